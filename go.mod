@@ -5,7 +5,9 @@ go 1.24
 toolchain go1.24.4
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.18.0
 	github.com/stretchr/testify v1.10.0
 	go.n16f.net/acme v0.0.0-20250705125806-ffbdeac8ee14
 	go.n16f.net/bcl v0.0.0-20250712123444-b5b1be09f25f
@@ -13,6 +15,7 @@ require (
 	go.n16f.net/log v0.0.0-20240820155337-9eef10dcf842
 	go.n16f.net/program v0.0.0-20241208190041-4d0013a2857b
 	golang.org/x/crypto v0.39.0
+	golang.org/x/net v0.41.0
 )
 
 require (
@@ -21,7 +24,6 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	go.n16f.net/uuid v0.0.0-20240707135755-e4fd26b968ad // indirect
 	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
-	golang.org/x/net v0.41.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/term v0.32.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
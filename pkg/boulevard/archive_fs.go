@@ -0,0 +1,507 @@
+package boulevard
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ArchiveFS is a read-only file system backed by a zip, tar or gzip-compressed
+// tar archive. The archive is indexed when it is opened; files stored without
+// compression are then read directly from the archive file, so they support
+// seeking at no cost.
+//
+// Gzip-compressed tar archives cannot be read at random offsets and are
+// decompressed to a temporary file when opened. Compressed zip entries are
+// decompressed while being read; seeking backward restarts decompression from
+// the beginning of the entry.
+type ArchiveFS struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+
+	file        *os.File
+	tmpFilePath string // gzip-compressed tar archives only
+	entries     map[string]*archiveEntry
+}
+
+type archiveEntry struct {
+	info     archiveFileInfo
+	children []*archiveEntry
+
+	// Regular files only
+	offset  int64     // -1 if the content must be decompressed
+	zipFile *zip.File // compressed zip entries only
+}
+
+func OpenArchiveFS(filePath string) (*ArchiveFS, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("cannot stat %q: %w", filePath, err)
+	}
+
+	a := ArchiveFS{
+		Path:    filePath,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+
+		file: file,
+		entries: map[string]*archiveEntry{
+			".": newArchiveDirEntry(".", info.ModTime()),
+		},
+	}
+
+	switch {
+	case strings.HasSuffix(filePath, ".zip"):
+		err = a.indexZipArchive()
+
+	case strings.HasSuffix(filePath, ".tar"):
+		err = a.indexTarArchive(file)
+
+	case strings.HasSuffix(filePath, ".tar.gz"),
+		strings.HasSuffix(filePath, ".tgz"):
+		if err = a.decompressGzipArchive(); err == nil {
+			err = a.indexTarArchive(a.file)
+		}
+
+	default:
+		err = fmt.Errorf("unknown archive format")
+	}
+
+	if err != nil {
+		a.Close()
+		return nil, fmt.Errorf("cannot read archive %q: %w", filePath, err)
+	}
+
+	for _, entry := range a.entries {
+		slices.SortFunc(entry.children, func(e1, e2 *archiveEntry) int {
+			return strings.Compare(e1.info.name, e2.info.name)
+		})
+	}
+
+	return &a, nil
+}
+
+func (a *ArchiveFS) Close() error {
+	var err error
+
+	if a.file != nil {
+		err = a.file.Close()
+		a.file = nil
+	}
+
+	if a.tmpFilePath != "" {
+		if err2 := os.Remove(a.tmpFilePath); err2 != nil && err == nil {
+			err = fmt.Errorf("cannot delete %q: %w", a.tmpFilePath, err2)
+		}
+
+		a.tmpFilePath = ""
+	}
+
+	return err
+}
+
+func (a *ArchiveFS) decompressGzipArchive() error {
+	zr, err := gzip.NewReader(a.file)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp("", "boulevard-archive-*.tar")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file: %w", err)
+	}
+
+	archiveFile := a.file
+	defer archiveFile.Close()
+
+	a.file = tmpFile
+	a.tmpFilePath = tmpFile.Name()
+
+	if _, err := io.Copy(tmpFile, zr); err != nil {
+		return fmt.Errorf("cannot decompress archive to %q: %w",
+			a.tmpFilePath, err)
+	}
+
+	if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("cannot seek %q: %w", a.tmpFilePath, err)
+	}
+
+	return nil
+}
+
+func (a *ArchiveFS) indexZipArchive() error {
+	zr, err := zip.NewReader(a.file, a.Size)
+	if err != nil {
+		return err
+	}
+
+	for _, zf := range zr.File {
+		name, ok := archiveEntryName(zf.Name)
+		if !ok {
+			continue
+		}
+
+		modTime := zf.Modified
+
+		if strings.HasSuffix(zf.Name, "/") {
+			a.addDirectory(name, modTime)
+			continue
+		}
+
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+
+		entry := archiveEntry{
+			info: archiveFileInfo{
+				name:    path.Base(name),
+				size:    int64(zf.UncompressedSize64),
+				mode:    zf.Mode().Perm(),
+				modTime: modTime,
+			},
+
+			offset: -1,
+		}
+
+		if zf.Method == zip.Store {
+			offset, err := zf.DataOffset()
+			if err != nil {
+				return fmt.Errorf("cannot locate %q: %w", zf.Name, err)
+			}
+
+			entry.offset = offset
+		} else {
+			entry.zipFile = zf
+		}
+
+		a.addEntry(name, &entry)
+	}
+
+	return nil
+}
+
+func (a *ArchiveFS) indexTarArchive(r io.Reader) error {
+	// We need the offset of each file in the archive so that we can read it
+	// later without going through tar.Reader.
+	cr := countingReader{r: r}
+	tr := tar.NewReader(&cr)
+
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return err
+		}
+
+		name, ok := archiveEntryName(header.Name)
+		if !ok {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			a.addDirectory(name, header.ModTime)
+
+		case tar.TypeReg:
+			entry := archiveEntry{
+				info: archiveFileInfo{
+					name:    path.Base(name),
+					size:    header.Size,
+					mode:    fs.FileMode(header.Mode).Perm(),
+					modTime: header.ModTime,
+				},
+
+				offset: cr.n,
+			}
+
+			a.addEntry(name, &entry)
+		}
+	}
+
+	return nil
+}
+
+func (a *ArchiveFS) addEntry(name string, entry *archiveEntry) {
+	if _, found := a.entries[name]; found {
+		// Later entries replace previous ones, as when extracting the
+		// archive.
+		a.removeEntry(name)
+	}
+
+	parent := a.addDirectory(path.Dir(name), entry.info.modTime)
+	parent.children = append(parent.children, entry)
+
+	a.entries[name] = entry
+}
+
+func (a *ArchiveFS) removeEntry(name string) {
+	entry := a.entries[name]
+	parent := a.entries[path.Dir(name)]
+
+	parent.children = slices.DeleteFunc(parent.children,
+		func(e *archiveEntry) bool { return e == entry })
+
+	delete(a.entries, name)
+}
+
+func (a *ArchiveFS) addDirectory(name string, modTime time.Time) *archiveEntry {
+	if entry, found := a.entries[name]; found {
+		if entry.info.IsDir() {
+			return entry
+		}
+
+		a.removeEntry(name)
+	}
+
+	entry := newArchiveDirEntry(path.Base(name), modTime)
+
+	parent := a.addDirectory(path.Dir(name), modTime)
+	parent.children = append(parent.children, entry)
+
+	a.entries[name] = entry
+
+	return entry
+}
+
+func newArchiveDirEntry(name string, modTime time.Time) *archiveEntry {
+	return &archiveEntry{
+		info: archiveFileInfo{
+			name:    name,
+			mode:    fs.ModeDir | 0755,
+			modTime: modTime,
+		},
+	}
+}
+
+func archiveEntryName(s string) (string, bool) {
+	name := path.Clean(strings.TrimLeft(s, "/"))
+	if name == "." || !fs.ValidPath(name) {
+		return "", false
+	}
+
+	return name, true
+}
+
+// fs.FS
+func (a *ArchiveFS) Open(name string) (fs.File, error) {
+	entry, err := a.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.info.IsDir() {
+		return &archiveDir{entry: entry}, nil
+	}
+
+	var r io.ReadSeeker
+
+	if entry.offset >= 0 {
+		r = io.NewSectionReader(a.file, entry.offset, entry.info.size)
+	} else {
+		r = &zipEntryReader{zipFile: entry.zipFile, size: entry.info.size}
+	}
+
+	return &archiveFile{entry: entry, ReadSeeker: r}, nil
+}
+
+// fs.StatFS
+func (a *ArchiveFS) Stat(name string) (fs.FileInfo, error) {
+	entry, err := a.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry.info, nil
+}
+
+func (a *ArchiveFS) lookup(op, name string) (*archiveEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	entry, found := a.entries[name]
+	if !found {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return entry, nil
+}
+
+type archiveFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *archiveFileInfo) Name() string       { return i.name }
+func (i *archiveFileInfo) Size() int64        { return i.size }
+func (i *archiveFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *archiveFileInfo) ModTime() time.Time { return i.modTime }
+func (i *archiveFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *archiveFileInfo) Sys() any           { return nil }
+
+type archiveFile struct {
+	io.ReadSeeker
+	entry *archiveEntry
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) {
+	return &f.entry.info, nil
+}
+
+func (f *archiveFile) Close() error {
+	if c, ok := f.ReadSeeker.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// zipEntryReader decompresses a zip entry on demand. Seeking is lazy: moving
+// forward skips decompressed data on the next read, and moving backward
+// restarts decompression.
+type zipEntryReader struct {
+	zipFile *zip.File
+	size    int64
+
+	r       io.ReadCloser
+	rOffset int64 // position of r in the decompressed data
+	offset  int64
+}
+
+func (r *zipEntryReader) Read(data []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.r == nil || r.rOffset > r.offset {
+		if r.r != nil {
+			r.r.Close()
+			r.r = nil
+		}
+
+		zr, err := r.zipFile.Open()
+		if err != nil {
+			return 0, err
+		}
+
+		r.r = zr
+		r.rOffset = 0
+	}
+
+	if r.rOffset < r.offset {
+		n, err := io.CopyN(io.Discard, r.r, r.offset-r.rOffset)
+		r.rOffset += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.r.Read(data)
+	r.rOffset += int64(n)
+	r.offset += int64(n)
+
+	return n, err
+}
+
+func (r *zipEntryReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	r.offset = offset
+
+	return offset, nil
+}
+
+func (r *zipEntryReader) Close() error {
+	if r.r == nil {
+		return nil
+	}
+
+	err := r.r.Close()
+	r.r = nil
+
+	return err
+}
+
+type archiveDir struct {
+	entry  *archiveEntry
+	offset int
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) {
+	return &d.entry.info, nil
+}
+
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.info.name,
+		Err: errors.New("is a directory")}
+}
+
+func (d *archiveDir) Close() error {
+	return nil
+}
+
+// fs.ReadDirFile
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	children := d.entry.children[d.offset:]
+
+	if n > 0 {
+		if len(children) == 0 {
+			return nil, io.EOF
+		}
+
+		children = children[:min(n, len(children))]
+	}
+
+	entries := make([]fs.DirEntry, len(children))
+	for i, child := range children {
+		entries[i] = fs.FileInfoToDirEntry(&child.info)
+	}
+
+	d.offset += len(children)
+
+	return entries, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(data []byte) (int, error) {
+	n, err := r.r.Read(data)
+	r.n += int64(n)
+	return n, err
+}
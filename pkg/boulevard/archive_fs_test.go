@@ -0,0 +1,153 @@
+package boulevard
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testArchiveFiles = []struct {
+	name    string
+	content string
+}{
+	{"index.html", "index\n"},
+	{"css/style.css", strings.Repeat("body { color: red; }\n", 100)},
+	{"js/app.js", "app\n"},
+	{"js/vendor/lib.js", "lib\n"},
+}
+
+func TestArchiveFS(t *testing.T) {
+	dirPath := t.TempDir()
+
+	for _, filename := range []string{"a.zip", "a.tar", "a.tar.gz"} {
+		t.Run(filename, func(t *testing.T) {
+			require := require.New(t)
+
+			filePath := path.Join(dirPath, filename)
+			writeTestArchive(t, filePath)
+
+			a, err := OpenArchiveFS(filePath)
+			require.NoError(err)
+			defer a.Close()
+
+			var names []string
+			for _, f := range testArchiveFiles {
+				names = append(names, f.name)
+
+				data, err := fs.ReadFile(a, f.name)
+				require.NoError(err)
+				require.Equal(f.content, string(data))
+			}
+
+			require.NoError(fstest.TestFS(a, names...))
+
+			file, err := a.Open("css/style.css")
+			require.NoError(err)
+			defer file.Close()
+
+			rs, ok := file.(io.ReadSeeker)
+			require.True(ok)
+			_, err = rs.Seek(-7, io.SeekEnd)
+			require.NoError(err)
+			data, err := io.ReadAll(rs)
+			require.NoError(err)
+			require.Equal("red; }\n", string(data))
+
+			_, err = rs.Seek(5, io.SeekStart)
+			require.NoError(err)
+			data = make([]byte, 6)
+			_, err = io.ReadFull(rs, data)
+			require.NoError(err)
+			require.Equal("{ colo", string(data))
+
+			_, err = a.Stat("unknown.txt")
+			require.ErrorIs(err, fs.ErrNotExist)
+
+			_, err = a.Open("../index.html")
+			require.ErrorIs(err, fs.ErrInvalid)
+		})
+	}
+
+	_, err := OpenArchiveFS(path.Join(dirPath, "unknown.zip"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func writeTestArchive(t *testing.T, filePath string) {
+	file, err := os.Create(filePath)
+	require.NoError(t, err)
+	defer file.Close()
+
+	modTime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	switch {
+	case strings.HasSuffix(filePath, ".zip"):
+		zw := zip.NewWriter(file)
+
+		for i, f := range testArchiveFiles {
+			// Use both stored and compressed entries
+			header := zip.FileHeader{
+				Name:     f.name,
+				Method:   zip.Store,
+				Modified: modTime,
+			}
+
+			if i%2 == 1 {
+				header.Method = zip.Deflate
+			}
+
+			w, err := zw.CreateHeader(&header)
+			require.NoError(t, err)
+			_, err = io.WriteString(w, f.content)
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, zw.Close())
+
+	default:
+		var w io.Writer = file
+
+		var zw *gzip.Writer
+		if strings.HasSuffix(filePath, ".gz") {
+			zw = gzip.NewWriter(file)
+			w = zw
+		}
+
+		tw := tar.NewWriter(w)
+
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     "js/",
+			Mode:     0755,
+			ModTime:  modTime,
+		}))
+
+		for _, f := range testArchiveFiles {
+			require.NoError(t, tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     f.name,
+				Mode:     0644,
+				Size:     int64(len(f.content)),
+				ModTime:  modTime,
+			}))
+
+			_, err := io.WriteString(tw, f.content)
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, tw.Close())
+
+		if zw != nil {
+			require.NoError(t, zw.Close())
+		}
+	}
+}
@@ -7,14 +7,19 @@ import (
 	"time"
 
 	"go.n16f.net/bcl"
+	"go.n16f.net/boulevard/pkg/httputils"
 	"go.n16f.net/boulevard/pkg/netutils"
 	"go.n16f.net/log"
 )
 
 type LoadBalancerCfg struct {
-	Name        string
-	Servers     []netutils.HostAddress
-	HealthProbe *HealthProbeCfg
+	Name             string
+	Servers          []netutils.HostAddress
+	HealthProbe      *HealthProbeCfg
+	OutlierDetection *OutlierDetectionCfg
+
+	// Used by reverse proxy actions unless they override them
+	ClientSettings httputils.ClientSettingsCfg
 
 	Log *log.Logger
 }
@@ -32,6 +37,9 @@ func (cfg *LoadBalancerCfg) ReadBCLElement(block *bcl.Element) error {
 	}
 
 	block.MaybeBlock("health_probe", &cfg.HealthProbe)
+	block.MaybeBlock("outlier_detection", &cfg.OutlierDetection)
+
+	cfg.ClientSettings.ReadBCLElement(block)
 
 	return nil
 }
@@ -41,6 +49,22 @@ type LoadBalancerServer struct {
 
 	healthy     atomic.Bool
 	healthProbe *HealthProbe
+
+	outlier outlierState
+}
+
+type LoadBalancerStatus struct {
+	Name    string                      `json:"name"`
+	Servers []*LoadBalancerServerStatus `json:"servers"`
+}
+
+type LoadBalancerServerStatus struct {
+	Address           string     `json:"address"`
+	Healthy           bool       `json:"healthy"`
+	Ejected           bool       `json:"ejected"`
+	EjectedUntil      *time.Time `json:"ejected_until,omitempty"`
+	NbEjections       int        `json:"nb_ejections"`
+	ConsecutiveErrors int        `json:"consecutive_errors"`
 }
 
 type LoadBalancer struct {
@@ -49,6 +73,7 @@ type LoadBalancer struct {
 
 	Servers         []*LoadBalancerServer
 	nextServerIndex int
+	mutex           sync.Mutex
 
 	stopChan chan struct{}
 	wg       sync.WaitGroup
@@ -133,12 +158,18 @@ func (lb *LoadBalancer) watchServerHealth(server *LoadBalancerServer) {
 func (lb *LoadBalancer) Address() string {
 	var server *LoadBalancerServer
 
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	now := time.Now()
+
 	firstIndex := lb.nextServerIndex
 	for {
 		server = lb.Servers[lb.nextServerIndex]
 		lb.nextServerIndex = (lb.nextServerIndex + 1) % len(lb.Servers)
 
-		if server.healthy.Load() == true {
+		if server.healthy.Load() == true &&
+			!lb.isServerEjected(server, now) {
 			return server.Address.String()
 		}
 
@@ -150,3 +181,34 @@ func (lb *LoadBalancer) Address() string {
 	// No healthy server available
 	return ""
 }
+
+func (lb *LoadBalancer) Status() *LoadBalancerStatus {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	now := time.Now()
+
+	status := LoadBalancerStatus{
+		Name:    lb.Cfg.Name,
+		Servers: make([]*LoadBalancerServerStatus, len(lb.Servers)),
+	}
+
+	for i, server := range lb.Servers {
+		serverStatus := LoadBalancerServerStatus{
+			Address:           server.Address.String(),
+			Healthy:           server.healthy.Load(),
+			Ejected:           lb.isServerEjected(server, now),
+			NbEjections:       server.outlier.nbEjections,
+			ConsecutiveErrors: server.outlier.consecutiveErrors,
+		}
+
+		if serverStatus.Ejected {
+			ejectedUntil := server.outlier.ejectedUntil
+			serverStatus.EjectedUntil = &ejectedUntil
+		}
+
+		status.Servers[i] = &serverStatus
+	}
+
+	return &status
+}
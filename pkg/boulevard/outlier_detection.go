@@ -0,0 +1,194 @@
+package boulevard
+
+import (
+	"fmt"
+	"time"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/log"
+)
+
+const (
+	DefaultOutlierDetectionConsecutiveErrors     = 5
+	DefaultOutlierDetectionEjectionTime          = 30 * time.Second
+	DefaultOutlierDetectionMaxEjectionTime       = 300 * time.Second
+	DefaultOutlierDetectionMaxEjectionPercentage = 50.0
+)
+
+// Outlier detection is passive: servers are ejected based on the result of
+// the requests actually sent to them, as reported by the components using the
+// load balancer.
+type OutlierDetectionCfg struct {
+	ConsecutiveErrors     int
+	EjectionTime          time.Duration
+	MaxEjectionTime       time.Duration
+	MaxEjectionPercentage float64
+}
+
+func (cfg *OutlierDetectionCfg) ReadBCLElement(block *bcl.Element) error {
+	cfg.ConsecutiveErrors = DefaultOutlierDetectionConsecutiveErrors
+	block.MaybeEntryValues("consecutive_errors",
+		bcl.WithValueValidation(&cfg.ConsecutiveErrors,
+			bcl.ValidatePositiveInteger))
+
+	cfg.EjectionTime = DefaultOutlierDetectionEjectionTime
+	block.MaybeEntryValues("ejection_time", &cfg.EjectionTime)
+
+	cfg.MaxEjectionTime = DefaultOutlierDetectionMaxEjectionTime
+	block.MaybeEntryValues("max_ejection_time", &cfg.MaxEjectionTime)
+
+	cfg.MaxEjectionPercentage = DefaultOutlierDetectionMaxEjectionPercentage
+	block.MaybeEntryValues("max_ejection_percentage",
+		bcl.WithValueValidation(&cfg.MaxEjectionPercentage,
+			ValidateBCLPercentage))
+
+	if cfg.MaxEjectionTime < cfg.EjectionTime {
+		block.AddSimpleValidationError("max_ejection_time must be greater " +
+			"or equal to ejection_time")
+	}
+
+	return nil
+}
+
+type outlierState struct {
+	consecutiveErrors int
+	nbEjections       int
+	ejectedUntil      time.Time
+	lastEjectionEnd   time.Time
+}
+
+// ReportSuccess records a successful request sent to a server.
+func (lb *LoadBalancer) ReportSuccess(address string) {
+	if lb.Cfg.OutlierDetection == nil {
+		return
+	}
+
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	if server := lb.server(address); server != nil {
+		server.outlier.consecutiveErrors = 0
+	}
+}
+
+// ReportFailure records a request sent to a server which failed, either
+// because of a connection error or because the server returned a 5xx
+// response. The server is ejected if it has failed too many times in a row.
+func (lb *LoadBalancer) ReportFailure(address string) {
+	cfg := lb.Cfg.OutlierDetection
+	if cfg == nil {
+		return
+	}
+
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	server := lb.server(address)
+	if server == nil {
+		return
+	}
+
+	now := time.Now()
+
+	// Requests which were already in progress when the server was ejected
+	// do not count.
+	if lb.isServerEjected(server, now) {
+		return
+	}
+
+	state := &server.outlier
+
+	state.consecutiveErrors++
+	if state.consecutiveErrors < cfg.ConsecutiveErrors {
+		return
+	}
+
+	logData := log.Data{
+		"server": server.Address.String(),
+	}
+
+	if lb.nbEjectedServers(now) >= lb.maxEjectedServers() {
+		lb.Log.DebugData(logData, 1, "cannot eject server: too many "+
+			"servers already ejected")
+		return
+	}
+
+	// The ejection time grows each time the server is ejected again, unless
+	// it has behaved correctly for long enough.
+	if !state.lastEjectionEnd.IsZero() &&
+		now.Sub(state.lastEjectionEnd) >= cfg.MaxEjectionTime {
+		state.nbEjections = 0
+	}
+
+	state.nbEjections++
+
+	ejectionTime := cfg.EjectionTime << (state.nbEjections - 1)
+	if ejectionTime > cfg.MaxEjectionTime || ejectionTime <= 0 {
+		ejectionTime = cfg.MaxEjectionTime
+	}
+
+	lb.Log.InfoData(logData, "ejecting server for %v after %d consecutive "+
+		"errors", ejectionTime, state.consecutiveErrors)
+
+	state.ejectedUntil = now.Add(ejectionTime)
+	state.consecutiveErrors = 0
+}
+
+func (lb *LoadBalancer) server(address string) *LoadBalancerServer {
+	for _, server := range lb.Servers {
+		if server.Address.String() == address {
+			return server
+		}
+	}
+
+	return nil
+}
+
+// isServerEjected must be called with the mutex locked.
+func (lb *LoadBalancer) isServerEjected(server *LoadBalancerServer, now time.Time) bool {
+	state := &server.outlier
+
+	if state.ejectedUntil.IsZero() {
+		return false
+	}
+
+	if now.Before(state.ejectedUntil) {
+		return true
+	}
+
+	logData := log.Data{
+		"server": server.Address.String(),
+	}
+
+	lb.Log.InfoData(logData, "re-enabling ejected server")
+
+	state.lastEjectionEnd = state.ejectedUntil
+	state.ejectedUntil = time.Time{}
+
+	return false
+}
+
+func (lb *LoadBalancer) nbEjectedServers(now time.Time) int {
+	var n int
+
+	for _, server := range lb.Servers {
+		if lb.isServerEjected(server, now) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (lb *LoadBalancer) maxEjectedServers() int {
+	percentage := lb.Cfg.OutlierDetection.MaxEjectionPercentage
+	return int(float64(len(lb.Servers)) * percentage / 100.0)
+}
+
+func ValidateBCLPercentage(v any) error {
+	if p := v.(float64); p < 0.0 || p > 100.0 {
+		return fmt.Errorf("percentage must be between 0 and 100")
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package boulevard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/netutils"
+	"go.n16f.net/log"
+)
+
+func TestOutlierDetection(t *testing.T) {
+	require := require.New(t)
+
+	cfg := LoadBalancerCfg{
+		Name: "test",
+		Servers: []netutils.HostAddress{
+			{Hostname: "a", Port: 80},
+			{Hostname: "b", Port: 80},
+		},
+		OutlierDetection: &OutlierDetectionCfg{
+			ConsecutiveErrors:     2,
+			EjectionTime:          100 * time.Millisecond,
+			MaxEjectionTime:       time.Second,
+			MaxEjectionPercentage: 50.0,
+		},
+		Log: log.DefaultLogger("load_balancer"),
+	}
+
+	lb, err := StartLoadBalancer(&cfg)
+	require.NoError(err)
+	defer lb.Stop()
+
+	addresses := func(n int) []string {
+		addrs := make([]string, n)
+		for i := range n {
+			addrs[i] = lb.Address()
+		}
+		return addrs
+	}
+
+	// Successful requests reset the error counter
+	lb.ReportFailure("a:80")
+	lb.ReportSuccess("a:80")
+	lb.ReportFailure("a:80")
+	require.Equal([]string{"a:80", "b:80"}, addresses(2))
+
+	// Ejection
+	lb.ReportFailure("a:80")
+	require.Equal([]string{"b:80", "b:80"}, addresses(2))
+
+	status := lb.Status()
+	require.True(status.Servers[0].Ejected)
+	require.NotNil(status.Servers[0].EjectedUntil)
+	require.Equal(1, status.Servers[0].NbEjections)
+	require.False(status.Servers[1].Ejected)
+
+	// We cannot eject more than half of the servers
+	lb.ReportFailure("b:80")
+	lb.ReportFailure("b:80")
+	require.False(lb.Status().Servers[1].Ejected)
+
+	// End of the ejection
+	time.Sleep(150 * time.Millisecond)
+	require.ElementsMatch([]string{"a:80", "b:80"}, addresses(2))
+
+	// The ejection time grows with each ejection
+	lb.ReportFailure("a:80")
+	lb.ReportFailure("a:80")
+
+	status = lb.Status()
+	require.True(status.Servers[0].Ejected)
+	require.Equal(2, status.Servers[0].NbEjections)
+	require.WithinDuration(time.Now().Add(200*time.Millisecond),
+		*status.Servers[0].EjectedUntil, 50*time.Millisecond)
+}
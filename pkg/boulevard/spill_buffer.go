@@ -7,23 +7,33 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"sync"
 
 	"go.n16f.net/boulevard/pkg/netutils"
 )
 
 var (
-	ErrSpillBufferFull = errors.New("maximum buffer capacity reached")
+	ErrSpillBufferFull      = errors.New("maximum buffer capacity reached")
+	ErrSpillBufferClosed    = errors.New("buffer closed")
+	ErrSpillBufferPersisted = errors.New("buffer persisted")
 )
 
 type SpillBuffer struct {
 	buffer []byte
 	file   *os.File
 
-	size int64
+	size      int64
+	spilled   bool // the content is stored in the file
+	persisted bool // the file contains the content and cannot be modified
+	closed    bool
 
 	filePath      string
 	maxMemorySize int64
 	maxBufferSize int64
+
+	// Buffers stored in the response cache are shared between requests, so
+	// they can be read and closed concurrently.
+	mutex sync.Mutex
 }
 
 func NewSpillBuffer(filePath string, maxMemorySize, maxBufferSize int64) *SpillBuffer {
@@ -36,18 +46,123 @@ func NewSpillBuffer(filePath string, maxMemorySize, maxBufferSize int64) *SpillB
 	return &buf
 }
 
+// LoadSpillBuffer returns a persisted buffer for the content of an existing
+// file. Content small enough to fit in memory is also kept in memory.
+func LoadSpillBuffer(filePath string, maxMemorySize, maxBufferSize int64) (*SpillBuffer, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		err = netutils.UnwrapOpError(err, "stat")
+		return nil, fmt.Errorf("cannot stat %q: %w", filePath, err)
+	}
+
+	if info.Size() > maxBufferSize {
+		return nil, ErrSpillBufferFull
+	}
+
+	buf := SpillBuffer{
+		size:      info.Size(),
+		spilled:   true,
+		persisted: true,
+
+		filePath:      filePath,
+		maxMemorySize: maxMemorySize,
+		maxBufferSize: maxBufferSize,
+	}
+
+	if buf.size <= maxMemorySize {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			err = netutils.UnwrapOpError(err, "read")
+			return nil, fmt.Errorf("cannot read %q: %w", filePath, err)
+		}
+
+		buf.buffer = data
+		buf.size = int64(len(data))
+		buf.spilled = false
+	}
+
+	return &buf, nil
+}
+
+func (buf *SpillBuffer) FilePath() string {
+	return buf.filePath
+}
+
 func (buf *SpillBuffer) Size() int64 {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+
 	return buf.size
 }
 
+// Persist makes sure that the content of the buffer is stored in its file,
+// including when it fits in memory. The buffer cannot be written to anymore.
+func (buf *SpillBuffer) Persist() error {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+
+	if buf.closed {
+		return ErrSpillBufferClosed
+	}
+
+	if buf.persisted {
+		return nil
+	}
+
+	if buf.file == nil {
+		if err := os.WriteFile(buf.filePath, buf.buffer, 0600); err != nil {
+			err = netutils.UnwrapOpError(err, "write")
+			return fmt.Errorf("cannot write %q: %w", buf.filePath, err)
+		}
+	} else {
+		if err := buf.file.Sync(); err != nil {
+			err = netutils.UnwrapOpError(err, "sync")
+			return fmt.Errorf("cannot sync %q: %w", buf.filePath, err)
+		}
+
+		if err := buf.file.Close(); err != nil {
+			return err
+		}
+
+		buf.file = nil
+	}
+
+	buf.persisted = true
+
+	return nil
+}
+
+// Close releases the buffer and deletes its file.
 func (buf *SpillBuffer) Close() error {
+	return buf.close(true)
+}
+
+// Release releases the buffer but keeps its file, which can be loaded again
+// with LoadSpillBuffer if the buffer was persisted.
+func (buf *SpillBuffer) Release() error {
+	return buf.close(false)
+}
+
+func (buf *SpillBuffer) close(deleteFile bool) error {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+
+	if buf.closed {
+		return nil
+	}
+
+	buf.closed = true
+	buf.buffer = nil
+
 	if buf.file != nil {
 		if err := buf.file.Close(); err != nil {
 			return err
 		}
 
 		buf.file = nil
+	}
 
+	if deleteFile && (buf.spilled || buf.persisted) {
 		if err := os.Remove(buf.filePath); err != nil {
 			if !errors.Is(err, fs.ErrNotExist) {
 				return fmt.Errorf("cannot delete %q: %w", buf.filePath, err)
@@ -58,8 +173,17 @@ func (buf *SpillBuffer) Close() error {
 	return nil
 }
 
+// Reader returns a reader for the content of the buffer. Readers remain usable
+// after the buffer has been closed, but new readers cannot be created.
 func (buf *SpillBuffer) Reader() (io.ReadCloser, error) {
-	if buf.file == nil {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+
+	if buf.closed {
+		return nil, ErrSpillBufferClosed
+	}
+
+	if !buf.spilled {
 		r := bytes.NewReader(buf.buffer)
 		return io.NopCloser(r), nil
 	}
@@ -74,6 +198,17 @@ func (buf *SpillBuffer) Reader() (io.ReadCloser, error) {
 }
 
 func (buf *SpillBuffer) Write(data []byte) (int, error) {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+
+	if buf.closed {
+		return 0, ErrSpillBufferClosed
+	}
+
+	if buf.persisted {
+		return 0, ErrSpillBufferPersisted
+	}
+
 	if buf.size+int64(len(data)) > buf.maxBufferSize {
 		return 0, ErrSpillBufferFull
 	}
@@ -93,6 +228,7 @@ func (buf *SpillBuffer) Write(data []byte) (int, error) {
 		}
 
 		buf.file = file
+		buf.spilled = true
 
 		if _, err := buf.file.Write(buf.buffer); err != nil {
 			err = netutils.UnwrapOpError(err, "write")
@@ -1,8 +1,11 @@
 package boulevard
 
 import (
+	"bytes"
+	"io"
 	"os"
 	"path"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -39,4 +42,95 @@ func TestSpillBuffer(t *testing.T) {
 	data, err = os.ReadFile(buf.filePath)
 	require.NoError(err)
 	require.Equal([]byte("foobar"), data)
+
+	r, err := buf.Reader()
+	require.NoError(err)
+	defer r.Close()
+
+	require.NoError(buf.Close())
+
+	data, err = io.ReadAll(r)
+	require.NoError(err)
+	require.Equal([]byte("foobar"), data)
+
+	_, err = buf.Reader()
+	require.ErrorIs(err, ErrSpillBufferClosed)
+}
+
+func TestSpillBufferConcurrentClose(t *testing.T) {
+	require := require.New(t)
+
+	dirPath := t.TempDir()
+
+	for _, size := range []int{3, 16} {
+		buf := NewSpillBuffer(path.Join(dirPath, "1"), 8, 32)
+
+		_, err := buf.Write(bytes.Repeat([]byte("a"), size))
+		require.NoError(err)
+
+		var wg sync.WaitGroup
+
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				r, err := buf.Reader()
+				if err != nil {
+					require.ErrorIs(err, ErrSpillBufferClosed)
+					return
+				}
+				defer r.Close()
+
+				data, err := io.ReadAll(r)
+				require.NoError(err)
+				require.Len(data, size)
+			}()
+		}
+
+		require.NoError(buf.Close())
+		wg.Wait()
+	}
+}
+
+func TestSpillBufferPersistence(t *testing.T) {
+	require := require.New(t)
+
+	dirPath := t.TempDir()
+
+	for _, content := range []string{"foo", "foobarbaz"} {
+		filePath := path.Join(dirPath, content)
+
+		buf := NewSpillBuffer(filePath, 5, 16)
+
+		_, err := buf.Write([]byte(content))
+		require.NoError(err)
+		require.NoError(buf.Persist())
+
+		_, err = buf.Write([]byte("x"))
+		require.ErrorIs(err, ErrSpillBufferPersisted)
+
+		require.NoError(buf.Release())
+		require.FileExists(filePath)
+
+		buf, err = LoadSpillBuffer(filePath, 5, 16)
+		require.NoError(err)
+		require.Equal(int64(len(content)), buf.Size())
+
+		r, err := buf.Reader()
+		require.NoError(err)
+		data, err := io.ReadAll(r)
+		require.NoError(err)
+		require.Equal(content, string(data))
+		r.Close()
+
+		require.NoError(buf.Close())
+		require.NoFileExists(filePath)
+	}
+
+	filePath := path.Join(dirPath, "large")
+	require.NoError(os.WriteFile(filePath, []byte("foobarbaz"), 0600))
+
+	_, err := LoadSpillBuffer(filePath, 5, 8)
+	require.ErrorIs(err, ErrSpillBufferFull)
 }
@@ -0,0 +1,161 @@
+package cgigateway
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"go.n16f.net/boulevard/pkg/fastcgi"
+	"go.n16f.net/boulevard/pkg/netutils"
+	"go.n16f.net/log"
+)
+
+const DefaultConnectionTimeout = 10 * time.Second
+
+var (
+	ErrConnectionLimitReached = errors.New("connection limit reached")
+	ErrRequestCancelled       = errors.New("request cancelled")
+	ErrRequestTimeout         = errors.New("request timeout")
+)
+
+// Protocol encodes requests and decodes responses for a protocol based on CGI
+// meta-variables.
+type Protocol interface {
+	EncodeRequest(map[string]string) ([]byte, error)
+	ReadResponseHeader(*bufio.Reader) (*fastcgi.Header, error)
+}
+
+type ClientCfg struct {
+	Log      *log.Logger
+	Protocol Protocol
+
+	Address           string
+	MaxConnections    *int
+	ConnectionTimeout time.Duration
+}
+
+// Client sends requests to a server using a protocol where each connection
+// carries a single request, i.e. SCGI and uwsgi. Neither protocol has a way to
+// delimit responses: the server signals the end of the response body by
+// closing the connection, so connections cannot be reused and are not pooled.
+// The client only limits the number of concurrent connections.
+type Client struct {
+	Cfg *ClientCfg
+	Log *log.Logger
+
+	connSlots chan struct{}
+}
+
+func NewClient(cfg *ClientCfg) (*Client, error) {
+	if cfg.ConnectionTimeout == 0 {
+		cfg.ConnectionTimeout = DefaultConnectionTimeout
+	}
+
+	c := Client{
+		Cfg: cfg,
+		Log: cfg.Log,
+	}
+
+	if maxConns := cfg.MaxConnections; maxConns != nil {
+		c.connSlots = make(chan struct{}, *maxConns)
+	}
+
+	return &c, nil
+}
+
+func (c *Client) Close() {
+}
+
+func (c *Client) SendRequest(ctx context.Context, params map[string]string, stdin io.Reader, stdout io.Writer) (*fastcgi.Header, error) {
+	if c.connSlots != nil {
+		select {
+		case c.connSlots <- struct{}{}:
+			defer func() { <-c.connSlots }()
+		default:
+			return nil, ErrConnectionLimitReached
+		}
+	}
+
+	data, err := c.Cfg.Protocol.EncodeRequest(params)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode request: %w", err)
+	}
+
+	address := c.Cfg.Address
+
+	dialer := net.Dialer{
+		Timeout: c.Cfg.ConnectionTimeout,
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		err = netutils.UnwrapOpError(err, "dial")
+		return nil, fmt.Errorf("cannot connect to %q: %w", address, err)
+	}
+	defer conn.Close()
+
+	// Closing the connection interrupts any pending read or write
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	header, err := c.sendRequest(conn, data, stdin, stdout)
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		return nil, err
+	}
+
+	return header, nil
+}
+
+func (c *Client) sendRequest(conn net.Conn, data []byte, stdin io.Reader, stdout io.Writer) (*fastcgi.Header, error) {
+	w := bufio.NewWriter(conn)
+
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("cannot write request header: %w", err)
+	}
+
+	if stdin != nil {
+		if _, err := io.Copy(w, stdin); err != nil {
+			return nil, fmt.Errorf("cannot write request body: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("cannot write request: %w", err)
+	}
+
+	r := bufio.NewReader(conn)
+
+	header, err := c.Cfg.Protocol.ReadResponseHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read response header: %w", err)
+	}
+
+	if _, err := io.Copy(stdout, r); err != nil {
+		return nil, fmt.Errorf("cannot read response body: %w", err)
+	}
+
+	return header, nil
+}
+
+func contextError(ctx context.Context) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return ErrRequestCancelled
+	case context.DeadlineExceeded:
+		return ErrRequestTimeout
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package cgigateway
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/fastcgi"
+	"go.n16f.net/log"
+)
+
+// testProtocol sends the request method on a single line
+type testProtocol struct{}
+
+func (testProtocol) EncodeRequest(params map[string]string) ([]byte, error) {
+	return []byte(params["REQUEST_METHOD"] + "\n"), nil
+}
+
+func (testProtocol) ReadResponseHeader(r *bufio.Reader) (*fastcgi.Header, error) {
+	return fastcgi.ReadHeader(r, 1024)
+}
+
+func TestClient(t *testing.T) {
+	require := require.New(t)
+
+	address := startTestServer(t)
+
+	maxConns := 1
+	cfg := ClientCfg{
+		Log:            log.DefaultLogger("cgigateway"),
+		Protocol:       testProtocol{},
+		Address:        address,
+		MaxConnections: &maxConns,
+	}
+
+	c, err := NewClient(&cfg)
+	require.NoError(err)
+	defer c.Close()
+
+	var stdout strings.Builder
+
+	params := map[string]string{"REQUEST_METHOD": "GET"}
+	header, err := c.SendRequest(context.Background(), params, nil, &stdout)
+	require.NoError(err)
+
+	status, _ := header.Status()
+	require.Equal(200, status)
+	require.Equal("GET", stdout.String())
+
+	// Connection limit
+	ctx, cancel := context.WithTimeout(context.Background(),
+		500*time.Millisecond)
+	defer cancel()
+
+	errChan := make(chan error)
+	go func() {
+		params := map[string]string{"REQUEST_METHOD": "SLEEP"}
+		_, err := c.SendRequest(ctx, params, nil, io.Discard)
+		errChan <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = c.SendRequest(context.Background(), params, nil, io.Discard)
+	require.ErrorIs(err, ErrConnectionLimitReached)
+
+	require.ErrorIs(<-errChan, ErrRequestTimeout)
+
+	// Cancellation
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	params["REQUEST_METHOD"] = "SLEEP"
+	_, err = c.SendRequest(ctx, params, nil, io.Discard)
+	require.ErrorIs(err, ErrRequestCancelled)
+}
+
+func startTestServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveTestConnection(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func serveTestConnection(conn net.Conn) {
+	defer conn.Close()
+
+	method, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+
+	method = strings.TrimSuffix(method, "\n")
+	if method == "SLEEP" {
+		time.Sleep(time.Second)
+		return
+	}
+
+	fmt.Fprintf(conn, "Status: 200 OK\r\n\r\n%s", method)
+}
@@ -1,8 +1,11 @@
 package fastcgi
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -96,6 +99,37 @@ func (h *Header) Parse(data []byte) (bool, []byte, error) {
 	return false, nil, nil
 }
 
+// ReadHeader reads and parses a header from a stream, leaving the reader at the
+// beginning of the body. It is used for protocols where the response is a plain
+// byte stream, e.g. CGI or SCGI.
+func ReadHeader(r *bufio.Reader, maxSize int) (*Header, error) {
+	var header Header
+	var size int
+
+	for {
+		line, err := r.ReadSlice('\n')
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return nil, err
+		}
+
+		size += len(line)
+		if size > maxSize {
+			return nil, fmt.Errorf("header too large")
+		}
+
+		done, _, err := header.Parse(line)
+		if err != nil {
+			return nil, err
+		} else if done {
+			return &header, nil
+		}
+	}
+}
+
 func (f *Field) Parse(data []byte) error {
 	colon := bytes.IndexByte(data, ':')
 	if colon == -1 {
@@ -0,0 +1,104 @@
+package httputils
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheControl contains the directives of one or more Cache-Control header
+// fields, indexed by lower case name. Directives without an argument are
+// associated with an empty string.
+type CacheControl map[string]string
+
+func ParseCacheControl(header http.Header) CacheControl {
+	// See RFC 9111 5.2. Cache-Control
+	//
+	// As for token lists, we ignore invalid directives instead of rejecting
+	// the whole field.
+
+	cc := make(CacheControl)
+
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range splitCacheControlDirectives(value) {
+			name, arg, _ := strings.Cut(part, "=")
+
+			name = strings.ToLower(strings.Trim(name, " \t"))
+			if name == "" {
+				continue
+			}
+
+			arg = strings.Trim(arg, " \t")
+			if len(arg) >= 2 && arg[0] == '"' && arg[len(arg)-1] == '"' {
+				arg = arg[1 : len(arg)-1]
+			}
+
+			if _, found := cc[name]; !found {
+				cc[name] = arg
+			}
+		}
+	}
+
+	return cc
+}
+
+func splitCacheControlDirectives(s string) []string {
+	// Quoted strings can contain commas (e.g. private="Foo, Bar"), so we
+	// cannot simply use SplitTokenList.
+
+	var parts []string
+
+	start := 0
+	quoted := false
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	parts = append(parts, s[start:])
+
+	return parts
+}
+
+func (cc CacheControl) Has(name string) bool {
+	_, found := cc[name]
+	return found
+}
+
+func (cc CacheControl) Duration(name string) (time.Duration, bool) {
+	value, found := cc[name]
+	if !found {
+		return 0, false
+	}
+
+	// RFC 9111 1.2.2. Delta Seconds: "If a cache receives a delta-seconds value
+	// greater than the greatest integer it can represent, or if any of its
+	// subsequent calculations overflows, the cache MUST consider the value to
+	// be 2147483648".
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		if !errors.Is(err, strconv.ErrRange) || strings.HasPrefix(value, "-") {
+			return 0, false
+		}
+
+		seconds = 2147483648
+	}
+
+	if seconds < 0 {
+		return 0, false
+	}
+
+	seconds = min(seconds, 2147483648)
+
+	return time.Duration(seconds) * time.Second, true
+}
@@ -0,0 +1,66 @@
+package httputils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		s  string
+		cc CacheControl
+	}{
+		{"",
+			CacheControl{}},
+		{"no-cache",
+			CacheControl{"no-cache": ""}},
+		{"Max-Age=60, public",
+			CacheControl{"max-age": "60", "public": ""}},
+		{" max-age = 60 ,, s-maxage=120 ",
+			CacheControl{"max-age": "60", "s-maxage": "120"}},
+		{`private="Set-Cookie, X-Foo", max-age=10`,
+			CacheControl{"private": "Set-Cookie, X-Foo", "max-age": "10"}},
+		{"max-age=10, max-age=20",
+			CacheControl{"max-age": "10"}},
+	}
+
+	for _, test := range tests {
+		label := fmt.Sprintf("%q", test.s)
+
+		cc := ParseCacheControl(Header("Cache-Control", test.s))
+		assert.Equal(test.cc, cc, label)
+	}
+}
+
+func TestCacheControlDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		s     string
+		d     time.Duration
+		valid bool
+	}{
+		{"max-age=0", 0, true},
+		{"max-age=60", 60 * time.Second, true},
+		{"max-age=99999999999999999999", 2147483648 * time.Second, true},
+		{"max-age", 0, false},
+		{"max-age=-1", 0, false},
+		{"max-age=foo", 0, false},
+		{"public", 0, false},
+	}
+
+	for _, test := range tests {
+		label := fmt.Sprintf("%q", test.s)
+
+		cc := ParseCacheControl(Header("Cache-Control", test.s))
+		d, valid := cc.Duration("max-age")
+
+		assert.Equal(test.valid, valid, label)
+		assert.Equal(test.d, d, label)
+	}
+}
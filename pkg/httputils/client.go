@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strings"
@@ -28,6 +29,10 @@ type ClientCfg struct {
 	ConnectionTimeout            time.Duration
 	ConnectionAcquisitionTimeout time.Duration
 	IdleConnectionTimeout        time.Duration
+
+	// Disabled if zero
+	ResponseHeaderTimeout time.Duration
+	ResponseBodyTimeout   time.Duration
 }
 
 type Client struct {
@@ -50,6 +55,13 @@ type ClientConn struct {
 	Conn   net.Conn
 	reader *bufio.Reader
 
+	responseHeaderTimeout time.Duration
+	responseBodyTimeout   time.Duration
+
+	// The deadline set by the user of the connection. Response timeouts can
+	// shorten it but never extend it.
+	deadline time.Time
+
 	lastActivity atomic.Pointer[time.Time]
 }
 
@@ -60,6 +72,23 @@ func (c *ClientConn) Close() {
 	}
 }
 
+// SetDeadline sets the read and write deadlines of the connection. Use it
+// instead of setting deadlines on the underlying connection so that response
+// timeouts do not override it.
+func (c *ClientConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *ClientConn) readDeadline(timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if !c.deadline.IsZero() && c.deadline.Before(deadline) {
+		return c.deadline
+	}
+
+	return deadline
+}
+
 func (c *ClientConn) SendRequest(req *http.Request) (*http.Response, error) {
 	now := time.Now()
 	c.lastActivity.Store(&now)
@@ -68,14 +97,52 @@ func (c *ClientConn) SendRequest(req *http.Request) (*http.Response, error) {
 		return nil, fmt.Errorf("cannot write request: %w", err)
 	}
 
+	if c.responseHeaderTimeout > 0 {
+		c.Conn.SetReadDeadline(c.readDeadline(c.responseHeaderTimeout))
+	}
+
 	res, err := http.ReadResponse(c.reader, req)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read response: %w", err)
 	}
 
+	if c.responseHeaderTimeout > 0 {
+		c.Conn.SetReadDeadline(c.deadline)
+	}
+
+	if c.responseBodyTimeout > 0 {
+		res.Body = &clientResponseBody{
+			ReadCloser: res.Body,
+			conn:       c,
+			netConn:    c.Conn,
+			timeout:    c.responseBodyTimeout,
+		}
+	}
+
 	return res, nil
 }
 
+// clientResponseBody enforces a timeout on each read of the response body so
+// that an upstream server which stops sending data does not block us forever.
+type clientResponseBody struct {
+	io.ReadCloser
+
+	conn    *ClientConn
+	netConn net.Conn // still set if the connection is closed
+	timeout time.Duration
+}
+
+func (b *clientResponseBody) Read(data []byte) (int, error) {
+	b.netConn.SetReadDeadline(b.conn.readDeadline(b.timeout))
+
+	n, err := b.ReadCloser.Read(data)
+	if err == io.EOF {
+		b.netConn.SetReadDeadline(b.conn.deadline)
+	}
+
+	return n, err
+}
+
 func NewClient(cfg ClientCfg) (*Client, error) {
 	if cfg.MaxConnections == 0 {
 		cfg.MaxConnections = 10
@@ -221,6 +288,9 @@ func (c *Client) connect() (*ClientConn, error) {
 	cc := ClientConn{
 		Conn:   conn,
 		reader: bufio.NewReader(conn),
+
+		responseHeaderTimeout: c.Cfg.ResponseHeaderTimeout,
+		responseBodyTimeout:   c.Cfg.ResponseBodyTimeout,
 	}
 
 	return &cc, nil
@@ -0,0 +1,96 @@
+package httputils
+
+import (
+	"time"
+
+	"go.n16f.net/bcl"
+)
+
+// ClientSettingsCfg contains the client settings which can be configured by
+// users. Unset values are nil so that settings can be merged from multiple
+// sources.
+type ClientSettingsCfg struct {
+	MaxConnections               *int
+	ConnectionTimeout            *time.Duration
+	ConnectionAcquisitionTimeout *time.Duration
+	IdleConnectionTimeout        *time.Duration
+
+	ResponseHeaderTimeout *time.Duration
+	ResponseBodyTimeout   *time.Duration
+}
+
+func (cfg *ClientSettingsCfg) ReadBCLElement(block *bcl.Element) error {
+	block.MaybeEntryValues("max_connections",
+		bcl.WithValueValidation(&cfg.MaxConnections,
+			bcl.ValidatePositiveInteger))
+	block.MaybeEntryValues("connection_timeout", &cfg.ConnectionTimeout)
+	block.MaybeEntryValues("connection_acquisition_timeout",
+		&cfg.ConnectionAcquisitionTimeout)
+	block.MaybeEntryValues("idle_connection_timeout",
+		&cfg.IdleConnectionTimeout)
+
+	block.MaybeEntryValues("response_header_timeout",
+		&cfg.ResponseHeaderTimeout)
+	block.MaybeEntryValues("response_body_timeout", &cfg.ResponseBodyTimeout)
+
+	return nil
+}
+
+// Merge returns a copy of the settings where unset values are taken from
+// defaultCfg.
+func (cfg ClientSettingsCfg) Merge(defaultCfg ClientSettingsCfg) ClientSettingsCfg {
+	if cfg.MaxConnections == nil {
+		cfg.MaxConnections = defaultCfg.MaxConnections
+	}
+
+	if cfg.ConnectionTimeout == nil {
+		cfg.ConnectionTimeout = defaultCfg.ConnectionTimeout
+	}
+
+	if cfg.ConnectionAcquisitionTimeout == nil {
+		cfg.ConnectionAcquisitionTimeout =
+			defaultCfg.ConnectionAcquisitionTimeout
+	}
+
+	if cfg.IdleConnectionTimeout == nil {
+		cfg.IdleConnectionTimeout = defaultCfg.IdleConnectionTimeout
+	}
+
+	if cfg.ResponseHeaderTimeout == nil {
+		cfg.ResponseHeaderTimeout = defaultCfg.ResponseHeaderTimeout
+	}
+
+	if cfg.ResponseBodyTimeout == nil {
+		cfg.ResponseBodyTimeout = defaultCfg.ResponseBodyTimeout
+	}
+
+	return cfg
+}
+
+// Apply copies the settings which are set to a client configuration.
+func (cfg ClientSettingsCfg) Apply(clientCfg *ClientCfg) {
+	if cfg.MaxConnections != nil {
+		clientCfg.MaxConnections = *cfg.MaxConnections
+	}
+
+	if cfg.ConnectionTimeout != nil {
+		clientCfg.ConnectionTimeout = *cfg.ConnectionTimeout
+	}
+
+	if cfg.ConnectionAcquisitionTimeout != nil {
+		clientCfg.ConnectionAcquisitionTimeout =
+			*cfg.ConnectionAcquisitionTimeout
+	}
+
+	if cfg.IdleConnectionTimeout != nil {
+		clientCfg.IdleConnectionTimeout = *cfg.IdleConnectionTimeout
+	}
+
+	if cfg.ResponseHeaderTimeout != nil {
+		clientCfg.ResponseHeaderTimeout = *cfg.ResponseHeaderTimeout
+	}
+
+	if cfg.ResponseBodyTimeout != nil {
+		clientCfg.ResponseBodyTimeout = *cfg.ResponseBodyTimeout
+	}
+}
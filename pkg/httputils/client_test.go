@@ -0,0 +1,124 @@
+package httputils
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientConnTimeouts(t *testing.T) {
+	require := require.New(t)
+
+	short := 100 * time.Millisecond
+	long := 5 * time.Second
+
+	sendRequest := func(path string, deadline time.Time, headerTimeout, bodyTimeout time.Duration) (*ClientConn, *http.Response, error) {
+		conn := testClientConn(t, headerTimeout, bodyTimeout)
+		require.NoError(conn.SetDeadline(deadline))
+
+		req, err := http.NewRequest("GET", "http://localhost"+path, nil)
+		require.NoError(err)
+
+		res, err := conn.SendRequest(req)
+		return conn, res, err
+	}
+
+	// The deadline of the caller expires before the header timeout
+	start := time.Now()
+	_, _, err := sendRequest("/stall-header", start.Add(short), long, long)
+	require.ErrorIs(err, os.ErrDeadlineExceeded)
+	require.Less(time.Since(start), long)
+
+	// The header timeout expires before the deadline of the caller
+	start = time.Now()
+	_, _, err = sendRequest("/stall-header", start.Add(long), short, long)
+	require.ErrorIs(err, os.ErrDeadlineExceeded)
+	require.Less(time.Since(start), long)
+
+	// The deadline of the caller expires before the body timeout
+	start = time.Now()
+	_, res, err := sendRequest("/stall-body", start.Add(short), long, long)
+	require.NoError(err)
+	_, err = io.ReadAll(res.Body)
+	require.ErrorIs(err, os.ErrDeadlineExceeded)
+	require.Less(time.Since(start), long)
+
+	// The body timeout expires before the deadline of the caller
+	start = time.Now()
+	_, res, err = sendRequest("/stall-body", start.Add(long), long, short)
+	require.NoError(err)
+	_, err = io.ReadAll(res.Body)
+	require.ErrorIs(err, os.ErrDeadlineExceeded)
+	require.Less(time.Since(start), long)
+
+	// The deadline of the caller still applies once the response has been read
+	start = time.Now()
+	conn, res, err := sendRequest("/", start.Add(short), long, long)
+	require.NoError(err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(err)
+	require.Equal("hello", string(body))
+	_, err = conn.Conn.Read(make([]byte, 1))
+	require.ErrorIs(err, os.ErrDeadlineExceeded)
+	require.Less(time.Since(start), long)
+}
+
+func testClientConn(t *testing.T, headerTimeout, bodyTimeout time.Duration) *ClientConn {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		serveTestClientConn(conn)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot connect: %v", err)
+	}
+
+	clientConn := ClientConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+
+		responseHeaderTimeout: headerTimeout,
+		responseBodyTimeout:   bodyTimeout,
+	}
+	t.Cleanup(clientConn.Close)
+
+	return &clientConn
+}
+
+// serveTestClientConn answers a single request, stalling until the client
+// closes the connection either before the response header, before the
+// response body, or after the complete response.
+func serveTestClientConn(conn net.Conn) {
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return
+	}
+
+	switch req.URL.Path {
+	case "/stall-header":
+	case "/stall-body":
+		io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\n")
+	default:
+		io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello")
+	}
+
+	io.Copy(io.Discard, conn)
+}
@@ -1,6 +1,10 @@
 package httputils
 
-import "net/http"
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
 
 func Header(fields ...string) http.Header {
 	header := make(http.Header)
@@ -12,3 +16,15 @@ func Header(fields ...string) http.Header {
 
 	return header
 }
+
+func AddVaryFieldName(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		names := SplitTokenList(value, true)
+		if slices.Contains(names, "*") ||
+			slices.Contains(names, strings.ToLower(name)) {
+			return
+		}
+	}
+
+	header.Add("Vary", name)
+}
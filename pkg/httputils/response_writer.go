@@ -20,6 +20,12 @@ func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
 	}
 }
 
+// Wrap replaces the underlying response writer by the one returned by fn,
+// which receives the current underlying response writer.
+func (w *ResponseWriter) Wrap(fn func(http.ResponseWriter) http.ResponseWriter) {
+	w.w = fn(w.w)
+}
+
 func (w *ResponseWriter) Header() http.Header {
 	return w.w.Header()
 }
@@ -49,3 +55,11 @@ func (w *ResponseWriter) Flush() {
 	f := w.w.(http.Flusher)
 	f.Flush()
 }
+
+// IsInformationalStatus reports whether a status is an informational (1xx)
+// status sent before the final response. 101 is excluded since it ends the
+// HTTP exchange on the connection.
+func IsInformationalStatus(status int) bool {
+	return status >= 100 && status < 200 &&
+		status != http.StatusSwitchingProtocols
+}
@@ -2,7 +2,9 @@ package netutils
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
 
 	"go.n16f.net/bcl"
 	"go.n16f.net/program"
@@ -48,24 +50,106 @@ func (cfg *TLSCfg) ReadBCLElement(block *bcl.Element) error {
 		block.EntryValues("private_key_file", &cfg.PrivateKeyFile)
 	}
 
-	var minVersion string
+	readBCLTLSParameters(block, &cfg.MinVersion, &cfg.MaxVersion,
+		&cfg.CipherSuites)
+
+	return nil
+}
+
+// TLSClientCfg is the configuration used to connect to TLS servers, e.g.
+// upstream servers of a reverse proxy.
+type TLSClientCfg struct {
+	CACertificateFile  string
+	CertificateFile    string
+	PrivateKeyFile     string
+	ServerName         string
+	InsecureSkipVerify bool
+	MinVersion         uint16
+	MaxVersion         uint16
+	CipherSuites       []uint16
+}
+
+func (cfg *TLSClientCfg) ReadBCLElement(block *bcl.Element) error {
+	block.MaybeEntryValues("ca_certificate_file", &cfg.CACertificateFile)
+
+	block.MaybeEntryValues("certificate_file", &cfg.CertificateFile)
+	block.MaybeEntryValues("private_key_file", &cfg.PrivateKeyFile)
+
+	if (cfg.CertificateFile == "") != (cfg.PrivateKeyFile == "") {
+		block.AddSimpleValidationError("client certificate file and " +
+			"private key file must be set together")
+	}
+
+	block.MaybeEntryValues("server_name", &cfg.ServerName)
+	block.MaybeEntryValues("insecure_skip_verify", &cfg.InsecureSkipVerify)
+
+	readBCLTLSParameters(block, &cfg.MinVersion, &cfg.MaxVersion,
+		&cfg.CipherSuites)
+
+	return nil
+}
+
+// TLSConfig loads certificates and returns a TLS configuration suitable for a
+// client. A nil configuration yields the default client configuration.
+func (cfg *TLSClientCfg) TLSConfig() (*tls.Config, error) {
+	var tlsCfg tls.Config
+
+	if cfg == nil {
+		return &tlsCfg, nil
+	}
+
+	if filePath := cfg.CACertificateFile; filePath != "" {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %q: %w", filePath, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("cannot load CA certificates from %q: "+
+				"no valid PEM certificate found", filePath)
+		}
+
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertificateFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertificateFile,
+			cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	tlsCfg.ServerName = cfg.ServerName
+	tlsCfg.InsecureSkipVerify = cfg.InsecureSkipVerify
+
+	tlsCfg.MinVersion = cfg.MinVersion
+	tlsCfg.MaxVersion = cfg.MaxVersion
+	tlsCfg.CipherSuites = cfg.CipherSuites
+
+	return &tlsCfg, nil
+}
+
+func readBCLTLSParameters(block *bcl.Element, minVersion, maxVersion *uint16, cipherSuites *[]uint16) {
+	var minVersionString string
 	block.MaybeEntryValues("min_version",
-		bcl.WithValueValidation(&minVersion, ValidateBCLTLSVersion))
-	cfg.MinVersion, _ = ParseTLSVersion(minVersion)
+		bcl.WithValueValidation(&minVersionString, ValidateBCLTLSVersion))
+	*minVersion, _ = ParseTLSVersion(minVersionString)
 
-	var maxVersion string
+	var maxVersionString string
 	block.MaybeEntryValues("max_version",
-		bcl.WithValueValidation(&maxVersion, ValidateBCLTLSVersion))
-	cfg.MaxVersion, _ = ParseTLSVersion(maxVersion)
+		bcl.WithValueValidation(&maxVersionString, ValidateBCLTLSVersion))
+	*maxVersion, _ = ParseTLSVersion(maxVersionString)
 
 	for _, entry := range block.FindEntries("cipher_suite") {
 		var name string
 		entry.Values(bcl.WithValueValidation(&name,
 			ValidateBCLTLSCipherSuite))
-		cfg.CipherSuites = append(cfg.CipherSuites, tlsCipherSuites[name])
+		*cipherSuites = append(*cipherSuites, tlsCipherSuites[name])
 	}
-
-	return nil
 }
 
 func (cfg *TLSCfg) SupportedTLSVersions() []uint16 {
@@ -0,0 +1,40 @@
+package netutils
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSClientCfg(t *testing.T) {
+	require := require.New(t)
+
+	var nilCfg *TLSClientCfg
+
+	tlsCfg, err := nilCfg.TLSConfig()
+	require.NoError(err)
+	require.Nil(tlsCfg.RootCAs)
+
+	dirPath := "../../test/upstream-tls"
+
+	cfg := TLSClientCfg{
+		CACertificateFile: dirPath + "/ca.pem",
+		CertificateFile:   dirPath + "/client.pem",
+		PrivateKeyFile:    dirPath + "/client.key",
+		ServerName:        "upstream.test",
+		MinVersion:        tls.VersionTLS12,
+	}
+
+	tlsCfg, err = cfg.TLSConfig()
+	require.NoError(err)
+	require.NotNil(tlsCfg.RootCAs)
+	require.Len(tlsCfg.Certificates, 1)
+	require.Equal("upstream.test", tlsCfg.ServerName)
+	require.Equal(uint16(tls.VersionTLS12), tlsCfg.MinVersion)
+
+	// A private key is not a CA certificate
+	cfg.CACertificateFile = dirPath + "/client.key"
+	_, err = cfg.TLSConfig()
+	require.Error(err)
+}
@@ -119,13 +119,16 @@ func (l *AccessLogger) formatMsgCommon(ctx *RequestContext, buf *bytes.Buffer) {
 	buf.WriteString(time.Now().Format("02/Jan/2006:15:04:05 -0700"))
 	buf.WriteByte(']')
 
+	// We log the request sent by the client, not the result of rewrites
+	req := cmp.Or(ctx.OriginalRequest, ctx.Request)
+
 	buf.WriteByte(' ')
 	buf.WriteByte('"')
-	buf.WriteString(ctx.Request.Method)
+	buf.WriteString(req.Method)
 	buf.WriteByte(' ')
-	buf.WriteString(ctx.Request.URL.Path)
+	buf.WriteString(req.URL.Path)
 	buf.WriteByte(' ')
-	buf.WriteString(ctx.Request.Proto)
+	buf.WriteString(req.Proto)
 	buf.WriteByte('"')
 
 	buf.WriteByte(' ')
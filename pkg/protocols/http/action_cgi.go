@@ -0,0 +1,437 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/boulevard/pkg/boulevard"
+	"go.n16f.net/boulevard/pkg/fastcgi"
+	"go.n16f.net/log"
+)
+
+const (
+	DefaultCGIMaxProcesses = 64
+
+	MaxCGIResponseHeaderSize = 64 * 1024
+)
+
+type CGIActionCfg struct {
+	Path          string
+	DefaultScript string
+	ScriptRegexp  string
+	Environment   map[string]string
+
+	TemporaryDirectory string
+
+	RequestBodyMemoryBufferSize *int64
+	MaxRequestBodySize          *int64
+
+	RequestTimeout *time.Duration
+	MaxProcesses   int
+}
+
+func (cfg *CGIActionCfg) ReadBCLElement(block *bcl.Element) error {
+	block.EntryValues("path", &cfg.Path)
+	block.MaybeEntryValues("default_script", &cfg.DefaultScript)
+	block.MaybeEntryValues("script_regexp", &cfg.ScriptRegexp)
+
+	cfg.Environment = make(map[string]string)
+	for _, entry := range block.FindEntries("environment") {
+		var name, value string
+		if entry.Values(&name, &value) {
+			cfg.Environment[name] = value
+		}
+	}
+
+	block.MaybeEntryValues("temporary_directory", &cfg.TemporaryDirectory)
+
+	block.MaybeEntryValues("request_body_memory_buffer_size",
+		bcl.WithValueValidation(&cfg.RequestBodyMemoryBufferSize,
+			bcl.ValidatePositiveInteger))
+	block.MaybeEntryValues("max_request_body_size",
+		bcl.WithValueValidation(&cfg.MaxRequestBodySize,
+			bcl.ValidatePositiveInteger))
+
+	block.MaybeEntryValues("request_timeout", &cfg.RequestTimeout)
+
+	cfg.MaxProcesses = DefaultCGIMaxProcesses
+	block.MaybeEntryValues("max_processes",
+		bcl.WithValueValidation(&cfg.MaxProcesses,
+			bcl.ValidatePositiveInteger))
+
+	return nil
+}
+
+type CGIAction struct {
+	Handler *Handler
+	Cfg     *CGIActionCfg
+	Log     *log.Logger
+
+	basePath string
+	scriptRE *regexp.Regexp
+
+	tmpDirPath string
+
+	reqBodyMemBufSize int64
+	maxReqBodySize    int64
+
+	requestTimeout time.Duration
+
+	processSlots chan struct{}
+}
+
+func NewCGIAction(h *Handler, cfg *CGIActionCfg) (*CGIAction, error) {
+	a := CGIAction{
+		Handler: h,
+		Cfg:     cfg,
+		Log:     h.Protocol.Log,
+
+		processSlots: make(chan struct{}, cfg.MaxProcesses),
+	}
+
+	// The script path is relative to the working directory of the command,
+	// so it must be absolute.
+	basePath, err := filepath.Abs(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve path %q: %w", cfg.Path, err)
+	}
+	a.basePath = basePath
+
+	if s := cfg.ScriptRegexp; s != "" {
+		re, err := compileCGIScriptRegexp(s)
+		if err != nil {
+			return nil, err
+		}
+
+		a.scriptRE = re
+	}
+
+	a.tmpDirPath = cfg.TemporaryDirectory
+	if a.tmpDirPath == "" {
+		dirPath, err := os.MkdirTemp("", "boulevard-cgi-*")
+		if err != nil {
+			return nil, fmt.Errorf("cannot create temporary directory: %w", err)
+		}
+
+		a.tmpDirPath = dirPath
+	}
+
+	a.reqBodyMemBufSize = DefaultRequestBodyMemoryBufferSize
+	if size := cfg.RequestBodyMemoryBufferSize; size != nil {
+		a.reqBodyMemBufSize = *size
+	}
+
+	a.maxReqBodySize = DefaultMaxRequestBodySize
+	if size := cfg.MaxRequestBodySize; size != nil {
+		a.maxReqBodySize = *size
+	}
+
+	a.requestTimeout = DefaultRequestTimeout
+	if timeout := cfg.RequestTimeout; timeout != nil {
+		a.requestTimeout = *timeout
+	}
+
+	return &a, nil
+}
+
+func (a *CGIAction) Start() error {
+	return nil
+}
+
+func (a *CGIAction) Stop() {
+	if err := os.RemoveAll(a.tmpDirPath); err != nil {
+		a.Log.Error("cannot delete directory %q: %v", a.tmpDirPath, err)
+	}
+}
+
+func (a *CGIAction) HandleRequest(ctx *RequestContext) {
+	req := ctx.Request
+
+	// The request body is streamed to the script if we know its length.
+	// Otherwise we have to buffer it to be able to set CONTENT_LENGTH.
+	var stdin io.Reader
+	var reqBodySize int64
+
+	if req.ContentLength >= 0 {
+		if req.ContentLength > a.maxReqBodySize {
+			ctx.ReplyError(413)
+			return
+		}
+
+		stdin = req.Body
+		reqBodySize = req.ContentLength
+	} else {
+		reqBodyBuf := a.newRequestBodySpillBuffer()
+		defer func() {
+			if err := reqBodyBuf.Close(); err != nil {
+				ctx.Log.Error("cannot close spill buffer: %v", err)
+			}
+		}()
+
+		size, err := io.Copy(reqBodyBuf, req.Body)
+		if err != nil {
+			if errors.Is(err, boulevard.ErrSpillBufferFull) {
+				ctx.ReplyError(413)
+				return
+			}
+
+			ctx.Log.Error("cannot copy request body: %v", err)
+			ctx.ReplyError(500)
+			return
+		}
+
+		reqBodyReader, err := reqBodyBuf.Reader()
+		if err != nil {
+			ctx.Log.Error("cannot read spill buffer: %v", err)
+			ctx.ReplyError(500)
+			return
+		}
+		defer reqBodyReader.Close()
+
+		stdin = reqBodyReader
+		reqBodySize = size
+	}
+
+	vars := cgiMetaVariables(ctx, a.scriptRE, a.Cfg.DefaultScript,
+		a.basePath, reqBodySize)
+
+	// Unlike FastCGI servers, we execute the script ourselves, so we must
+	// make sure it is located in the base directory.
+	scriptPath := path.Join(a.basePath, path.Join("/", vars["SCRIPT_NAME"]))
+	vars["SCRIPT_FILENAME"] = scriptPath
+
+	info, err := os.Stat(scriptPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			ctx.ReplyError2(404, "script not found")
+			return
+		}
+
+		ctx.Log.Error("cannot stat %q: %v", scriptPath, err)
+		ctx.ReplyError(500)
+		return
+	}
+
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		ctx.ReplyError2(403, "script access denied")
+		return
+	}
+
+	if location := a.executeScript(ctx, scriptPath, vars, stdin); location != nil {
+		a.redirectLocally(ctx, location)
+	}
+}
+
+func (a *CGIAction) executeScript(ctx *RequestContext, scriptPath string, vars map[string]string, stdin io.Reader) *url.URL {
+	select {
+	case a.processSlots <- struct{}{}:
+		defer func() { <-a.processSlots }()
+	default:
+		ctx.Log.Error("too many CGI processes running")
+		ctx.ReplyError(503)
+		return nil
+	}
+
+	timeoutCtx, cancelTimeoutCtx := context.WithTimeout(ctx.Ctx,
+		a.requestTimeout)
+	defer cancelTimeoutCtx()
+
+	cmd := exec.CommandContext(timeoutCtx, scriptPath)
+	cmd.Dir = path.Dir(scriptPath)
+	cmd.Env = a.environment(vars)
+	cmd.Stdin = stdin
+	stderr := cgiStderrWriter{log: ctx.Log}
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		ctx.Log.Error("cannot create pipe: %v", err)
+		ctx.ReplyError(500)
+		return nil
+	}
+
+	if err := cmd.Start(); err != nil {
+		ctx.Log.Error("cannot execute %q: %v", scriptPath, err)
+		ctx.ReplyError(500)
+		return nil
+	}
+
+	defer func() {
+		if err := cmd.Wait(); err != nil {
+			if timeoutCtx.Err() != nil {
+				ctx.Log.Error("CGI script %q timed out", scriptPath)
+			} else {
+				ctx.Log.Error("CGI script %q failed: %v", scriptPath, err)
+			}
+		}
+
+		stderr.Flush()
+	}()
+
+	r := bufio.NewReader(stdout)
+
+	resHeader, err := fastcgi.ReadHeader(r, MaxCGIResponseHeaderSize)
+	if err != nil {
+		// Make sure we are not going to wait for a script stuck writing to
+		// stdout.
+		cancelTimeoutCtx()
+
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			ctx.ReplyError(504)
+			return nil
+		}
+
+		ctx.Log.Error("cannot read CGI response header: %v", err)
+		ctx.ReplyError(502)
+		return nil
+	}
+
+	location := resHeader.Field("Location")
+	hasStatus := resHeader.Field("Status") != ""
+
+	// RFC 3875 6.2.2. Local Redirect Response: a local path must be processed
+	// by the server as if the client had requested it.
+	if location != "" && !hasStatus && isLocalCGILocation(location) {
+		uri, err := url.Parse(location)
+		if err != nil {
+			ctx.Log.Error("cannot parse local redirection location %q: %v",
+				location, err)
+			ctx.ReplyError(502)
+			return nil
+		}
+
+		// The script is not supposed to send a body, but we still have to
+		// read it to let the process terminate.
+		io.Copy(io.Discard, r)
+
+		return uri
+	}
+
+	header := ctx.ResponseWriter.Header()
+	resHeader.CopyToHTTPHeader(header)
+
+	// RFC 3875 6.2.3. Client Redirect Response: the server must send a 302
+	// response if the script only returns a Location header field.
+	statusCode, _ := resHeader.Status()
+	if location != "" && !hasStatus {
+		statusCode = 302
+	}
+
+	ctx.Reply(statusCode, r)
+	return nil
+}
+
+func (a *CGIAction) redirectLocally(ctx *RequestContext, uri *url.URL) {
+	// The request body was consumed by the script, so the redirected request
+	// is always a GET request without any content.
+	ctx.saveOriginalRequest()
+
+	req := ctx.Request
+
+	req.Method = "GET"
+	req.Body = http.NoBody
+	req.ContentLength = 0
+
+	req.Header.Del("Content-Length")
+	req.Header.Del("Content-Type")
+	req.Header.Del("Transfer-Encoding")
+
+	ctx.Log.Debug(1, "redirecting request locally to %q", uri.String())
+
+	ctx.reprocess(uri.Path, uri.RawQuery)
+}
+
+func (a *CGIAction) environment(vars map[string]string) []string {
+	env := make([]string, 0, len(vars)+len(a.Cfg.Environment)+1)
+
+	// Scripts usually expect PATH to be set; it can still be overridden in
+	// the configuration.
+	if value, found := os.LookupEnv("PATH"); found {
+		if _, found := a.Cfg.Environment["PATH"]; !found {
+			env = append(env, "PATH="+value)
+		}
+	}
+
+	for name, value := range vars {
+		if _, found := a.Cfg.Environment[name]; !found {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	for name, value := range a.Cfg.Environment {
+		env = append(env, name+"="+value)
+	}
+
+	return env
+}
+
+func (a *CGIAction) newRequestBodySpillBuffer() *boulevard.SpillBuffer {
+	fileName := hex.EncodeToString(boulevard.RandomBytes(16))
+	filePath := path.Join(a.tmpDirPath, fileName)
+
+	return boulevard.NewSpillBuffer(filePath, a.reqBodyMemBufSize,
+		a.maxReqBodySize)
+}
+
+// cgiStderrWriter sends each line written by a script on its standard error
+// output to the request log.
+// Scripts writing to stderr without ever writing a newline character must not
+// make us buffer an unbounded amount of data; long lines are logged in chunks.
+const cgiStderrMaxLineLength = 4096
+
+type cgiStderrWriter struct {
+	log *log.Logger
+	buf bytes.Buffer
+}
+
+func (w *cgiStderrWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+
+	for {
+		line, err := w.buf.ReadBytes('\n')
+		if err != nil {
+			// Incomplete line, keep it for later
+			w.buf.Write(line)
+			break
+		}
+
+		w.log.Error("CGI error: %s", bytes.TrimRight(line, "\r\n"))
+	}
+
+	for w.buf.Len() >= cgiStderrMaxLineLength {
+		w.log.Error("CGI error: %s", w.buf.Next(cgiStderrMaxLineLength))
+	}
+
+	return len(data), nil
+}
+
+func (w *cgiStderrWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.log.Error("CGI error: %s", w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+func isLocalCGILocation(location string) bool {
+	// RFC 3875 6.3.2. "local-Location = local-pathquery", i.e. an absolute
+	// path which is not a network-path reference.
+	return strings.HasPrefix(location, "/") &&
+		!strings.HasPrefix(location, "//")
+}
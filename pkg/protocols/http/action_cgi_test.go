@@ -0,0 +1,31 @@
+package http
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/log"
+)
+
+func TestCGIStderrWriter(t *testing.T) {
+	require := require.New(t)
+
+	w := cgiStderrWriter{log: log.DefaultLogger("cgi")}
+
+	n, err := w.Write([]byte("foo\nbar"))
+	require.NoError(err)
+	require.Equal(7, n)
+	require.Equal("bar", w.buf.String())
+
+	// Without any newline character, data are logged in chunks
+	data := bytes.Repeat([]byte("x"), cgiStderrMaxLineLength)
+	for range 10 {
+		_, err := w.Write(data)
+		require.NoError(err)
+		require.Less(w.buf.Len(), cgiStderrMaxLineLength)
+	}
+
+	w.Flush()
+	require.Equal(0, w.buf.Len())
+}
@@ -11,7 +11,6 @@ import (
 	"path"
 	"regexp"
 	"strconv"
-	"strings"
 	"time"
 
 	"go.n16f.net/bcl"
@@ -114,17 +113,9 @@ func NewFastCGIAction(h *Handler, cfg *FastCGIActionCfg) (*FastCGIAction, error)
 	}
 
 	if s := cfg.ScriptRegexp; s != "" {
-		if s[0] != '^' && s[0] != '/' {
-			return nil, fmt.Errorf("script regexp must match an absolute path")
-		}
-
-		if s[0] != '^' {
-			s = "^" + s
-		}
-
-		re, err := regexp.Compile(s)
+		re, err := compileCGIScriptRegexp(s)
 		if err != nil {
-			return nil, fmt.Errorf("cannot parse script regexp: %w", err)
+			return nil, err
 		}
 
 		a.scriptRE = re
@@ -297,81 +288,8 @@ func (a *FastCGIAction) newResponseBodySpillBuffer() *boulevard.SpillBuffer {
 }
 
 func (a *FastCGIAction) requestParameters(ctx *RequestContext, reqBodySize int64) fastcgi.NameValuePairs {
-	req := ctx.Request
-	header := req.Header
-
-	var pathInfo string
-	var scriptName string // must not start with '/'
-
-	subpath := ctx.Subpath // relative
-	if subpath == "" {
-		subpath = a.Cfg.DefaultScript
-	}
-
-	if a.scriptRE == nil {
-		scriptName = subpath
-		pathInfo = "/"
-	} else {
-		if match := a.scriptRE.FindString("/" + subpath); match == "" {
-			scriptName = subpath
-			pathInfo = "/"
-		} else {
-			scriptName = strings.TrimPrefix(match, "/")
-			pathInfo = path.Join("/", strings.TrimPrefix(subpath, match))
-		}
-	}
-
-	serverPort := ctx.Listener.Port
-
-	buildId := ctx.Protocol.Server.Cfg.BoulevardBuildId
-
-	basePath := a.Cfg.Path
-	if basePath == "" {
-		basePath = "/"
-	}
-
-	params := map[string]string{
-		// RFC 3875 4.1. Request Meta-Variables
-		"CONTENT_LENGTH":    strconv.FormatInt(reqBodySize, 10),
-		"CONTENT_TYPE":      header.Get("Content-Type"),
-		"GATEWAY_INTERFACE": "CGI/1.1",
-		"PATH_INFO":         pathInfo,
-		"PATH_TRANSLATED":   path.Join(basePath, pathInfo),
-		"QUERY_STRING":      req.URL.RawQuery,
-		"REMOTE_ADDR":       ctx.ClientAddress.String(),
-		"REMOTE_HOST":       ctx.ClientAddress.String(),  // [1]
-		"REQUEST_METHOD":    strings.ToUpper(req.Method), // [2]
-		"SCRIPT_NAME":       scriptName,
-		"SERVER_NAME":       ctx.Host,
-		"SERVER_PORT":       strconv.Itoa(serverPort),
-		"SERVER_PROTOCOL":   req.Proto,
-		"SERVER_SOFTWARE":   "boulevard/" + buildId,
-
-		// Required for php-fpm
-		"SCRIPT_FILENAME": path.Join(basePath, scriptName),
-	}
-
-	// [1] 4.1.9. REMOTE_HOST: "If the hostname is not available for performance
-	// reasons or otherwise, the server MAY substitute the REMOTE_ADDR value".
-	// Because no, we are not going to do a reverse DNS lookup for each request.
-	//
-	// [2] 4.1.12. REQUEST_METHOD: "The method is case sensitive".
-
-	if scheme, _, ok := strings.Cut(header.Get("Authorization"), " "); ok {
-		params["AUTH_TYPE"] = scheme
-	}
-
-	if username, _, ok := req.BasicAuth(); ok {
-		params["REMOTE_USER"] = username
-	}
-
-	// RFC 3875 4.1.18. Protocol-Specific Meta-Variables
-	for name, values := range header {
-		name = "HTTP_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
-		value := strings.Join(values, ", ")
-
-		params[name] = value
-	}
+	params := cgiMetaVariables(ctx, a.scriptRE, a.Cfg.DefaultScript,
+		a.Cfg.Path, reqBodySize)
 
 	for name, value := range a.Cfg.Parameters {
 		params[name] = value
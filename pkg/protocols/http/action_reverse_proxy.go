@@ -2,15 +2,12 @@ package http
 
 import (
 	"context"
-	"crypto/tls"
-	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
-	"net/url"
 	"slices"
 	"strings"
+	"sync"
 
 	"go.n16f.net/bcl"
 	"go.n16f.net/boulevard/pkg/boulevard"
@@ -23,8 +20,19 @@ type ReverseProxyActionCfg struct {
 	URI              string
 	LoadBalancerName string
 
+	UpstreamTLS    *netutils.TLSClientCfg
+	ClientSettings httputils.ClientSettingsCfg
+
+	// One or the other
+	StripPrefix string
+	Path        *boulevard.FormatString
+
 	RequestHeader  HeaderOps
 	ResponseHeader HeaderOps
+
+	Cache  *ResponseCacheCfg
+	Mirror *ReverseProxyMirrorCfg
+	Retry  *ReverseProxyRetryCfg
 }
 
 func (cfg *ReverseProxyActionCfg) ReadBCLElement(elt *bcl.Element) error {
@@ -34,8 +42,20 @@ func (cfg *ReverseProxyActionCfg) ReadBCLElement(elt *bcl.Element) error {
 			bcl.WithValueValidation(&cfg.URI, httputils.ValidateBCLHTTPURI))
 		elt.MaybeEntryValues("load_balancer", &cfg.LoadBalancerName)
 
+		elt.MaybeBlock("upstream_tls", &cfg.UpstreamTLS)
+		cfg.ClientSettings.ReadBCLElement(elt)
+
+		elt.CheckElementsMaybeOneOf("strip_prefix", "path")
+		elt.MaybeEntryValues("strip_prefix",
+			bcl.WithValueValidation(&cfg.StripPrefix, validateBCLStripPrefix))
+		elt.MaybeEntryValues("path", &cfg.Path)
+
 		elt.MaybeBlock("request_header", &cfg.RequestHeader)
 		elt.MaybeBlock("response_header", &cfg.ResponseHeader)
+
+		elt.MaybeElement("cache", &cfg.Cache)
+		elt.MaybeBlock("mirror", &cfg.Mirror)
+		elt.MaybeBlock("retry", &cfg.Retry)
 	} else {
 		elt.Values(
 			bcl.WithValueValidation(&cfg.URI, httputils.ValidateBCLHTTPURI))
@@ -48,155 +68,109 @@ type ReverseProxyAction struct {
 	Handler *Handler
 	Cfg     *ReverseProxyActionCfg
 
-	// Single upstream server
-	uri    *url.URL
-	client *httputils.Client
+	primary *reverseProxyUpstream
+	mirror  *reverseProxyMirror
+	retry   *reverseProxyRetry
 
-	// Load balancer
-	loadBalancer *boulevard.LoadBalancer
-	clients      map[string]*httputils.Client // address -> client
+	cache *ResponseCache
+	wg    sync.WaitGroup
 }
 
 func NewReverseProxyAction(h *Handler, cfg *ReverseProxyActionCfg) (*ReverseProxyAction, error) {
-	tlsCfg := tls.Config{}
-
 	a := ReverseProxyAction{
 		Handler: h,
 		Cfg:     cfg,
 	}
 
-	if cfg.URI != "" {
-		// URI
-		uri, err := url.Parse(cfg.URI)
-		if err != nil {
-			return nil, fmt.Errorf("cannot parse URI: %w", err)
-		}
-		if uri.Scheme == "" {
-			uri.Scheme = "http"
-		}
-		if uri.Host == "" {
-			uri.Host = "localhost"
-		}
-		uri.Path = ""
-		uri.Fragment = ""
-
-		port := uri.Port()
-		if port == "" {
-			if strings.ToLower(uri.Scheme) == "http" {
-				port = "80"
-			} else {
-				port = "443"
-			}
-		}
-		address := net.JoinHostPort(uri.Hostname(), port)
-
-		clientCfg := httputils.ClientCfg{
-			Scheme:  uri.Scheme,
-			Address: address,
+	primary, err := newReverseProxyUpstream(h, cfg.URI, cfg.LoadBalancerName,
+		cfg.UpstreamTLS, cfg.ClientSettings)
+	if err != nil {
+		return nil, err
+	}
 
-			TLS: &tlsCfg,
-		}
+	a.primary = primary
 
-		client, err := httputils.NewClient(clientCfg)
+	if mirrorCfg := cfg.Mirror; mirrorCfg != nil {
+		mirror, err := newReverseProxyMirror(h, mirrorCfg)
 		if err != nil {
-			return nil, fmt.Errorf("cannot create client: %w", err)
-		}
-
-		a.uri = uri
-		a.client = client
-	} else {
-		// Load balancer
-		serverCfg := h.Protocol.Server.Cfg
-
-		lb := serverCfg.LoadBalancers[cfg.LoadBalancerName]
-		if lb == nil {
-			return nil, fmt.Errorf("unknown load balancer %q",
-				cfg.LoadBalancerName)
+			primary.stop()
+			return nil, fmt.Errorf("cannot create mirror: %w", err)
 		}
 
-		a.clients = make(map[string]*httputils.Client)
-		var startedClients []string
-
-		for _, srv := range lb.Servers {
-			address := srv.Address.String()
-
-			clientCfg := httputils.ClientCfg{
-				Scheme:  "http",
-				Address: address,
-
-				TLS: &tlsCfg,
-			}
-
-			client, err := httputils.NewClient(clientCfg)
-			if err != nil {
-				for _, addr := range startedClients {
-					a.clients[addr].Stop()
-				}
+		a.mirror = mirror
+	}
 
-				return nil, fmt.Errorf("cannot create client: %w", err)
+	if retryCfg := cfg.Retry; retryCfg != nil {
+		retry, err := newReverseProxyRetry(retryCfg)
+		if err != nil {
+			if a.mirror != nil {
+				a.mirror.stop()
 			}
-
-			a.clients[address] = client
-			startedClients = append(startedClients, address)
+			primary.stop()
+			return nil, fmt.Errorf("cannot create retry policy: %w", err)
 		}
 
-		a.loadBalancer = lb
+		a.retry = retry
 	}
 
 	return &a, nil
 }
 
 func (a *ReverseProxyAction) Start() error {
+	if cfg := a.Cfg.Cache; cfg != nil && cfg.Enabled {
+		cache, err := NewResponseCache(cfg, a.Handler.Protocol.Log)
+		if err != nil {
+			return fmt.Errorf("cannot create response cache: %w", err)
+		}
+
+		a.cache = cache
+	}
+
 	return nil
 }
 
 func (a *ReverseProxyAction) Stop() {
-	if a.client != nil {
-		a.client.Stop()
-	} else {
-		for _, client := range a.clients {
-			client.Stop()
-		}
+	a.wg.Wait()
+
+	if a.mirror != nil {
+		a.mirror.stop()
 	}
-}
 
-func (a *ReverseProxyAction) HandleRequest(ctx *RequestContext) {
-	var client *httputils.Client
-	var scheme, address string
-
-	if a.client != nil {
-		// Single upstream server
-		client = a.client
-		scheme = a.uri.Scheme
-		address = a.uri.Host
-	} else {
-		// Load balancer
-		address = a.loadBalancer.Address()
-		if address == "" {
-			ctx.Log.Error("no available upstream server found")
-			ctx.ReplyError(503)
-			return
-		}
+	a.primary.stop()
 
-		client = a.clients[address]
-		scheme = "http"
+	if a.retry != nil {
+		a.retry.stop()
 	}
 
-	req := a.rewriteRequest(ctx, scheme, address)
-	a.maybeSetConnectionUpgrade(ctx, req)
+	if a.cache != nil {
+		a.cache.Close()
+	}
+}
 
-	var hijack bool
+func (a *ReverseProxyAction) HandleRequest(ctx *RequestContext) {
+	var reqBodyBuf *boulevard.SpillBuffer
 
-	conn, err := client.AcquireConn()
-	if err != nil {
-		ctx.Log.Error("cannot acquire upstream connection: %v", err)
+	if a.mirror != nil && len(ctx.UpgradeProtocols) == 0 {
+		var release func()
+		reqBodyBuf, release = a.mirrorRequest(ctx)
+		defer release()
+	}
 
-		status := 500
-		if errors.Is(err, httputils.ErrNoConnectionAvailable) {
-			status = 503
+	if a.cache != nil && len(ctx.UpgradeProtocols) == 0 {
+		method := ctx.Request.Method
+		if method == "GET" || method == "HEAD" {
+			a.handleCachedRequest(ctx, reqBodyBuf)
+			return
 		}
+	}
 
-		ctx.ReplyError(status)
+	var hijack bool
+
+	client, conn, res := a.sendRequest(ctx, reqBodyBuf,
+		func(req *http.Request) {
+			a.maybeSetConnectionUpgrade(ctx, req)
+		})
+	if res == nil {
 		return
 	}
 	defer func() {
@@ -206,17 +180,9 @@ func (a *ReverseProxyAction) HandleRequest(ctx *RequestContext) {
 			client.ReleaseConn(conn)
 		}
 	}()
-
-	res, err := conn.SendRequest(req)
-	if err != nil {
-		ctx.Log.Error("cannot send request upstream: %v", err)
-		ctx.ReplyError(500)
-		conn.Close()
-		return
-	}
 	defer res.Body.Close()
 
-	a.initResponseHeader(ctx, res)
+	a.initResponseHeader(ctx, res.Header)
 	ctx.Reply(res.StatusCode, nil)
 
 	if a.isConnectionUpgraded(ctx, res) {
@@ -242,11 +208,19 @@ func (a *ReverseProxyAction) HandleRequest(ctx *RequestContext) {
 			} else {
 				ctx.Log.Error("cannot copy response body: %v", err)
 			}
+
+			// The rest of the response body is still pending, the
+			// connection cannot be reused.
+			conn.Close()
 			return
 		}
 	}
 }
 
+func (a *ReverseProxyAction) upstream() (*httputils.Client, string, string) {
+	return a.primary.pick()
+}
+
 func (a *ReverseProxyAction) rewriteRequest(ctx *RequestContext, scheme, address string) *http.Request {
 	req := ctx.Request.Clone(context.Background())
 	header := req.Header
@@ -254,6 +228,7 @@ func (a *ReverseProxyAction) rewriteRequest(ctx *RequestContext, scheme, address
 	// Rewrite the URI to target the upstream server
 	req.URL.Scheme = scheme
 	req.URL.Host = address
+	a.rewriteRequestPath(ctx, req, a.primary.basePath)
 
 	a.initRequestHeader(ctx, header)
 
@@ -351,15 +326,19 @@ func (a *ReverseProxyAction) isConnectionUpgraded(ctx *RequestContext, res *http
 	return true
 }
 
-func (a *ReverseProxyAction) initResponseHeader(ctx *RequestContext, res *http.Response) {
+func (a *ReverseProxyAction) initResponseHeader(ctx *RequestContext, resHeader http.Header) {
 	header := ctx.ResponseWriter.Header()
 
-	for name, fields := range res.Header {
+	for name, fields := range resHeader {
 		for _, field := range fields {
 			header.Add(name, field)
 		}
 	}
 
+	if a.rewritesPath() {
+		a.rewriteResponseHeader(ctx, header)
+	}
+
 	a.Cfg.ResponseHeader.Apply(header, ctx.Vars)
 }
 
@@ -0,0 +1,291 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.n16f.net/boulevard/pkg/boulevard"
+	"go.n16f.net/boulevard/pkg/httputils"
+	"go.n16f.net/boulevard/pkg/netutils"
+	"go.n16f.net/log"
+)
+
+func (a *ReverseProxyAction) handleCachedRequest(ctx *RequestContext, reqBodyBuf *boulevard.SpillBuffer) {
+	key := a.cache.Key(ctx)
+	reqHeader := ctx.Request.Header
+
+	entry, body, err := a.cache.Lookup(key, reqHeader)
+	if err != nil {
+		ctx.Log.Error("cannot read cache entry: %v", err)
+	}
+	if body != nil {
+		defer body.Close()
+	}
+
+	if entry != nil && !isRevalidationRequested(reqHeader) {
+		now := time.Now()
+
+		if entry.IsFresh(now) {
+			a.serveCacheEntry(ctx, entry, body, "hit")
+			return
+		}
+
+		if entry.CanServeStale(now) {
+			a.revalidateCacheEntry(ctx, entry)
+			a.serveCacheEntry(ctx, entry, body, "stale")
+			return
+		}
+	}
+
+	validation := entry != nil && entry.HasValidators()
+
+	reqTime := time.Now()
+
+	client, conn, res := a.sendRequest(ctx, reqBodyBuf,
+		func(req *http.Request) {
+			if validation {
+				entry.SetConditionalRequestHeader(req.Header)
+			}
+		})
+	if res == nil {
+		return
+	}
+	defer client.ReleaseConn(conn)
+	defer res.Body.Close()
+
+	resTime := time.Now()
+
+	req := res.Request
+
+	if validation && res.StatusCode == 304 {
+		// If the entry was evicted, we can still serve the body we opened
+		// with the previous header.
+		newEntry := a.cache.Refresh(entry, res, reqTime, resTime)
+		if newEntry != nil {
+			entry = newEntry
+		}
+
+		a.serveCacheEntry(ctx, entry, body, "revalidated")
+		return
+	}
+
+	ctx.Vars["http.response.cache_status"] = "miss"
+
+	a.initResponseHeader(ctx, res.Header)
+	ctx.Reply(res.StatusCode, nil)
+
+	storable := isResponseStorable(req, res)
+
+	// The new response supersedes the stored one
+	if entry != nil && !storable && req.Method == "GET" {
+		a.cache.Delete(entry)
+	}
+
+	w := responseCacheWriter{
+		w:   ctx.ResponseWriter,
+		log: ctx.Log,
+	}
+
+	if storable {
+		w.buf = a.cache.NewBodyBuffer()
+	}
+
+	if _, err := io.Copy(&w, res.Body); err != nil {
+		if netutils.IsSilentIOError(err) {
+			ctx.Log.Debug(1, "cannot copy response body: %v", err)
+		} else {
+			ctx.Log.Error("cannot copy response body: %v", err)
+		}
+
+		// The rest of the response body is still pending, the connection
+		// cannot be reused.
+		conn.Close()
+
+		w.discardBuffer()
+		return
+	}
+
+	if w.buf != nil {
+		entry := NewResponseCacheEntry(key, reqHeader, res, w.buf,
+			reqTime, resTime)
+		a.cache.Store(entry)
+	}
+}
+
+func (a *ReverseProxyAction) serveCacheEntry(ctx *RequestContext, entry *ResponseCacheEntry, body io.Reader, cacheStatus string) {
+	ctx.Vars["http.response.cache_status"] = cacheStatus
+
+	a.initResponseHeader(ctx, entry.Header)
+
+	header := ctx.ResponseWriter.Header()
+
+	age := int64(entry.Age(time.Now()).Seconds())
+	header.Set("Age", strconv.FormatInt(age, 10))
+
+	if entry.IsNotModified(ctx.Request.Header) {
+		header.Del("Content-Length")
+		ctx.Reply(304, nil)
+		return
+	}
+
+	if entry.Status != 204 {
+		size := entry.Body.Size()
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+
+	if ctx.Request.Method == "HEAD" {
+		ctx.Reply(entry.Status, nil)
+		return
+	}
+
+	ctx.Reply(entry.Status, body)
+}
+
+func (a *ReverseProxyAction) revalidateCacheEntry(ctx *RequestContext, entry *ResponseCacheEntry) {
+	// RFC 5861 3. The stale-while-revalidate Cache-Control Extension: the
+	// stale response is served while we revalidate it in the background. We
+	// only run one revalidation at a time for each entry.
+
+	if !entry.revalidating.CompareAndSwap(false, true) {
+		return
+	}
+
+	client, scheme, address := a.upstream()
+	if client == nil {
+		ctx.Log.Error("no available upstream server found")
+		entry.revalidating.Store(false)
+		return
+	}
+
+	// The request context will not be valid anymore once the response has been
+	// sent, so we prepare everything we need beforehand.
+	req := a.rewriteRequest(ctx, scheme, address)
+	req.Method = "GET"
+	req.Body = http.NoBody
+	req.ContentLength = 0
+
+	if entry.HasValidators() {
+		entry.SetConditionalRequestHeader(req.Header)
+	}
+
+	reqHeader := ctx.Request.Header.Clone()
+	logger := ctx.Log
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer entry.revalidating.Store(false)
+
+		err := a.refreshCacheEntry(entry, client, req, reqHeader)
+		if err != nil {
+			logger.Error("cannot revalidate cache entry: %v", err)
+		}
+	}()
+}
+
+func (a *ReverseProxyAction) refreshCacheEntry(entry *ResponseCacheEntry, client *httputils.Client, req *http.Request, reqHeader http.Header) error {
+	conn, err := client.AcquireConn()
+	if err != nil {
+		return fmt.Errorf("cannot acquire upstream connection: %w", err)
+	}
+	defer client.ReleaseConn(conn)
+
+	reqTime := time.Now()
+
+	res, err := conn.SendRequest(req)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("cannot send request upstream: %w", err)
+	}
+	defer res.Body.Close()
+
+	resTime := time.Now()
+
+	if res.StatusCode == 304 {
+		a.cache.Refresh(entry, res, reqTime, resTime)
+		return nil
+	}
+
+	if !isResponseStorable(req, res) {
+		a.cache.Delete(entry)
+		return nil
+	}
+
+	buf := a.cache.NewBodyBuffer()
+
+	if _, err := io.Copy(buf, res.Body); err != nil {
+		buf.Close()
+		a.cache.Delete(entry)
+
+		if errors.Is(err, boulevard.ErrSpillBufferFull) {
+			return nil
+		}
+
+		return fmt.Errorf("cannot read response body: %w", err)
+	}
+
+	newEntry := NewResponseCacheEntry(entry.Key, reqHeader, res, buf,
+		reqTime, resTime)
+	a.cache.Store(newEntry)
+
+	return nil
+}
+
+func isRevalidationRequested(header http.Header) bool {
+	// RFC 9111 5.2.1.4. no-cache and 5.2.1.1. max-age
+	cc := httputils.ParseCacheControl(header)
+
+	if cc.Has("no-cache") {
+		return true
+	}
+
+	if maxAge, ok := cc.Duration("max-age"); ok && maxAge == 0 {
+		return true
+	}
+
+	return false
+}
+
+// responseCacheWriter copies the response body to the client and, if the
+// response can be stored, to a buffer. If the response is too large, it stops
+// buffering but keeps copying data to the client.
+type responseCacheWriter struct {
+	w   io.Writer
+	buf *boulevard.SpillBuffer
+	log *log.Logger
+}
+
+func (w *responseCacheWriter) Write(data []byte) (int, error) {
+	n, err := w.w.Write(data)
+	if err != nil {
+		return n, err
+	}
+
+	if w.buf != nil {
+		if _, err := w.buf.Write(data); err != nil {
+			if !errors.Is(err, boulevard.ErrSpillBufferFull) {
+				w.log.Error("cannot buffer response body: %v", err)
+			}
+
+			w.discardBuffer()
+		}
+	}
+
+	return n, nil
+}
+
+func (w *responseCacheWriter) discardBuffer() {
+	if w.buf == nil {
+		return
+	}
+
+	if err := w.buf.Close(); err != nil {
+		w.log.Error("cannot close spill buffer: %v", err)
+	}
+
+	w.buf = nil
+}
@@ -0,0 +1,364 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/boulevard/pkg/boulevard"
+	"go.n16f.net/boulevard/pkg/httputils"
+	"go.n16f.net/boulevard/pkg/netutils"
+	"go.n16f.net/log"
+)
+
+const (
+	DefaultReverseProxyMirrorTimeout            = 10 * time.Second
+	DefaultReverseProxyMirrorMaxPendingRequests = 100
+)
+
+type ReverseProxyMirrorCfg struct {
+	Upstreams   []ReverseProxyMirrorUpstreamCfg
+	UpstreamTLS *netutils.TLSClientCfg
+
+	Percentage         float64
+	Timeout            *time.Duration
+	MaxPendingRequests int
+
+	TemporaryDirectory string
+
+	RequestBodyMemoryBufferSize *int64
+	MaxRequestBodySize          *int64
+}
+
+type ReverseProxyMirrorUpstreamCfg struct {
+	// One or the other
+	URI              string
+	LoadBalancerName string
+}
+
+func (cfg *ReverseProxyMirrorCfg) ReadBCLElement(block *bcl.Element) error {
+	for _, entry := range block.FindEntries("uri") {
+		var upstream ReverseProxyMirrorUpstreamCfg
+		if entry.Values(bcl.WithValueValidation(&upstream.URI,
+			httputils.ValidateBCLHTTPURI)) {
+			cfg.Upstreams = append(cfg.Upstreams, upstream)
+		}
+	}
+
+	for _, entry := range block.FindEntries("load_balancer") {
+		var upstream ReverseProxyMirrorUpstreamCfg
+		if entry.Values(&upstream.LoadBalancerName) {
+			cfg.Upstreams = append(cfg.Upstreams, upstream)
+		}
+	}
+
+	if len(cfg.Upstreams) == 0 {
+		block.AddSimpleValidationError("mirror block must contain at least " +
+			"one uri or load_balancer entry")
+	}
+
+	block.MaybeBlock("upstream_tls", &cfg.UpstreamTLS)
+
+	cfg.Percentage = 100.0
+	block.MaybeEntryValues("percentage",
+		bcl.WithValueValidation(&cfg.Percentage,
+			boulevard.ValidateBCLPercentage))
+
+	block.MaybeEntryValues("timeout", &cfg.Timeout)
+
+	cfg.MaxPendingRequests = DefaultReverseProxyMirrorMaxPendingRequests
+	block.MaybeEntryValues("max_pending_requests",
+		bcl.WithValueValidation(&cfg.MaxPendingRequests,
+			bcl.ValidatePositiveInteger))
+
+	block.MaybeEntryValues("temporary_directory", &cfg.TemporaryDirectory)
+
+	block.MaybeEntryValues("request_body_memory_buffer_size",
+		bcl.WithValueValidation(&cfg.RequestBodyMemoryBufferSize,
+			bcl.ValidatePositiveInteger))
+	block.MaybeEntryValues("max_request_body_size",
+		bcl.WithValueValidation(&cfg.MaxRequestBodySize,
+			bcl.ValidatePositiveInteger))
+
+	return nil
+}
+
+// reverseProxyMirror sends a copy of requests to other upstreams. Responses
+// are discarded; mirrored requests are sent in the background so that they
+// never affect the response sent to the client.
+type reverseProxyMirror struct {
+	Cfg *ReverseProxyMirrorCfg
+
+	upstreams []*reverseProxyUpstream
+
+	tmpDirPath string
+
+	reqBodyMemBufSize int64
+	maxReqBodySize    int64
+
+	timeout time.Duration
+
+	nbPendingRequests atomic.Int64
+	wg                sync.WaitGroup
+}
+
+func newReverseProxyMirror(h *Handler, cfg *ReverseProxyMirrorCfg) (*reverseProxyMirror, error) {
+	m := reverseProxyMirror{
+		Cfg: cfg,
+	}
+
+	for _, upstreamCfg := range cfg.Upstreams {
+		upstream, err := newReverseProxyUpstream(h, upstreamCfg.URI,
+			upstreamCfg.LoadBalancerName, cfg.UpstreamTLS,
+			httputils.ClientSettingsCfg{})
+		if err != nil {
+			m.stop()
+			return nil, err
+		}
+
+		m.upstreams = append(m.upstreams, upstream)
+	}
+
+	m.tmpDirPath = cfg.TemporaryDirectory
+	if m.tmpDirPath == "" {
+		dirPath, err := os.MkdirTemp("", "boulevard-mirror-*")
+		if err != nil {
+			m.stop()
+			return nil, fmt.Errorf("cannot create temporary directory: %w", err)
+		}
+
+		m.tmpDirPath = dirPath
+	}
+
+	m.reqBodyMemBufSize = DefaultRequestBodyMemoryBufferSize
+	if size := cfg.RequestBodyMemoryBufferSize; size != nil {
+		m.reqBodyMemBufSize = *size
+	}
+
+	m.maxReqBodySize = DefaultMaxRequestBodySize
+	if size := cfg.MaxRequestBodySize; size != nil {
+		m.maxReqBodySize = *size
+	}
+
+	m.timeout = DefaultReverseProxyMirrorTimeout
+	if timeout := cfg.Timeout; timeout != nil {
+		m.timeout = *timeout
+	}
+
+	return &m, nil
+}
+
+func (m *reverseProxyMirror) stop() {
+	m.wg.Wait()
+
+	for _, upstream := range m.upstreams {
+		upstream.stop()
+	}
+
+	if m.tmpDirPath != "" && m.Cfg.TemporaryDirectory == "" {
+		os.RemoveAll(m.tmpDirPath)
+	}
+}
+
+// mirrorRequest buffers the request body and sends a copy of the request to
+// each mirror upstream. It returns the buffer containing the request body if
+// there is one, and a function which must be called once the request has been
+// processed.
+func (a *ReverseProxyAction) mirrorRequest(ctx *RequestContext) (*boulevard.SpillBuffer, func()) {
+	m := a.mirror
+
+	if m.Cfg.Percentage < 100.0 && rand.Float64()*100.0 >= m.Cfg.Percentage {
+		return nil, func() {}
+	}
+
+	// Slots are reserved for each mirrored request in sendMirrorRequests;
+	// this is only a way to avoid buffering the request body for nothing.
+	if m.nbPendingRequests.Load() >= int64(m.Cfg.MaxPendingRequests) {
+		ctx.Log.Debug(1, "too many pending mirror requests, skipping mirror")
+		return nil, func() {}
+	}
+
+	req := ctx.Request
+
+	if req.ContentLength == 0 {
+		a.sendMirrorRequests(ctx, nil)
+		return nil, func() {}
+	}
+
+	reqBodyBuf, body, err := a.bufferMirroredRequestBody(ctx)
+	if err != nil {
+		// We cannot recover if we fail to read the body: the primary request
+		// will fail with the same error, and report it.
+		ctx.Log.Debug(1, "cannot buffer request body: %v", err)
+		req.Body = io.NopCloser(&errorReader{err: err})
+		return nil, func() {}
+	}
+
+	release := func() {
+		// The server closes the original request body, but not the one we
+		// have substituted.
+		body.Close()
+
+		if err := reqBodyBuf.Close(); err != nil {
+			ctx.Log.Error("cannot close spill buffer: %v", err)
+		}
+	}
+
+	// If the body was too large to be mirrored, the primary request must
+	// still get all of it.
+	var extra [1]byte
+	if n, _ := io.ReadFull(req.Body, extra[:]); n > 0 {
+		ctx.Log.Debug(1, "request body too large, skipping mirror")
+
+		req.Body = io.NopCloser(io.MultiReader(body,
+			bytes.NewReader(extra[:n]), req.Body))
+		return nil, release
+	}
+
+	req.Body = body
+	req.ContentLength = reqBodyBuf.Size()
+	req.TransferEncoding = nil
+
+	a.sendMirrorRequests(ctx, reqBodyBuf)
+
+	return reqBodyBuf, release
+}
+
+func (a *ReverseProxyAction) bufferMirroredRequestBody(ctx *RequestContext) (*boulevard.SpillBuffer, io.ReadCloser, error) {
+	m := a.mirror
+
+	fileName := hex.EncodeToString(boulevard.RandomBytes(16))
+	filePath := path.Join(m.tmpDirPath, fileName)
+
+	buf := boulevard.NewSpillBuffer(filePath, m.reqBodyMemBufSize,
+		m.maxReqBodySize)
+
+	_, err := io.Copy(buf, io.LimitReader(ctx.Request.Body, m.maxReqBodySize))
+	if err == nil {
+		var body io.ReadCloser
+		if body, err = buf.Reader(); err == nil {
+			return buf, body, nil
+		}
+	}
+
+	if err := buf.Close(); err != nil {
+		ctx.Log.Error("cannot close spill buffer: %v", err)
+	}
+
+	return nil, nil, err
+}
+
+func (a *ReverseProxyAction) sendMirrorRequests(ctx *RequestContext, reqBodyBuf *boulevard.SpillBuffer) {
+	m := a.mirror
+
+	for _, upstream := range m.upstreams {
+		client, scheme, address := upstream.pick()
+		if client == nil {
+			ctx.Log.Debug(1, "no available mirror server found")
+			continue
+		}
+
+		if !m.reservePendingRequest() {
+			ctx.Log.Debug(1, "too many pending mirror requests, skipping mirror")
+			continue
+		}
+
+		// As for cache revalidation, the request context will not be valid
+		// anymore once the response has been sent, so the mirrored request is
+		// entirely prepared beforehand.
+		req := ctx.Request.Clone(context.Background())
+		req.URL.Scheme = scheme
+		req.URL.Host = address
+		a.rewriteRequestPath(ctx, req, upstream.basePath)
+		a.initRequestHeader(ctx, req.Header)
+
+		req.Body = http.NoBody
+
+		if reqBodyBuf != nil {
+			// Readers remain usable after the spill buffer has been closed
+			body, err := reqBodyBuf.Reader()
+			if err != nil {
+				ctx.Log.Error("cannot read spill buffer: %v", err)
+				m.nbPendingRequests.Add(-1)
+				continue
+			}
+
+			req.Body = body
+		}
+
+		logger := ctx.Log
+
+		m.wg.Add(1)
+
+		go func() {
+			defer m.wg.Done()
+			defer m.nbPendingRequests.Add(-1)
+
+			m.sendRequest(client, req, logger)
+		}()
+	}
+}
+
+func (m *reverseProxyMirror) reservePendingRequest() bool {
+	maxPendingRequests := int64(m.Cfg.MaxPendingRequests)
+
+	for {
+		n := m.nbPendingRequests.Load()
+		if n >= maxPendingRequests {
+			return false
+		}
+
+		if m.nbPendingRequests.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// Failures of mirror servers do not affect clients, and a mirror server being
+// down would flood the log at the error level.
+func (m *reverseProxyMirror) sendRequest(client *httputils.Client, req *http.Request, logger *log.Logger) {
+	defer req.Body.Close()
+
+	conn, err := client.AcquireConn()
+	if err != nil {
+		logger.Info("cannot acquire mirror connection: %v", err)
+		return
+	}
+	defer client.ReleaseConn(conn)
+
+	conn.SetDeadline(time.Now().Add(m.timeout))
+
+	res, err := conn.SendRequest(req)
+	if err != nil {
+		logger.Info("cannot send mirror request: %v", err)
+		conn.Close()
+		return
+	}
+	defer res.Body.Close()
+
+	if _, err := io.Copy(io.Discard, res.Body); err != nil {
+		logger.Info("cannot read mirror response body: %v", err)
+		conn.Close()
+		return
+	}
+
+	conn.SetDeadline(time.Time{})
+}
+
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read(data []byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseProxyMirrorPendingRequests(t *testing.T) {
+	require := require.New(t)
+
+	m := reverseProxyMirror{
+		Cfg: &ReverseProxyMirrorCfg{MaxPendingRequests: 10},
+	}
+
+	var nbReserved atomic.Int64
+	var wg sync.WaitGroup
+
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if m.reservePendingRequest() {
+				nbReserved.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.Equal(int64(10), nbReserved.Load())
+	require.Equal(int64(10), m.nbPendingRequests.Load())
+
+	m.nbPendingRequests.Add(-1)
+	require.True(m.reservePendingRequest())
+	require.False(m.reservePendingRequest())
+}
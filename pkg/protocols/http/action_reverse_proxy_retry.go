@@ -0,0 +1,410 @@
+package http
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"time"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/boulevard/pkg/boulevard"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+const (
+	ReverseProxyRetryConditionConnectError = "connect_error"
+	ReverseProxyRetryConditionReset        = "reset"
+	ReverseProxyRetryConditionStatus       = "status"
+
+	DefaultReverseProxyRetryAttempts   = 2
+	DefaultReverseProxyRetryBackoff    = 100 * time.Millisecond
+	DefaultReverseProxyRetryMaxBackoff = 2 * time.Second
+)
+
+// RFC 9110 9.2.2. Idempotent Methods
+var idempotentHTTPMethods = []string{
+	"GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE",
+}
+
+type ReverseProxyRetryCfg struct {
+	Attempts   int
+	Conditions []string
+	Statuses   []int
+
+	TryTimeout *time.Duration
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	TemporaryDirectory string
+
+	RequestBodyMemoryBufferSize *int64
+	MaxRequestBodySize          *int64
+}
+
+func (cfg *ReverseProxyRetryCfg) ReadBCLElement(block *bcl.Element) error {
+	cfg.Attempts = DefaultReverseProxyRetryAttempts
+	block.MaybeEntryValues("attempts",
+		bcl.WithValueValidation(&cfg.Attempts, bcl.ValidatePositiveInteger))
+
+	for _, entry := range block.FindEntries("on") {
+		if !entry.CheckValueOneOf(0, ReverseProxyRetryConditionConnectError,
+			ReverseProxyRetryConditionReset,
+			ReverseProxyRetryConditionStatus) {
+			continue
+		}
+
+		var condition string
+		entry.Value(0, &condition)
+
+		if condition == ReverseProxyRetryConditionStatus {
+			for i := 1; i < entry.NbValues(); i++ {
+				var status int
+				if entry.Value(i, &status) {
+					if status < 500 || status > 599 {
+						entry.AddSimpleValidationError("invalid status %d: "+
+							"only 5xx statuses can be retried", status)
+						continue
+					}
+
+					cfg.Statuses = append(cfg.Statuses, status)
+				}
+			}
+
+			if len(cfg.Statuses) == 0 {
+				entry.AddSimpleValidationError("missing status")
+			}
+		} else if !entry.CheckNbValues(1) {
+			continue
+		}
+
+		cfg.Conditions = append(cfg.Conditions, condition)
+	}
+
+	if len(cfg.Conditions) == 0 {
+		cfg.Conditions = []string{
+			ReverseProxyRetryConditionConnectError,
+			ReverseProxyRetryConditionReset,
+		}
+	}
+
+	block.MaybeEntryValues("try_timeout", &cfg.TryTimeout)
+
+	cfg.Backoff = DefaultReverseProxyRetryBackoff
+	block.MaybeEntryValues("backoff", &cfg.Backoff)
+
+	cfg.MaxBackoff = DefaultReverseProxyRetryMaxBackoff
+	block.MaybeEntryValues("max_backoff", &cfg.MaxBackoff)
+
+	block.MaybeEntryValues("temporary_directory", &cfg.TemporaryDirectory)
+
+	block.MaybeEntryValues("request_body_memory_buffer_size",
+		bcl.WithValueValidation(&cfg.RequestBodyMemoryBufferSize,
+			bcl.ValidatePositiveInteger))
+	block.MaybeEntryValues("max_request_body_size",
+		bcl.WithValueValidation(&cfg.MaxRequestBodySize,
+			bcl.ValidatePositiveInteger))
+
+	return nil
+}
+
+func (cfg *ReverseProxyRetryCfg) hasCondition(condition string) bool {
+	return slices.Contains(cfg.Conditions, condition)
+}
+
+type reverseProxyRetry struct {
+	Cfg *ReverseProxyRetryCfg
+
+	tmpDirPath string
+
+	reqBodyMemBufSize int64
+	maxReqBodySize    int64
+}
+
+func newReverseProxyRetry(cfg *ReverseProxyRetryCfg) (*reverseProxyRetry, error) {
+	r := reverseProxyRetry{
+		Cfg: cfg,
+	}
+
+	r.tmpDirPath = cfg.TemporaryDirectory
+	if r.tmpDirPath == "" {
+		dirPath, err := os.MkdirTemp("", "boulevard-retry-*")
+		if err != nil {
+			return nil, fmt.Errorf("cannot create temporary directory: %w", err)
+		}
+
+		r.tmpDirPath = dirPath
+	}
+
+	r.reqBodyMemBufSize = DefaultRequestBodyMemoryBufferSize
+	if size := cfg.RequestBodyMemoryBufferSize; size != nil {
+		r.reqBodyMemBufSize = *size
+	}
+
+	r.maxReqBodySize = DefaultMaxRequestBodySize
+	if size := cfg.MaxRequestBodySize; size != nil {
+		r.maxReqBodySize = *size
+	}
+
+	return &r, nil
+}
+
+func (r *reverseProxyRetry) stop() {
+	if r.Cfg.TemporaryDirectory == "" {
+		os.RemoveAll(r.tmpDirPath)
+	}
+}
+
+func (r *reverseProxyRetry) backoff(attempt int) time.Duration {
+	delay := r.Cfg.Backoff << (attempt - 1)
+	if delay > r.Cfg.MaxBackoff || delay <= 0 {
+		delay = r.Cfg.MaxBackoff
+	}
+
+	return delay
+}
+
+// reverseProxyRequestBody is the buffered body of a request which may have to
+// be sent several times.
+type reverseProxyRequestBody struct {
+	buf       *boulevard.SpillBuffer
+	ownBuffer bool
+	readers   []io.Closer
+}
+
+func (b *reverseProxyRequestBody) reader() (io.ReadCloser, error) {
+	r, err := b.buf.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read spill buffer: %w", err)
+	}
+
+	b.readers = append(b.readers, r)
+
+	return r, nil
+}
+
+func (b *reverseProxyRequestBody) close(ctx *RequestContext) {
+	for _, r := range b.readers {
+		r.Close()
+	}
+
+	if b.ownBuffer {
+		if err := b.buf.Close(); err != nil {
+			ctx.Log.Error("cannot close spill buffer: %v", err)
+		}
+	}
+}
+
+// bufferRetriedRequestBody buffers the request body so that it can be sent
+// multiple times. If the body is too large, it is left readable in the
+// original request and the boolean returned is false.
+func (a *ReverseProxyAction) bufferRetriedRequestBody(ctx *RequestContext, reqBodyBuf *boulevard.SpillBuffer) (*reverseProxyRequestBody, bool, error) {
+	if reqBodyBuf != nil {
+		// Already buffered for mirroring
+		return &reverseProxyRequestBody{buf: reqBodyBuf}, true, nil
+	}
+
+	r := a.retry
+	req := ctx.Request
+
+	fileName := hex.EncodeToString(boulevard.RandomBytes(16))
+	filePath := path.Join(r.tmpDirPath, fileName)
+
+	buf := boulevard.NewSpillBuffer(filePath, r.reqBodyMemBufSize,
+		r.maxReqBodySize)
+	body := reverseProxyRequestBody{buf: buf, ownBuffer: true}
+
+	if _, err := io.Copy(buf, io.LimitReader(req.Body,
+		r.maxReqBodySize)); err != nil {
+		body.close(ctx)
+		return nil, false, err
+	}
+
+	var extra [1]byte
+	if n, _ := io.ReadFull(req.Body, extra[:]); n > 0 {
+		ctx.Log.Debug(1, "request body too large, disabling retries")
+
+		bufReader, err := body.reader()
+		if err != nil {
+			body.close(ctx)
+			return nil, false, err
+		}
+
+		req.Body = io.NopCloser(io.MultiReader(bufReader,
+			bytes.NewReader(extra[:n]), req.Body))
+		return &body, false, nil
+	}
+
+	return &body, true, nil
+}
+
+// sendRequest sends the request to the upstream, retrying according to the
+// retry policy if there is one. On success, the caller is responsible for
+// releasing the connection. On failure, an error response has already been
+// sent.
+func (a *ReverseProxyAction) sendRequest(ctx *RequestContext, reqBodyBuf *boulevard.SpillBuffer, prepare func(*http.Request)) (*httputils.Client, *httputils.ClientConn, *http.Response) {
+	attempts := 1
+	var body *reverseProxyRequestBody
+
+	if a.retry != nil && a.retry.Cfg.Attempts > 1 {
+		attempts = a.retry.Cfg.Attempts
+
+		if ctx.Request.ContentLength != 0 {
+			buf, replayable, err := a.bufferRetriedRequestBody(ctx,
+				reqBodyBuf)
+			if err != nil {
+				ctx.Log.Error("cannot buffer request body: %v", err)
+				ctx.ReplyError(500)
+				return nil, nil, nil
+			}
+			defer buf.close(ctx)
+
+			if replayable {
+				body = buf
+			} else {
+				attempts = 1
+			}
+		}
+	}
+
+	idempotent := slices.Contains(idempotentHTTPMethods, ctx.Request.Method)
+
+	for attempt := 1; ; attempt++ {
+		lastAttempt := attempt >= attempts
+
+		canRetry := func(condition string) bool {
+			if lastAttempt || !a.retry.Cfg.hasCondition(condition) {
+				return false
+			}
+
+			// Retrying a non-idempotent request which has already been sent
+			// could have unexpected side effects.
+			return condition == ReverseProxyRetryConditionConnectError ||
+				idempotent
+		}
+
+		if attempt > 1 && !a.waitBeforeRetry(ctx, attempt-1) {
+			return nil, nil, nil
+		}
+
+		client, scheme, address := a.upstream()
+		if client == nil {
+			ctx.Log.Error("no available upstream server found")
+			ctx.ReplyError(503)
+			return nil, nil, nil
+		}
+
+		req := a.rewriteRequest(ctx, scheme, address)
+		if prepare != nil {
+			prepare(req)
+		}
+
+		if body != nil {
+			bodyReader, err := body.reader()
+			if err != nil {
+				ctx.Log.Error("%v", err)
+				ctx.ReplyError(500)
+				return nil, nil, nil
+			}
+
+			req.Body = bodyReader
+			req.ContentLength = body.buf.Size()
+			req.TransferEncoding = nil
+		}
+
+		conn, err := client.AcquireConn()
+		if err != nil {
+			// Running out of connections says nothing about the health of
+			// the server.
+			if !errors.Is(err, httputils.ErrNoConnectionAvailable) {
+				a.primary.reportFailure(address)
+			}
+
+			if canRetry(ReverseProxyRetryConditionConnectError) {
+				ctx.Log.Debug(1, "cannot acquire upstream connection "+
+					"(attempt %d/%d): %v", attempt, attempts, err)
+				continue
+			}
+
+			ctx.Log.Error("cannot acquire upstream connection: %v", err)
+
+			status := 500
+			if errors.Is(err, httputils.ErrNoConnectionAvailable) {
+				status = 503
+			}
+
+			ctx.ReplyError(status)
+			return nil, nil, nil
+		}
+
+		if a.retry != nil && a.retry.Cfg.TryTimeout != nil {
+			conn.SetDeadline(time.Now().Add(*a.retry.Cfg.TryTimeout))
+		}
+
+		res, err := conn.SendRequest(req)
+		if err != nil {
+			a.primary.reportFailure(address)
+
+			conn.Close()
+			client.ReleaseConn(conn)
+
+			if canRetry(ReverseProxyRetryConditionReset) {
+				ctx.Log.Debug(1, "cannot send request upstream "+
+					"(attempt %d/%d): %v", attempt, attempts, err)
+				continue
+			}
+
+			ctx.Log.Error("cannot send request upstream: %v", err)
+
+			status := 500
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				status = 504
+			}
+
+			ctx.ReplyError(status)
+			return nil, nil, nil
+		}
+
+		if res.StatusCode >= 500 {
+			a.primary.reportFailure(address)
+		} else {
+			a.primary.reportSuccess(address)
+		}
+
+		if canRetry(ReverseProxyRetryConditionStatus) &&
+			slices.Contains(a.retry.Cfg.Statuses, res.StatusCode) {
+			ctx.Log.Debug(1, "upstream server replied with status %d "+
+				"(attempt %d/%d)", res.StatusCode, attempt, attempts)
+
+			// Closing the connection is cheaper than reading a body we do
+			// not care about.
+			res.Body.Close()
+			conn.Close()
+			client.ReleaseConn(conn)
+			continue
+		}
+
+		if a.retry != nil && a.retry.Cfg.TryTimeout != nil {
+			conn.SetDeadline(time.Time{})
+		}
+
+		return client, conn, res
+	}
+}
+
+func (a *ReverseProxyAction) waitBeforeRetry(ctx *RequestContext, attempt int) bool {
+	timer := time.NewTimer(a.retry.backoff(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Ctx.Done():
+		return false
+	}
+}
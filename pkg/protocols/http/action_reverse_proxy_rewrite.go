@@ -0,0 +1,207 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func validateBCLStripPrefix(v any) error {
+	if prefix := v.(string); !strings.HasPrefix(prefix, "/") {
+		return fmt.Errorf("prefix must start with '/'")
+	}
+
+	return nil
+}
+
+// rewritesPath indicates whether the path of requests sent to the primary
+// upstream differs from the path of the original request.
+func (a *ReverseProxyAction) rewritesPath() bool {
+	return a.Cfg.StripPrefix != "" || a.Cfg.Path != nil ||
+		a.primary.basePath != ""
+}
+
+// upstreamPath returns the escaped path the request must be sent to on an
+// upstream with a specific base path.
+func (a *ReverseProxyAction) upstreamPath(ctx *RequestContext, basePath string) string {
+	uriPath := ctx.Request.URL.EscapedPath()
+
+	if a.Cfg.Path != nil {
+		expandedPath := a.Cfg.Path.Expand(ctx.Vars)
+		if !strings.HasPrefix(expandedPath, "/") {
+			expandedPath = "/" + expandedPath
+		}
+
+		uriPath = (&url.URL{Path: expandedPath}).EscapedPath()
+	} else if prefix := a.Cfg.StripPrefix; prefix != "" {
+		uriPath, _ = replacePathPrefix(uriPath,
+			strings.TrimSuffix(prefix, "/"), "")
+	}
+
+	return basePath + uriPath
+}
+
+func (a *ReverseProxyAction) rewriteRequestPath(ctx *RequestContext, req *http.Request, basePath string) {
+	if a.Cfg.StripPrefix == "" && a.Cfg.Path == nil && basePath == "" {
+		return
+	}
+
+	uriPath := a.upstreamPath(ctx, basePath)
+
+	unescapedPath, err := url.PathUnescape(uriPath)
+	if err != nil {
+		// Cannot happen: all parts of the path are escaped
+		ctx.Log.Error("cannot unescape path %q: %v", uriPath, err)
+		return
+	}
+
+	req.URL.Path = unescapedPath
+	req.URL.RawPath = uriPath
+}
+
+// rewriteResponseHeader rewrites Location and Set-Cookie fields so that paths
+// on the upstream server are mapped back to paths seen by the client.
+func (a *ReverseProxyAction) rewriteResponseHeader(ctx *RequestContext, header http.Header) {
+	clientPrefix, upstreamPrefix := pathPrefixMapping(
+		ctx.Request.URL.EscapedPath(),
+		a.upstreamPath(ctx, a.primary.basePath))
+
+	if location := header.Get("Location"); location != "" {
+		header.Set("Location", a.rewriteLocation(ctx, location,
+			clientPrefix, upstreamPrefix))
+	}
+
+	if cookies := header.Values("Set-Cookie"); len(cookies) > 0 {
+		header.Del("Set-Cookie")
+
+		for _, cookie := range cookies {
+			header.Add("Set-Cookie", a.rewriteSetCookie(ctx, cookie,
+				clientPrefix, upstreamPrefix))
+		}
+	}
+}
+
+func (a *ReverseProxyAction) rewriteLocation(ctx *RequestContext, location, clientPrefix, upstreamPrefix string) string {
+	uri, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+
+	if uri.Host != "" {
+		if a.primary.isUpstreamHost(uri.Host) {
+			// The client cannot reach the upstream server
+			uri.Scheme = ""
+			uri.User = nil
+			uri.Host = ""
+		} else if !strings.EqualFold(uri.Host, ctx.Host) {
+			return location
+		}
+	} else if !strings.HasPrefix(uri.Path, "/") {
+		// Relative references are relative to the path of the client
+		// request and do not need to be rewritten.
+		return location
+	}
+
+	uriPath, found := replacePathPrefix(uri.EscapedPath(), upstreamPrefix,
+		clientPrefix)
+	if !found {
+		return uri.String()
+	}
+
+	unescapedPath, err := url.PathUnescape(uriPath)
+	if err != nil {
+		return location
+	}
+
+	uri.Path = unescapedPath
+	uri.RawPath = uriPath
+
+	return uri.String()
+}
+
+func (a *ReverseProxyAction) rewriteSetCookie(ctx *RequestContext, cookie, clientPrefix, upstreamPrefix string) string {
+	// We rewrite attributes in place instead of parsing and reformatting the
+	// cookie to make sure we do not lose anything.
+	parts := strings.Split(cookie, ";")
+
+	for i, part := range parts[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+
+		switch strings.ToLower(name) {
+		case "path":
+			cookiePath, found := replacePathPrefix(value, upstreamPrefix,
+				clientPrefix)
+			if !found {
+				continue
+			}
+
+			if len(cookiePath) > 1 {
+				cookiePath = strings.TrimSuffix(cookiePath, "/")
+			}
+
+			parts[i+1] = " " + name + "=" + cookiePath
+
+		case "domain":
+			if !a.primary.isUpstreamHost(strings.TrimPrefix(value, ".")) {
+				continue
+			}
+
+			host := ctx.Host
+			if hostname, _, err := net.SplitHostPort(host); err == nil {
+				host = hostname
+			}
+
+			parts[i+1] = " " + name + "=" + host
+		}
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// pathPrefixMapping finds the prefixes of the client path and of the upstream
+// path whose replacement maps one path to the other. For example
+// "/api/users/1" and "/v2/users/1" yield "/api" and "/v2".
+func pathPrefixMapping(clientPath, upstreamPath string) (string, string) {
+	i, j := len(clientPath), len(upstreamPath)
+	for i > 0 && j > 0 && clientPath[i-1] == upstreamPath[j-1] {
+		i--
+		j--
+	}
+
+	// The common suffix must start at a segment boundary
+	suffix := clientPath[i:]
+	if k := strings.IndexByte(suffix, '/'); k >= 0 {
+		i += k
+		j += k
+	} else {
+		i, j = len(clientPath), len(upstreamPath)
+	}
+
+	return clientPath[:i], upstreamPath[:j]
+}
+
+// replacePathPrefix replaces a prefix of a path, making sure that the prefix
+// only matches full segments.
+func replacePathPrefix(uriPath, prefix, newPrefix string) (string, bool) {
+	var rest string
+
+	switch {
+	case prefix == "":
+		rest = uriPath
+	case uriPath == prefix:
+		rest = ""
+	case strings.HasPrefix(uriPath, prefix+"/"):
+		rest = uriPath[len(prefix):]
+	default:
+		return uriPath, false
+	}
+
+	newPath := newPrefix + rest
+	if newPath == "" {
+		newPath = "/"
+	}
+
+	return newPath, true
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathPrefixMapping(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		clientPath     string
+		upstreamPath   string
+		clientPrefix   string
+		upstreamPrefix string
+	}{
+		{"/", "/", "", ""},
+		{"/a/b", "/a/b", "", ""},
+		{"/api/users/1", "/users/1", "/api", ""},
+		{"/api/users/1", "/v2/users/1", "/api", "/v2"},
+		{"/api/", "/", "/api", ""},
+		{"/api", "/app", "/api", "/app"},
+		{"/foo", "/xfoo", "/foo", "/xfoo"},
+		{"/a/foo", "/b/xfoo", "/a/foo", "/b/xfoo"},
+	}
+
+	for _, test := range tests {
+		clientPrefix, upstreamPrefix := pathPrefixMapping(test.clientPath,
+			test.upstreamPath)
+
+		label := test.clientPath + " " + test.upstreamPath
+		assert.Equal(test.clientPrefix, clientPrefix, label)
+		assert.Equal(test.upstreamPrefix, upstreamPrefix, label)
+	}
+}
+
+func TestReplacePathPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		path      string
+		prefix    string
+		newPrefix string
+		newPath   string
+		found     bool
+	}{
+		{"/a/b", "", "", "/a/b", true},
+		{"/a/b", "", "/x", "/x/a/b", true},
+		{"/a/b", "/a", "", "/b", true},
+		{"/a/b", "/a", "/x", "/x/b", true},
+		{"/a", "/a", "", "/", true},
+		{"/a", "/a", "/x", "/x", true},
+		{"/ab", "/a", "/x", "/ab", false},
+		{"/b", "/a", "/x", "/b", false},
+	}
+
+	for _, test := range tests {
+		newPath, found := replacePathPrefix(test.path, test.prefix,
+			test.newPrefix)
+
+		label := test.path + " " + test.prefix + " " + test.newPrefix
+		assert.Equal(test.newPath, newPath, label)
+		assert.Equal(test.found, found, label)
+	}
+}
@@ -0,0 +1,196 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"go.n16f.net/boulevard/pkg/boulevard"
+	"go.n16f.net/boulevard/pkg/httputils"
+	"go.n16f.net/boulevard/pkg/netutils"
+)
+
+// reverseProxyUpstream is the destination of proxied requests: either a single
+// server identified by its URI or the servers of a load balancer.
+type reverseProxyUpstream struct {
+	// Single upstream server
+	uri      *url.URL
+	basePath string // escaped, without trailing slash
+	client   *httputils.Client
+
+	// Load balancer
+	loadBalancer *boulevard.LoadBalancer
+	scheme       string
+	clients      map[string]*httputils.Client // address -> client
+}
+
+func newReverseProxyUpstream(h *Handler, uriString, lbName string, tlsClientCfg *netutils.TLSClientCfg, settings httputils.ClientSettingsCfg) (*reverseProxyUpstream, error) {
+	tlsCfg, err := tlsClientCfg.TLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+
+	var u reverseProxyUpstream
+
+	if uriString != "" {
+		// URI
+		uri, err := url.Parse(uriString)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse URI: %w", err)
+		}
+		if uri.Scheme == "" {
+			uri.Scheme = "http"
+		}
+		if uri.Host == "" {
+			uri.Host = "localhost"
+		}
+		u.basePath = strings.TrimSuffix(uri.EscapedPath(), "/")
+		uri.Path = ""
+		uri.RawPath = ""
+		uri.Fragment = ""
+
+		if tlsClientCfg != nil && strings.ToLower(uri.Scheme) != "https" {
+			return nil, fmt.Errorf("TLS configuration requires an HTTPS URI")
+		}
+
+		port := uri.Port()
+		if port == "" {
+			if strings.ToLower(uri.Scheme) == "http" {
+				port = "80"
+			} else {
+				port = "443"
+			}
+		}
+		address := net.JoinHostPort(uri.Hostname(), port)
+
+		clientCfg := httputils.ClientCfg{
+			Scheme:  uri.Scheme,
+			Address: address,
+
+			TLS: tlsCfg,
+		}
+		settings.Apply(&clientCfg)
+
+		client, err := httputils.NewClient(clientCfg)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create client: %w", err)
+		}
+
+		u.uri = uri
+		u.client = client
+	} else {
+		// Load balancer
+		serverCfg := h.Protocol.Server.Cfg
+
+		lb := serverCfg.LoadBalancers[lbName]
+		if lb == nil {
+			return nil, fmt.Errorf("unknown load balancer %q", lbName)
+		}
+
+		// Load balancers only contain addresses, so we rely on the presence
+		// of a TLS configuration to know whether to use HTTPS or not.
+		u.scheme = "http"
+		if tlsClientCfg != nil {
+			u.scheme = "https"
+		}
+
+		// Settings of the action take precedence over those of the load
+		// balancer.
+		settings = settings.Merge(lb.Cfg.ClientSettings)
+
+		u.clients = make(map[string]*httputils.Client)
+		var startedClients []string
+
+		for _, srv := range lb.Servers {
+			address := srv.Address.String()
+
+			clientCfg := httputils.ClientCfg{
+				Scheme:  u.scheme,
+				Address: address,
+
+				TLS: tlsCfg,
+			}
+			settings.Apply(&clientCfg)
+
+			client, err := httputils.NewClient(clientCfg)
+			if err != nil {
+				for _, addr := range startedClients {
+					u.clients[addr].Stop()
+				}
+
+				return nil, fmt.Errorf("cannot create client: %w", err)
+			}
+
+			u.clients[address] = client
+			startedClients = append(startedClients, address)
+		}
+
+		u.loadBalancer = lb
+	}
+
+	return &u, nil
+}
+
+func (u *reverseProxyUpstream) stop() {
+	if u.client != nil {
+		u.client.Stop()
+	} else {
+		for _, client := range u.clients {
+			client.Stop()
+		}
+	}
+}
+
+// pick returns the client, scheme and address of the server the next request
+// should be sent to, or a nil client if no server is available.
+func (u *reverseProxyUpstream) pick() (*httputils.Client, string, string) {
+	if u.client != nil {
+		// Single upstream server
+		return u.client, u.uri.Scheme, u.uri.Host
+	}
+
+	// Load balancer
+	address := u.loadBalancer.Address()
+	if address == "" {
+		return nil, "", ""
+	}
+
+	return u.clients[address], u.scheme, address
+}
+
+// isUpstreamHost indicates whether a host, with or without port, designates
+// one of the upstream servers.
+func (u *reverseProxyUpstream) isUpstreamHost(host string) bool {
+	matches := func(address string) bool {
+		hostname, _, _ := net.SplitHostPort(address)
+		return strings.EqualFold(host, address) ||
+			strings.EqualFold(host, hostname)
+	}
+
+	if u.client != nil {
+		return matches(u.uri.Host) || matches(u.client.Cfg.Address)
+	}
+
+	for address := range u.clients {
+		if matches(address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reportSuccess and reportFailure feed the outlier detection of the load
+// balancer if there is one.
+func (u *reverseProxyUpstream) reportSuccess(address string) {
+	if u.loadBalancer != nil {
+		u.loadBalancer.ReportSuccess(address)
+	}
+}
+
+func (u *reverseProxyUpstream) reportFailure(address string) {
+	if u.loadBalancer != nil {
+		u.loadBalancer.ReportFailure(address)
+	}
+}
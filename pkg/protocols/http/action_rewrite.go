@@ -0,0 +1,73 @@
+package http
+
+import (
+	"fmt"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/boulevard/pkg/boulevard"
+)
+
+const (
+	MaxRequestRewrites = 10
+)
+
+type RewriteActionCfg struct {
+	Path  *boulevard.FormatString
+	Query *boulevard.FormatString
+}
+
+func (cfg *RewriteActionCfg) ReadBCLElement(elt *bcl.Element) error {
+	if elt.IsBlock() {
+		elt.MaybeEntryValues("path", &cfg.Path)
+		elt.MaybeEntryValues("query", &cfg.Query)
+
+		if cfg.Path == nil && cfg.Query == nil {
+			return fmt.Errorf("rewrite action must contain a path, " +
+				"a query or both")
+		}
+	} else {
+		elt.Values(&cfg.Path)
+	}
+
+	return nil
+}
+
+type RewriteAction struct {
+	Handler *Handler
+	Cfg     *RewriteActionCfg
+}
+
+func NewRewriteAction(h *Handler, cfg *RewriteActionCfg) (*RewriteAction, error) {
+	a := RewriteAction{
+		Handler: h,
+		Cfg:     cfg,
+	}
+
+	return &a, nil
+}
+
+func (a *RewriteAction) Start() error {
+	return nil
+}
+
+func (a *RewriteAction) Stop() {
+}
+
+func (a *RewriteAction) HandleRequest(ctx *RequestContext) {
+	uri := ctx.Request.URL
+
+	uriPath := uri.Path
+	if a.Cfg.Path != nil {
+		uriPath = a.Cfg.Path.Expand(ctx.Vars)
+	}
+
+	rawQuery := uri.RawQuery
+	if a.Cfg.Query != nil {
+		rawQuery = a.Cfg.Query.Expand(ctx.Vars)
+	}
+
+	ctx.Log.Debug(1, "rewriting request to path %q and query %q",
+		uriPath, rawQuery)
+
+	ctx.reprocess(uriPath, rawQuery)
+}
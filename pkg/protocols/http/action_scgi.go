@@ -0,0 +1,43 @@
+package http
+
+import "go.n16f.net/boulevard/pkg/scgi"
+
+type SCGIActionCfg struct {
+	CGIGatewayCfg
+}
+
+type SCGIAction struct {
+	Handler *Handler
+	Cfg     *SCGIActionCfg
+
+	gateway *cgiGateway
+}
+
+func NewSCGIAction(h *Handler, cfg *SCGIActionCfg) (*SCGIAction, error) {
+	gateway, err := newCGIGateway(h, &cfg.CGIGatewayCfg, "SCGI",
+		scgi.Protocol{})
+	if err != nil {
+		return nil, err
+	}
+
+	a := SCGIAction{
+		Handler: h,
+		Cfg:     cfg,
+
+		gateway: gateway,
+	}
+
+	return &a, nil
+}
+
+func (a *SCGIAction) Start() error {
+	return a.gateway.start()
+}
+
+func (a *SCGIAction) Stop() {
+	a.gateway.stop()
+}
+
+func (a *SCGIAction) HandleRequest(ctx *RequestContext) {
+	a.gateway.handleRequest(ctx)
+}
@@ -2,12 +2,22 @@ package http
 
 import (
 	"bytes"
+	"cmp"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"go.n16f.net/bcl"
 	"go.n16f.net/boulevard/pkg/boulevard"
@@ -17,24 +27,45 @@ import (
 const (
 	DefaultServeActionIndexViewMaxFiles = 1000
 	ServeActionTimestampLayout          = "2006-01-02 15:04:05Z"
+
+	ServeIndexReadmeMaxSize = 64 * 1024
+
+	ServeActionArchiveCheckInterval = time.Second
 )
 
 type ServeActionCfg struct {
-	Path *boulevard.FormatString
+	Path    *boulevard.FormatString
+	Archive string
 
 	IndexFiles          []string
 	IndexRedirectStatus int
 	IndexRedirectURI    *boulevard.FormatString
 	IndexView           ServeActionIndexViewCfg
 
+	Precompressed ServeActionPrecompressedCfg
+
+	ETag         ServeActionETagCfg
+	CacheControl *ServeActionCacheControlCfg
+
+	TryFiles     []*boulevard.FormatString
 	FileNotFound *ServeActionFileNotFoundCfg
+
+	FollowSymlinks string
+	HiddenFiles    string
+	DenyPatterns   []*ServeActionDenyPattern
 }
 
 func (cfg *ServeActionCfg) ReadBCLElement(elt *bcl.Element) error {
 	if elt.IsBlock() {
 		elt.CheckEntriesMaybeOneOf("index_file", "index_redirect")
 
-		elt.EntryValues("path", &cfg.Path)
+		// When serving an archive, the path is an optional directory in the
+		// archive.
+		if elt.MaybeEntryValues("archive", &cfg.Archive) {
+			elt.MaybeEntryValues("path", &cfg.Path)
+		} else {
+			elt.EntryValues("path", &cfg.Path)
+		}
 
 		for _, entry := range elt.FindEntries("index_file") {
 			var file string
@@ -50,25 +81,85 @@ func (cfg *ServeActionCfg) ReadBCLElement(elt *bcl.Element) error {
 
 		elt.MaybeElement("index_view", &cfg.IndexView)
 
+		elt.MaybeElement("precompressed", &cfg.Precompressed)
+
+		elt.MaybeElement("etag", &cfg.ETag)
+		elt.MaybeBlock("cache_control", &cfg.CacheControl)
+
+		for _, entry := range elt.FindEntries("try_files") {
+			for i := range entry.NbValues() {
+				var candidate boulevard.FormatString
+				if entry.Value(i, &candidate) {
+					cfg.TryFiles = append(cfg.TryFiles, &candidate)
+				}
+			}
+		}
+
 		elt.MaybeBlock("file_not_found", &cfg.FileNotFound)
+
+		if entry := elt.FindEntry("follow_symlinks"); entry != nil {
+			if entry.CheckValueOneOf(0, ServeSymlinkPolicyNone,
+				ServeSymlinkPolicyOwnerMatch, ServeSymlinkPolicyInsideRoot,
+				ServeSymlinkPolicyAll) {
+				entry.Values(&cfg.FollowSymlinks)
+			}
+		}
+
+		if entry := elt.FindEntry("hidden_files"); entry != nil {
+			if entry.CheckValueOneOf(0, ServeHiddenFilePolicyDeny,
+				ServeHiddenFilePolicyIgnore, ServeHiddenFilePolicyAllow) {
+				entry.Values(&cfg.HiddenFiles)
+			}
+		}
+
+		for _, entry := range elt.FindEntries("deny") {
+			for i := range entry.NbValues() {
+				var pattern ServeActionDenyPattern
+				if entry.Value(i, &pattern) {
+					cfg.DenyPatterns = append(cfg.DenyPatterns, &pattern)
+				}
+			}
+		}
 	} else {
 		elt.Values(&cfg.Path)
 	}
 
+	if cfg.FollowSymlinks == "" {
+		cfg.FollowSymlinks = ServeSymlinkPolicyAll
+	}
+
+	if cfg.HiddenFiles == "" {
+		cfg.HiddenFiles = ServeHiddenFilePolicyAllow
+	}
+
 	return nil
 }
 
 type ServeActionIndexViewCfg struct {
-	Enabled  bool
-	MaxFiles int
+	Enabled         bool
+	MaxFiles        int
+	PageSize        int // 0 if pagination is disabled by default
+	HideHiddenFiles bool
+	ReadmeFiles     []string
 }
 
 func (cfg *ServeActionIndexViewCfg) ReadBCLElement(elt *bcl.Element) error {
+	cfg.MaxFiles = DefaultServeActionIndexViewMaxFiles
+
 	if elt.IsBlock() {
 		cfg.Enabled = true
 
-		cfg.MaxFiles = DefaultServeActionIndexViewMaxFiles
-		elt.MaybeEntryValues("max_files", &cfg.MaxFiles)
+		elt.MaybeEntryValues("max_files",
+			bcl.WithValueValidation(&cfg.MaxFiles, bcl.ValidatePositiveInteger))
+		elt.MaybeEntryValues("page_size",
+			bcl.WithValueValidation(&cfg.PageSize, bcl.ValidatePositiveInteger))
+		elt.MaybeEntryValues("hide_hidden_files", &cfg.HideHiddenFiles)
+
+		for _, entry := range elt.FindEntries("readme_file") {
+			var file string
+			entry.Values(&file)
+			cfg.ReadmeFiles = append(cfg.ReadmeFiles, file)
+		}
 	} else {
 		elt.Values(&cfg.Enabled)
 	}
@@ -76,24 +167,206 @@ func (cfg *ServeActionIndexViewCfg) ReadBCLElement(elt *bcl.Element) error {
 	return nil
 }
 
+type ServeActionPrecompressedCfg struct {
+	Enabled bool
+	Codings []string
+}
+
+func (cfg *ServeActionPrecompressedCfg) ReadBCLElement(elt *bcl.Element) error {
+	if elt.IsBlock() {
+		cfg.Enabled = true
+
+		for _, entry := range elt.FindEntries("algorithm") {
+			var coding string
+			if entry.CheckValueOneOf(0, ContentCodingGzip, ContentCodingZstd,
+				ContentCodingBrotli) && entry.Values(&coding) {
+				cfg.Codings = append(cfg.Codings, coding)
+			}
+		}
+	} else {
+		elt.Values(&cfg.Enabled)
+	}
+
+	if len(cfg.Codings) == 0 {
+		cfg.Codings = ContentCodingValues
+	}
+
+	return nil
+}
+
+type ServeActionETagCfg struct {
+	Method    string // empty if disabled
+	CacheSize int
+}
+
+func (cfg *ServeActionETagCfg) ReadBCLElement(elt *bcl.Element) error {
+	cfg.CacheSize = DefaultFileETagCacheSize
+
+	if elt.IsBlock() {
+		if entry := elt.FindEntry("method"); entry != nil {
+			if entry.CheckValueOneOf(0, FileETagMethodMTimeSize,
+				FileETagMethodContentHash) {
+				entry.Values(&cfg.Method)
+			}
+		} else {
+			cfg.Method = FileETagMethodMTimeSize
+		}
+
+		elt.MaybeEntryValues("cache_size",
+			bcl.WithValueValidation(&cfg.CacheSize, bcl.ValidatePositiveInteger))
+	} else {
+		if elt.CheckValueOneOf(0, FileETagMethodMTimeSize,
+			FileETagMethodContentHash) {
+			elt.Values(&cfg.Method)
+		}
+	}
+
+	return nil
+}
+
+type ServeActionCacheControlCfg struct {
+	Rules   []*ServeActionCacheControlRuleCfg
+	Default string
+}
+
+func (cfg *ServeActionCacheControlCfg) ReadBCLElement(block *bcl.Element) error {
+	for _, entry := range block.FindEntries("rule") {
+		var rule ServeActionCacheControlRuleCfg
+		if rule.ReadBCLEntry(entry) {
+			cfg.Rules = append(cfg.Rules, &rule)
+		}
+	}
+
+	block.MaybeEntryValues("default", &cfg.Default)
+
+	return nil
+}
+
+type ServeActionCacheControlRuleCfg struct {
+	// One of the following
+	Path       *PathPattern
+	PathRegexp *regexp.Regexp
+	MediaRange *MediaRange
+
+	Value string
+}
+
+func (cfg *ServeActionCacheControlRuleCfg) ReadBCLEntry(entry *bcl.Element) bool {
+	if !entry.CheckNbValues(3) {
+		return false
+	}
+
+	if !entry.CheckValueOneOf(0, "path", "media_type") {
+		return false
+	}
+
+	var ruleType string
+	entry.Value(0, &ruleType)
+
+	switch ruleType {
+	case "path":
+		var s bcl.String
+		if !entry.Value(1, &s) {
+			return false
+		}
+
+		if s.Sigil == "re" {
+			if !entry.Value(1, &cfg.PathRegexp) {
+				return false
+			}
+		} else if !entry.Value(1, &cfg.Path) {
+			return false
+		}
+
+	case "media_type":
+		var s string
+		if !entry.Value(1, &s) {
+			return false
+		}
+
+		var mediaRange MediaRange
+		if err := mediaRange.Parse(s); err != nil {
+			entry.AddSimpleValidationError("invalid media range: %v", err)
+			return false
+		}
+
+		cfg.MediaRange = &mediaRange
+	}
+
+	return entry.Value(2, &cfg.Value)
+}
+
+func (cfg *ServeActionCacheControlRuleCfg) Matches(filePath string, mediaType *MediaType) bool {
+	switch {
+	case cfg.Path != nil:
+		matched, _ := cfg.Path.Match(filePath)
+		return matched
+	case cfg.PathRegexp != nil:
+		return cfg.PathRegexp.MatchString(filePath)
+	case cfg.MediaRange != nil:
+		return mediaType != nil && cfg.MediaRange.Matches(mediaType)
+	}
+
+	return false
+}
+
 type ServeActionFileNotFoundCfg struct {
-	Reply *ReplyActionCfg
+	Reply   *ReplyActionCfg
+	Rewrite *RewriteActionCfg
+	FastCGI *FastCGIActionCfg
 }
 
 func (cfg *ServeActionFileNotFoundCfg) ReadBCLElement(block *bcl.Element) error {
+	block.CheckElementsMaybeOneOf("reply", "rewrite", "fastcgi")
 	block.MaybeElement("reply", &cfg.Reply)
+	block.MaybeElement("rewrite", &cfg.Rewrite)
+	block.MaybeElement("fastcgi", &cfg.FastCGI)
 	return nil
 }
 
 type ServeAction struct {
-	Handler           *Handler
-	Cfg               *ServeActionCfg
-	FileNotFoundReply *ReplyAction
+	Handler            *Handler
+	Cfg                *ServeActionCfg
+	FileNotFoundAction Action
+
+	archive          *servedArchive
+	archiveCheckTime time.Time
+	archiveMutex     sync.Mutex
 
+	etagCache    *FileETagCache
 	serveView    *View
 	redirectView *View
 }
 
+// servedArchive is reference counted so that an archive replaced after a
+// change is only closed once all requests using it are done.
+type servedArchive struct {
+	fs      *boulevard.ArchiveFS
+	refs    int
+	retired bool
+}
+
+// serveRoot is the file system files are served from. Path identifies the
+// root directory in log messages and caches.
+type serveRoot struct {
+	FS   fs.FS
+	Path string
+	Dir  string // empty if the file system is not the local one
+}
+
+func (r *serveRoot) FilePath(name string) string {
+	return path.Join(r.Path, name)
+}
+
+func serveFileName(p string) string {
+	name := strings.TrimPrefix(path.Join("/", p), "/")
+	if name == "" {
+		return "."
+	}
+
+	return name
+}
+
 func NewServeAction(h *Handler, cfg *ServeActionCfg) (*ServeAction, error) {
 	serveView, err := NewView("templates/serve")
 	if err != nil {
@@ -113,24 +386,48 @@ func NewServeAction(h *Handler, cfg *ServeActionCfg) (*ServeAction, error) {
 		redirectView: redirectView,
 	}
 
-	if cfg.FileNotFound != nil && cfg.FileNotFound.Reply != nil {
-		reply, err := NewReplyAction(h, cfg.FileNotFound.Reply)
+	if cfg.ETag.Method == FileETagMethodContentHash {
+		a.etagCache = NewFileETagCache(cfg.ETag.CacheSize)
+	}
+
+	if fnfCfg := cfg.FileNotFound; fnfCfg != nil {
+		var action Action
+		var err error
+
+		switch {
+		case fnfCfg.Reply != nil:
+			action, err = NewReplyAction(h, fnfCfg.Reply)
+		case fnfCfg.Rewrite != nil:
+			action, err = NewRewriteAction(h, fnfCfg.Rewrite)
+		case fnfCfg.FastCGI != nil:
+			action, err = NewFastCGIAction(h, fnfCfg.FastCGI)
+		}
+
 		if err != nil {
-			return nil, fmt.Errorf("cannot create file not found reply "+
-				"action: %w", err)
+			return nil, fmt.Errorf("cannot create file not found action: %w",
+				err)
 		}
 
-		a.FileNotFoundReply = reply
+		a.FileNotFoundAction = action
 	}
 
 	return &a, nil
 }
 
 func (a *ServeAction) Start() error {
-	if a.FileNotFoundReply != nil {
-		if err := a.FileNotFoundReply.Start(); err != nil {
-			return fmt.Errorf("cannot start file not found reply action: %w",
-				err)
+	if filePath := a.Cfg.Archive; filePath != "" {
+		archiveFS, err := boulevard.OpenArchiveFS(filePath)
+		if err != nil {
+			return fmt.Errorf("cannot open archive: %w", err)
+		}
+
+		a.archive = &servedArchive{fs: archiveFS}
+		a.archiveCheckTime = time.Now()
+	}
+
+	if a.FileNotFoundAction != nil {
+		if err := a.FileNotFoundAction.Start(); err != nil {
+			return fmt.Errorf("cannot start file not found action: %w", err)
 		}
 	}
 
@@ -138,13 +435,115 @@ func (a *ServeAction) Start() error {
 }
 
 func (a *ServeAction) Stop() {
-	if a.FileNotFoundReply != nil {
-		a.FileNotFoundReply.Stop()
+	if a.FileNotFoundAction != nil {
+		a.FileNotFoundAction.Stop()
+	}
+
+	a.archiveMutex.Lock()
+	defer a.archiveMutex.Unlock()
+
+	if a.archive != nil {
+		a.archive.fs.Close()
+	}
+}
+
+func (a *ServeAction) acquireArchive(ctx *RequestContext) *servedArchive {
+	a.archiveMutex.Lock()
+	defer a.archiveMutex.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(a.archiveCheckTime) >= ServeActionArchiveCheckInterval {
+		a.archiveCheckTime = now
+
+		if err := a.maybeReloadArchive(ctx); err != nil {
+			// Keep serving the previous version, it is better than nothing
+			ctx.Log.Error("cannot reload archive %q: %v", a.Cfg.Archive, err)
+		}
+	}
+
+	a.archive.refs++
+
+	return a.archive
+}
+
+func (a *ServeAction) releaseArchive(archive *servedArchive) {
+	a.archiveMutex.Lock()
+	defer a.archiveMutex.Unlock()
+
+	archive.refs--
+
+	if archive.refs == 0 && archive.retired {
+		archive.fs.Close()
+	}
+}
+
+func (a *ServeAction) maybeReloadArchive(ctx *RequestContext) error {
+	// Archives are usually deployed by renaming a new file over the previous
+	// one; the file we have open is not affected.
+
+	archiveFS := a.archive.fs
+
+	info, err := os.Stat(archiveFS.Path)
+	if err != nil {
+		return fmt.Errorf("cannot stat %q: %w", archiveFS.Path, err)
+	}
+
+	if info.Size() == archiveFS.Size && info.ModTime().Equal(archiveFS.ModTime) {
+		return nil
+	}
+
+	newArchiveFS, err := boulevard.OpenArchiveFS(archiveFS.Path)
+	if err != nil {
+		return err
+	}
+
+	ctx.Log.Info("archive %q modified, reloading", archiveFS.Path)
+
+	a.archive.retired = true
+	if a.archive.refs == 0 {
+		archiveFS.Close()
 	}
+
+	a.archive = &servedArchive{fs: newArchiveFS}
+
+	return nil
 }
 
 func (a *ServeAction) HandleRequest(ctx *RequestContext) {
-	basePath := a.Cfg.Path.Expand(ctx.Vars)
+	var root serveRoot
+
+	if a.Cfg.Archive == "" {
+		root.Path = a.Cfg.Path.Expand(ctx.Vars)
+		root.FS = os.DirFS(root.Path)
+		root.Dir = root.Path
+	} else {
+		archive := a.acquireArchive(ctx)
+		defer a.releaseArchive(archive)
+
+		root.Path = archive.fs.Path
+		root.FS = archive.fs
+
+		if a.Cfg.Path != nil {
+			dirName := serveFileName(a.Cfg.Path.Expand(ctx.Vars))
+
+			subFS, err := fs.Sub(root.FS, dirName)
+			if err != nil {
+				ctx.Log.Error("cannot access directory %q in archive %q: %v",
+					dirName, root.Path, err)
+				ctx.ReplyError(500)
+				return
+			}
+
+			root.Path = path.Join(root.Path, dirName)
+			root.FS = subFS
+		}
+	}
+
+	if len(a.Cfg.TryFiles) > 0 {
+		a.tryFiles(ctx, &root)
+		return
+	}
 
 	subpath := ctx.Subpath
 	if subpath == "" && !a.Handler.Cfg.Match.HasPaths() {
@@ -152,142 +551,566 @@ func (a *ServeAction) HandleRequest(ctx *RequestContext) {
 		// context, meaning that we are serving what the request URL contains.
 		subpath = ctx.Request.URL.Path
 	}
-	filePath := path.Join(basePath, subpath)
+	name := serveFileName(subpath)
 
-	info, err := os.Stat(filePath)
+	info, err := a.stat(&root, name)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
-			if a.FileNotFoundReply == nil {
-				ctx.ReplyError2(404, "file not found")
-			} else {
-				a.FileNotFoundReply.HandleRequest(ctx)
-			}
-
+			a.replyFileNotFound(ctx)
+			return
+		} else if errors.Is(err, errServeAccessDenied) {
+			ctx.ReplyError2(403, "file access denied")
 			return
 		}
 
-		ctx.Log.Error("cannot stat %q: %v", filePath, err)
+		ctx.Log.Error("cannot stat %q: %v", root.FilePath(name), err)
 		ctx.ReplyError(500)
 		return
 	}
 
 	if info.Mode().IsDir() {
-		if a.Cfg.IndexRedirectURI != nil {
-			uriString := a.Cfg.IndexRedirectURI.Expand(ctx.Vars)
-			redirect(a.Cfg.IndexRedirectStatus, uriString, nil, nil,
-				a.redirectView, ctx)
-			return
-		} else {
-			for _, indexFile := range a.Cfg.IndexFiles {
-				indexFilePath := path.Join(filePath, indexFile)
-				indexInfo, err := os.Stat(indexFilePath)
-				if err == nil {
-					body, err := os.Open(indexFilePath)
-					if err == nil {
-						defer body.Close()
-
-						http.ServeContent(ctx.ResponseWriter, ctx.Request,
-							indexFilePath, indexInfo.ModTime(), body)
-						return
-					}
-				}
+		a.serveDirectory(ctx, &root, name)
+		return
+	}
+
+	if !info.Mode().IsRegular() {
+		ctx.ReplyError2(403, "file access denied")
+		return
+	}
+
+	a.serveFile(ctx, &root, name, info)
+}
+
+func (a *ServeAction) tryFiles(ctx *RequestContext, root *serveRoot) {
+	// Candidates are always relative to the base path. Directories are only
+	// used if the candidate ends with a '/' character.
+
+	for _, candidate := range a.Cfg.TryFiles {
+		relPath := candidate.Expand(ctx.Vars)
+		name := serveFileName(relPath)
+
+		info, err := a.stat(root, name)
+		if err != nil {
+			// Candidates which cannot be accessed are treated as missing so
+			// that we do not leak their existence.
+			if errors.Is(err, fs.ErrNotExist) ||
+				errors.Is(err, errServeAccessDenied) {
+				continue
 			}
+
+			ctx.Log.Error("cannot stat %q: %v", root.FilePath(name), err)
+			ctx.ReplyError(500)
+			return
 		}
 
-		if a.Cfg.IndexView.Enabled {
-			a.serveIndexView(filePath, ctx)
+		if info.Mode().IsRegular() {
+			a.serveFile(ctx, root, name, info)
 			return
 		}
 
-		ctx.ReplyError2(403, "directory access denied")
+		if info.Mode().IsDir() && strings.HasSuffix(relPath, "/") {
+			a.serveDirectory(ctx, root, name)
+			return
+		}
+	}
+
+	a.replyFileNotFound(ctx)
+}
+
+func (a *ServeAction) replyFileNotFound(ctx *RequestContext) {
+	if a.FileNotFoundAction == nil {
+		ctx.ReplyError2(404, "file not found")
 		return
 	}
 
-	if !info.Mode().IsRegular() {
-		ctx.ReplyError2(403, "file access denied")
+	a.FileNotFoundAction.HandleRequest(ctx)
+}
+
+func (a *ServeAction) serveDirectory(ctx *RequestContext, root *serveRoot, dirName string) {
+	if a.Cfg.IndexRedirectURI != nil {
+		uriString := a.Cfg.IndexRedirectURI.Expand(ctx.Vars)
+		redirect(a.Cfg.IndexRedirectStatus, uriString, nil, nil,
+			a.redirectView, ctx)
 		return
 	}
 
-	modTime := info.ModTime()
+	for _, indexFile := range a.Cfg.IndexFiles {
+		indexName := path.Join(dirName, indexFile)
+		indexInfo, err := a.stat(root, indexName)
+		if err == nil && indexInfo.Mode().IsRegular() {
+			a.serveFile(ctx, root, indexName, indexInfo)
+			return
+		}
+	}
+
+	if a.Cfg.IndexView.Enabled {
+		a.serveIndexView(ctx, root, dirName)
+		return
+	}
+
+	ctx.ReplyError2(403, "directory access denied")
+}
+
+func (a *ServeAction) serveFile(ctx *RequestContext, root *serveRoot, name string, info fs.FileInfo) {
+	header := ctx.ResponseWriter.Header()
+
+	var coding string
+	contentName := name
+	contentInfo := info
+
+	if a.Cfg.Precompressed.Enabled {
+		variantCoding, variantName, variantInfo, hasVariants :=
+			a.findPrecompressedVariant(ctx, root, name)
+
+		if hasVariants {
+			httputils.AddVaryFieldName(header, "Accept-Encoding")
+
+			if variantCoding != "" {
+				// The media type must be the one of the original file, not the
+				// one of the compressed variant.
+				if err := a.setContentType(header, root, name); err != nil {
+					ctx.Log.Error("cannot identify content type of %q: %v",
+						root.FilePath(name), err)
+					ctx.ReplyError(500)
+					return
+				}
+
+				header.Set("Content-Encoding", variantCoding)
 
-	body, err := os.Open(filePath)
+				coding = variantCoding
+				contentName = variantName
+				contentInfo = variantInfo
+			}
+		}
+	}
+
+	contentPath := root.FilePath(contentName)
+
+	file, err := root.FS.Open(contentName)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			ctx.ReplyError2(404, "file not found")
 			return
 		}
 
-		ctx.Log.Error("cannot open %q: %v", filePath, err)
+		ctx.Log.Error("cannot open %q: %v", contentPath, err)
+		ctx.ReplyError(500)
+		return
+	}
+	defer file.Close()
+
+	body, ok := file.(io.ReadSeeker)
+	if !ok {
+		ctx.Log.Error("cannot seek in %q", contentPath)
 		ctx.ReplyError(500)
 		return
 	}
-	defer body.Close()
 
-	http.ServeContent(ctx.ResponseWriter, ctx.Request, filePath, modTime, body)
+	if a.Cfg.ETag.Method != "" {
+		etag, err := a.fileETag(contentPath, contentInfo, body, coding)
+		if err != nil {
+			ctx.Log.Error("cannot compute entity tag of %q: %v",
+				contentPath, err)
+			ctx.ReplyError(500)
+			return
+		}
+
+		header.Set("ETag", etag)
+	}
+
+	if a.Cfg.CacheControl != nil {
+		if value := a.cacheControl(ctx, name); value != "" {
+			header.Set("Cache-Control", value)
+		}
+	}
+
+	http.ServeContent(ctx.ResponseWriter, ctx.Request, name,
+		contentInfo.ModTime(), body)
+}
+
+func (a *ServeAction) fileETag(filePath string, info fs.FileInfo, body io.ReadSeeker, coding string) (string, error) {
+	var etag string
+
+	switch a.Cfg.ETag.Method {
+	case FileETagMethodMTimeSize:
+		etag = FileETag(info)
+
+	case FileETagMethodContentHash:
+		var err error
+		etag, err = a.etagCache.ETag(filePath, info, body)
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+
+	// Each precompressed variant is a different representation and must have
+	// its own entity tag.
+	if coding != "" {
+		etag = etag[:len(etag)-1] + "-" + coding + `"`
+	}
+
+	return etag, nil
+}
+
+func (a *ServeAction) cacheControl(ctx *RequestContext, name string) string {
+	cfg := a.Cfg.CacheControl
+
+	// Path rules apply to the path of the file relative to the base
+	// directory, whatever the request path is.
+	filePath := path.Join("/", name)
+
+	var mediaType *MediaType
+	contentType := ctx.ResponseWriter.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if contentType != "" {
+		var t MediaType
+		if err := t.Parse(contentType); err == nil {
+			mediaType = &t
+		}
+	}
+
+	for _, rule := range cfg.Rules {
+		if rule.Matches(filePath, mediaType) {
+			return rule.Value
+		}
+	}
+
+	return cfg.Default
+}
+
+var precompressedFileExtensions = map[string]string{
+	ContentCodingGzip:   ".gz",
+	ContentCodingZstd:   ".zst",
+	ContentCodingBrotli: ".br",
+}
+
+func (a *ServeAction) findPrecompressedVariant(ctx *RequestContext, root *serveRoot, name string) (string, string, fs.FileInfo, bool) {
+	// Return the content coding, name and file information of the best variant
+	// acceptable by the client, and whether there is at least one variant at
+	// all. The content coding is empty if the original file must be used.
+
+	var codings []string
+	infos := make(map[string]fs.FileInfo)
+
+	for _, coding := range a.Cfg.Precompressed.Codings {
+		variantName := name + precompressedFileExtensions[coding]
+
+		info, err := a.stat(root, variantName)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		codings = append(codings, coding)
+		infos[coding] = info
+	}
+
+	if len(codings) == 0 {
+		return "", "", nil, false
+	}
+
+	coding := NegotiateContentCoding(ctx.AcceptedContentCodings(), codings)
+	if coding == "" {
+		return "", "", nil, true
+	}
+
+	variantName := name + precompressedFileExtensions[coding]
+	return coding, variantName, infos[coding], true
+}
+
+func (a *ServeAction) setContentType(header http.Header, root *serveRoot, name string) error {
+	// Same logic as http.ServeContent: use the file extension if possible and
+	// sniff the content otherwise.
+
+	if header.Get("Content-Type") != "" {
+		return nil
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+
+	if contentType == "" {
+		file, err := root.FS.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		buf := make([]byte, 512)
+		n, err := io.ReadFull(file, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return err
+		}
+
+		contentType = http.DetectContentType(buf[:n])
+	}
+
+	header.Set("Content-Type", contentType)
+	return nil
+}
+
+// The first media type is the default one when the client does not express any
+// preference.
+var serveIndexMediaTypes = []*MediaType{
+	MediaTypeText,
+	MediaTypeHTML,
+	MediaTypeJSON,
+}
+
+type ServeIndexData struct {
+	DirectoryPath string            `json:"directory_path"`
+	Readme        string            `json:"readme,omitempty"`
+	Entries       []ServeIndexEntry `json:"entries"`
+	NbEntries     int               `json:"nb_entries"`
+	Truncated     bool              `json:"truncated,omitempty"`
+
+	Sort     string `json:"sort"`
+	Order    string `json:"order"`
+	Page     int    `json:"page"`
+	NbPages  int    `json:"nb_pages"`
+	PageSize int    `json:"page_size,omitempty"`
+
+	SortQueries   map[string]string `json:"-"`
+	PrevPageQuery string            `json:"-"`
+	NextPageQuery string            `json:"-"`
+
+	MaxDisplayedFilenameLength int `json:"-"`
+	MTimeLength                int `json:"-"`
+	MaxDisplayedSizeLength     int `json:"-"`
+}
+
+type ServeIndexEntry struct {
+	Filename          string `json:"filename"`
+	DisplayedFilename string `json:"-"`
+	Directory         bool   `json:"directory,omitempty"`
+	Size              int64  `json:"size,omitempty"`
+	DisplayedSize     string `json:"displayed_size,omitempty"`
+	MTime             string `json:"mtime,omitempty"`
+
+	modTime time.Time
 }
 
-func (a *ServeAction) serveIndexView(dirPath string, ctx *RequestContext) {
-	entries, err := a.readIndexEntries(dirPath)
+func (a *ServeAction) serveIndexView(ctx *RequestContext, root *serveRoot, dirName string) {
+	data := ServeIndexData{
+		Sort:     "name",
+		Order:    "asc",
+		Page:     1,
+		PageSize: a.Cfg.IndexView.PageSize,
+
+		MTimeLength: len(ServeActionTimestampLayout),
+	}
+
+	query := ctx.Request.URL.Query()
+	if err := data.parseQuery(query); err != nil {
+		ctx.ReplyError2(400, "invalid query: %v", err)
+		return
+	}
+
+	entries, err := a.readIndexEntries(root, dirName)
 	if err != nil {
 		ctx.Log.Error("cannot read index entries: %v", err)
 		ctx.ReplyError(500)
 		return
 	}
 
+	sortIndexEntries(entries, data.Sort, data.Order)
+
+	// The limit applies to visible entries once sorted, so that the index
+	// always starts with the same entries for a given sort order.
+	truncated := len(entries) > a.Cfg.IndexView.MaxFiles
+	if truncated {
+		ctx.Log.Info("directory %q contains more than %d files, truncating "+
+			"index", root.FilePath(dirName), a.Cfg.IndexView.MaxFiles)
+		entries = entries[:a.Cfg.IndexView.MaxFiles]
+	}
+
 	// Let us not leak the full server-side path
-	relDirPath := ctx.Subpath
-	if relDirPath == "" {
-		relDirPath = "."
+	data.DirectoryPath = ctx.Subpath
+	if data.DirectoryPath == "" {
+		data.DirectoryPath = "."
 	}
 
-	tplData := struct {
-		DirectoryPath string            `json:"directory_path"`
-		Entries       []ServeIndexEntry `json:"entries"`
+	data.Readme = a.readIndexReadme(ctx, root, dirName)
+	data.NbEntries = len(entries)
+	data.Truncated = truncated
 
-		MaxDisplayedFilenameLength int `json:"-"`
-		MTimeLength                int `json:"-"`
-		MaxDisplayedSizeLength     int `json:"-"`
-	}{
-		DirectoryPath: relDirPath,
-		Entries:       entries,
+	data.NbPages = 1
+	if data.PageSize > 0 {
+		data.NbPages = max(1, (len(entries)+data.PageSize-1)/data.PageSize)
 
-		MTimeLength: len(ServeActionTimestampLayout),
+		start := min((data.Page-1)*data.PageSize, len(entries))
+		end := min(start+data.PageSize, len(entries))
+		entries = entries[start:end]
 	}
 
+	data.Entries = entries
+	data.initQueries(query)
+
 	for _, e := range entries {
-		tplData.MaxDisplayedFilenameLength =
-			max(tplData.MaxDisplayedFilenameLength, len(e.Filename))
-		tplData.MaxDisplayedSizeLength = max(tplData.MaxDisplayedSizeLength,
+		data.MaxDisplayedFilenameLength =
+			max(data.MaxDisplayedFilenameLength, len(e.DisplayedFilename))
+		data.MaxDisplayedSizeLength = max(data.MaxDisplayedSizeLength,
 			len(e.DisplayedSize))
 	}
 
-	content, err := a.serveView.Render("index", tplData, ctx)
+	// Unlike other views, the index view announces the format it selected
+	// since clients can ask for JSON listings.
+	mediaType := ctx.NegotiateMediaType(serveIndexMediaTypes)
+
+	content, err := a.serveView.RenderMediaType(mediaType, "index", &data,
+		ctx)
 	if err != nil {
 		ctx.Log.Error("cannot render index data: %v", err)
 		ctx.ReplyError(500)
 		return
 	}
 
+	ctx.ResponseWriter.Header().Set("Content-Type", mediaType.String())
+
 	ctx.Reply(200, bytes.NewReader(content))
 }
 
-type ServeIndexEntry struct {
-	Filename          string `json:"filename"`
-	DisplayedFilename string `json:"-"`
-	Directory         bool   `json:"directory,omitempty"`
-	Size              int64  `json:"size,omitempty"`
-	DisplayedSize     string `json:"displayed_size,omitempty"`
-	MTime             string `json:"mtime,omitempty"`
+func (data *ServeIndexData) parseQuery(query url.Values) error {
+	if s := query.Get("sort"); s != "" {
+		if !slices.Contains(ServeIndexSortKeys, s) {
+			return fmt.Errorf("invalid sort key %q", s)
+		}
+
+		data.Sort = s
+	}
+
+	if s := query.Get("order"); s != "" {
+		if s != "asc" && s != "desc" {
+			return fmt.Errorf("invalid sort order %q", s)
+		}
+
+		data.Order = s
+	}
+
+	if s := query.Get("page"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 1 {
+			return fmt.Errorf("invalid page %q", s)
+		}
+
+		data.Page = i
+	}
+
+	if s := query.Get("page_size"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 1 {
+			return fmt.Errorf("invalid page size %q", s)
+		}
+
+		data.PageSize = i
+	}
+
+	return nil
 }
 
-func (a *ServeAction) readIndexEntries(dirPath string) ([]ServeIndexEntry, error) {
-	dirEntries, err := os.ReadDir(dirPath)
+func (data *ServeIndexData) initQueries(query url.Values) {
+	// Build the query strings used for links in HTML pages. They preserve the
+	// page size selected by the client if there is one.
+
+	newQuery := func(sortKey, order string, page int) string {
+		q := make(url.Values)
+
+		q.Set("sort", sortKey)
+		q.Set("order", order)
+
+		if page > 1 {
+			q.Set("page", strconv.Itoa(page))
+		}
+
+		if s := query.Get("page_size"); s != "" {
+			q.Set("page_size", s)
+		}
+
+		return "?" + q.Encode()
+	}
+
+	data.SortQueries = make(map[string]string)
+	for _, key := range ServeIndexSortKeys {
+		order := "asc"
+		if key == data.Sort && data.Order == "asc" {
+			order = "desc"
+		}
+
+		data.SortQueries[key] = newQuery(key, order, 1)
+	}
+
+	if data.Page > 1 {
+		data.PrevPageQuery = newQuery(data.Sort, data.Order,
+			min(data.Page-1, data.NbPages))
+	}
+
+	if data.Page < data.NbPages {
+		data.NextPageQuery = newQuery(data.Sort, data.Order, data.Page+1)
+	}
+}
+
+var ServeIndexSortKeys = []string{"name", "size", "mtime"}
+
+func sortIndexEntries(entries []ServeIndexEntry, sortKey, order string) {
+	slices.SortStableFunc(entries, func(e1, e2 ServeIndexEntry) int {
+		var c int
+
+		switch sortKey {
+		case "size":
+			c = cmp.Compare(e1.Size, e2.Size)
+		case "mtime":
+			c = e1.modTime.Compare(e2.modTime)
+		}
+
+		if c == 0 {
+			c = strings.Compare(e1.Filename, e2.Filename)
+		}
+
+		if order == "desc" {
+			c = -c
+		}
+
+		return c
+	})
+}
+
+func (a *ServeAction) readIndexEntries(root *serveRoot, dirName string) ([]ServeIndexEntry, error) {
+	cfg := a.Cfg.IndexView
+	dirPath := root.FilePath(dirName)
+
+	file, err := root.FS.Open(dirName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open directory %q: %w",
+			dirPath, err)
+	}
+	defer file.Close()
+
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		return nil, fmt.Errorf("cannot read directory %q", dirPath)
+	}
+
+	dirEntries, err := dir.ReadDir(-1)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read directory %q: %v", dirPath, err)
+		return nil, fmt.Errorf("cannot read directory %q: %w",
+			dirPath, err)
 	}
 
-	idxEntries := make([]ServeIndexEntry, len(dirEntries))
-	for i, de := range dirEntries {
+	idxEntries := make([]ServeIndexEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		if cfg.HideHiddenFiles && strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+
+		name := path.Join(dirName, de.Name())
+		if err := a.checkAccess(root, name); err != nil {
+			continue
+		}
+
 		ie := ServeIndexEntry{
 			Filename: de.Name(),
 		}
@@ -306,16 +1129,61 @@ func (a *ServeAction) readIndexEntries(dirPath string) ([]ServeIndexEntry, error
 				ie.DisplayedSize = a.formatFileSize(ie.Size)
 			}
 
-			mtime := info.ModTime()
-			ie.MTime = mtime.UTC().Format(ServeActionTimestampLayout)
+			ie.modTime = info.ModTime()
+			ie.MTime = ie.modTime.UTC().Format(ServeActionTimestampLayout)
 		}
 
-		idxEntries[i] = ie
+		idxEntries = append(idxEntries, ie)
 	}
 
 	return idxEntries, nil
 }
 
+func (a *ServeAction) readIndexReadme(ctx *RequestContext, root *serveRoot, dirName string) string {
+	for _, filename := range a.Cfg.IndexView.ReadmeFiles {
+		name := path.Join(dirName, filename)
+
+		if err := a.checkAccess(root, name); err != nil {
+			continue
+		}
+
+		data, err := readIndexReadmeFile(root.FS, name)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				ctx.Log.Error("cannot read %q: %v", root.FilePath(name), err)
+			}
+
+			continue
+		}
+
+		return data
+	}
+
+	return ""
+}
+
+func readIndexReadmeFile(fsys fs.FS, name string) (string, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	} else if !info.Mode().IsRegular() {
+		return "", fs.ErrNotExist
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, ServeIndexReadmeMaxSize))
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
 func (a *ServeAction) formatFileSize(size int64) string {
 	switch {
 	case size < 1_000:
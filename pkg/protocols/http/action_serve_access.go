@@ -0,0 +1,199 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"go.n16f.net/bcl"
+)
+
+const (
+	ServeSymlinkPolicyNone       = "none"
+	ServeSymlinkPolicyOwnerMatch = "owner_match"
+	ServeSymlinkPolicyInsideRoot = "inside_root"
+	ServeSymlinkPolicyAll        = "all"
+
+	ServeHiddenFilePolicyDeny   = "deny"
+	ServeHiddenFilePolicyIgnore = "ignore"
+	ServeHiddenFilePolicyAllow  = "allow"
+)
+
+var errServeAccessDenied = errors.New("access denied")
+
+// ServeActionDenyPattern is either a glob pattern or a regular expression.
+// Glob patterns containing a '/' character are matched against the path of the
+// file relative to the base directory (e.g. "/private/*.txt"); other glob
+// patterns are matched against each segment of the path (e.g. "*.bak").
+// Regular expressions are always matched against the path.
+type ServeActionDenyPattern struct {
+	Glob   string
+	Regexp *regexp.Regexp
+}
+
+// bcl.ValueReader
+func (p *ServeActionDenyPattern) ReadBCLValue(v *bcl.Value) error {
+	if v.Type() != bcl.ValueTypeString {
+		return bcl.NewValueTypeError(v, bcl.ValueTypeString)
+	}
+
+	s := v.Content.(bcl.String)
+
+	if s.Sigil == "re" {
+		re, err := regexp.Compile(s.String)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression: %w", err)
+		}
+
+		p.Regexp = re
+		return nil
+	}
+
+	if _, err := path.Match(s.String, ""); err != nil {
+		return fmt.Errorf("invalid glob pattern: %w", err)
+	}
+
+	p.Glob = s.String
+	return nil
+}
+
+func (p *ServeActionDenyPattern) Match(filePath string) bool {
+	if p.Regexp != nil {
+		return p.Regexp.MatchString(filePath)
+	}
+
+	if strings.Contains(p.Glob, "/") {
+		matched, _ := path.Match(p.Glob, filePath)
+		return matched
+	}
+
+	for segment := range strings.SplitSeq(strings.Trim(filePath, "/"), "/") {
+		if matched, _ := path.Match(p.Glob, segment); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *ServeAction) stat(root *serveRoot, name string) (fs.FileInfo, error) {
+	if err := a.checkAccess(root, name); err != nil {
+		return nil, err
+	}
+
+	return fs.Stat(root.FS, name)
+}
+
+// checkAccess returns errServeAccessDenied if the file must not be served, or
+// an error wrapping fs.ErrNotExist if the file must be treated as missing.
+func (a *ServeAction) checkAccess(root *serveRoot, name string) error {
+	if name == "." {
+		return nil
+	}
+
+	filePath := "/" + name
+
+	for _, pattern := range a.Cfg.DenyPatterns {
+		if pattern.Match(filePath) {
+			return errServeAccessDenied
+		}
+	}
+
+	if a.Cfg.HiddenFiles != ServeHiddenFilePolicyAllow &&
+		isHiddenFilePath(name) {
+		if a.Cfg.HiddenFiles == ServeHiddenFilePolicyDeny {
+			return errServeAccessDenied
+		}
+
+		return fs.ErrNotExist
+	}
+
+	// Archives do not contain symbolic links
+	if root.Dir != "" && a.Cfg.FollowSymlinks != ServeSymlinkPolicyAll {
+		return a.checkSymlinks(root, name)
+	}
+
+	return nil
+}
+
+func (a *ServeAction) checkSymlinks(root *serveRoot, name string) error {
+	// Each segment of the path can be a symbolic link, so we have to check
+	// all of them.
+
+	var rootRealPath string
+
+	filePath := root.Dir
+
+	for segment := range strings.SplitSeq(name, "/") {
+		filePath = filepath.Join(filePath, segment)
+
+		info, err := os.Lstat(filePath)
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&fs.ModeSymlink == 0 {
+			continue
+		}
+
+		switch a.Cfg.FollowSymlinks {
+		case ServeSymlinkPolicyNone:
+			return errServeAccessDenied
+
+		case ServeSymlinkPolicyOwnerMatch:
+			targetInfo, err := os.Stat(filePath)
+			if err != nil {
+				return err
+			}
+
+			if fileOwner(info) != fileOwner(targetInfo) {
+				return errServeAccessDenied
+			}
+
+		case ServeSymlinkPolicyInsideRoot:
+			if rootRealPath == "" {
+				rootRealPath, err = filepath.EvalSymlinks(root.Dir)
+				if err != nil {
+					return err
+				}
+			}
+
+			realPath, err := filepath.EvalSymlinks(filePath)
+			if err != nil {
+				return err
+			}
+
+			relPath, err := filepath.Rel(rootRealPath, realPath)
+			if err != nil || relPath == ".." ||
+				strings.HasPrefix(relPath, "../") {
+				return errServeAccessDenied
+			}
+		}
+	}
+
+	return nil
+}
+
+func isHiddenFilePath(name string) bool {
+	for segment := range strings.SplitSeq(name, "/") {
+		if strings.HasPrefix(segment, ".") && segment != "." {
+			return true
+		}
+	}
+
+	return false
+}
+
+func fileOwner(info fs.FileInfo) int {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(stat.Uid)
+	}
+
+	return -1
+}
@@ -17,7 +17,8 @@ var statusMediaTypes = []*MediaType{
 }
 
 type StatusData struct {
-	Servers []*boulevard.ServerStatus `json:"servers"`
+	Servers       []*boulevard.ServerStatus       `json:"servers"`
+	LoadBalancers []*boulevard.LoadBalancerStatus `json:"load_balancers"`
 }
 
 type StatusActionCfg struct {
@@ -69,8 +70,17 @@ func (a *StatusAction) HandleRequest(ctx *RequestContext) {
 		statuses = append(statuses, statusTable[name])
 	}
 
+	loadBalancers := ctx.Protocol.Server.Cfg.LoadBalancers
+
+	lbStatuses := make([]*boulevard.LoadBalancerStatus, 0,
+		len(loadBalancers))
+	for _, name := range slices.Sorted(maps.Keys(loadBalancers)) {
+		lbStatuses = append(lbStatuses, loadBalancers[name].Status())
+	}
+
 	status := StatusData{
-		Servers: statuses,
+		Servers:       statuses,
+		LoadBalancers: lbStatuses,
 	}
 
 	content, err := a.view.Render("status", &status, ctx)
@@ -0,0 +1,43 @@
+package http
+
+import "go.n16f.net/boulevard/pkg/uwsgi"
+
+type UWSGIActionCfg struct {
+	CGIGatewayCfg
+}
+
+type UWSGIAction struct {
+	Handler *Handler
+	Cfg     *UWSGIActionCfg
+
+	gateway *cgiGateway
+}
+
+func NewUWSGIAction(h *Handler, cfg *UWSGIActionCfg) (*UWSGIAction, error) {
+	gateway, err := newCGIGateway(h, &cfg.CGIGatewayCfg, "uwsgi",
+		uwsgi.Protocol{})
+	if err != nil {
+		return nil, err
+	}
+
+	a := UWSGIAction{
+		Handler: h,
+		Cfg:     cfg,
+
+		gateway: gateway,
+	}
+
+	return &a, nil
+}
+
+func (a *UWSGIAction) Start() error {
+	return a.gateway.start()
+}
+
+func (a *UWSGIAction) Stop() {
+	a.gateway.stop()
+}
+
+func (a *UWSGIAction) HandleRequest(ctx *RequestContext) {
+	a.gateway.handleRequest(ctx)
+}
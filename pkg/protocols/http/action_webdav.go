@@ -0,0 +1,379 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/boulevard/pkg/boulevard"
+	"golang.org/x/net/webdav"
+)
+
+var webDAVWriteMethods = []string{
+	"PUT", "DELETE", "MKCOL", "COPY", "MOVE", "PROPPATCH", "LOCK", "UNLOCK",
+}
+
+type WebDAVActionCfg struct {
+	Path     *boulevard.FormatString
+	ReadOnly bool
+	Writers  []string
+}
+
+func (cfg *WebDAVActionCfg) ReadBCLElement(elt *bcl.Element) error {
+	if elt.IsBlock() {
+		elt.EntryValues("path", &cfg.Path)
+		elt.MaybeEntryValues("read_only", &cfg.ReadOnly)
+
+		for _, entry := range elt.FindEntries("writer") {
+			for i := range entry.NbValues() {
+				var username string
+				if entry.Value(i, &username) {
+					cfg.Writers = append(cfg.Writers, username)
+				}
+			}
+		}
+	} else {
+		elt.Values(&cfg.Path)
+	}
+
+	return nil
+}
+
+type WebDAVAction struct {
+	Handler *Handler
+	Cfg     *WebDAVActionCfg
+
+	// The path is a format string, so each request can use a different root
+	// directory. Locks are only meaningful in the context of a root directory.
+	// Lock systems are dropped once they are not used by any request and do
+	// not contain any active lock.
+	lockSystems      map[string]*webDAVLockSystem
+	lockSystemsMutex sync.Mutex
+}
+
+func NewWebDAVAction(h *Handler, cfg *WebDAVActionCfg) (*WebDAVAction, error) {
+	// Resource URIs are built by removing the part of the request path which
+	// matched the handler; we cannot know which part it is with a regexp.
+	if len(h.Cfg.Match.PathRegexps) > 0 {
+		return nil, fmt.Errorf("webdav action cannot be used in a handler " +
+			"matching path regexps")
+	}
+
+	a := WebDAVAction{
+		Handler: h,
+		Cfg:     cfg,
+
+		lockSystems: make(map[string]*webDAVLockSystem),
+	}
+
+	return &a, nil
+}
+
+func (a *WebDAVAction) Start() error {
+	return nil
+}
+
+func (a *WebDAVAction) Stop() {
+}
+
+func (a *WebDAVAction) HandleRequest(ctx *RequestContext) {
+	req := ctx.Request
+
+	if slices.Contains(webDAVWriteMethods, req.Method) {
+		if a.Cfg.ReadOnly {
+			ctx.ReplyError2(403, "read-only resource")
+			return
+		}
+
+		if len(a.Cfg.Writers) > 0 &&
+			!slices.Contains(a.Cfg.Writers, ctx.Username) {
+			ctx.ReplyError2(403, "write access denied")
+			return
+		}
+	}
+
+	// Variables used in the path may be empty or contain relative paths, and
+	// we certainly do not want to serve the current directory or the parent
+	// of a per-user directory.
+	rootPath := a.Cfg.Path.Expand(ctx.Vars)
+	if !filepath.IsAbs(rootPath) ||
+		slices.Contains(strings.Split(rootPath, "/"), "..") {
+		ctx.Log.Error("invalid WebDAV root path %q", rootPath)
+		ctx.ReplyError(500)
+		return
+	}
+
+	// The WebDAV handler must know the part of the request path which is not
+	// part of the resource path so that it can build the URIs of resources.
+	var prefix string
+	if a.Handler.Cfg.Match.HasPaths() {
+		prefix = webDAVPrefix(req.URL.Path, ctx.Subpath)
+	}
+
+	ls := a.acquireLockSystem(rootPath)
+	defer a.releaseLockSystem(rootPath, ls)
+
+	h := webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: newWebDAVFileSystem(rootPath),
+		LockSystem: ls,
+		Logger: func(req *http.Request, err error) {
+			if err != nil {
+				ctx.Log.Error("cannot handle %s request: %v", req.Method, err)
+			}
+		},
+	}
+
+	h.ServeHTTP(ctx.ResponseWriter, req)
+}
+
+func (a *WebDAVAction) acquireLockSystem(rootPath string) *webDAVLockSystem {
+	a.lockSystemsMutex.Lock()
+	defer a.lockSystemsMutex.Unlock()
+
+	ls, found := a.lockSystems[rootPath]
+	if !found {
+		ls = newWebDAVLockSystem()
+		a.lockSystems[rootPath] = ls
+	}
+
+	ls.refs++
+
+	return ls
+}
+
+func (a *WebDAVAction) releaseLockSystem(rootPath string, ls *webDAVLockSystem) {
+	a.lockSystemsMutex.Lock()
+	defer a.lockSystemsMutex.Unlock()
+
+	ls.refs--
+
+	// Locks can expire without any request, so we also look for other lock
+	// systems which are not needed anymore.
+	now := time.Now()
+
+	for rootPath2, ls2 := range a.lockSystems {
+		if ls2.refs == 0 && !ls2.hasLocks(now) {
+			delete(a.lockSystems, rootPath2)
+		}
+	}
+}
+
+// webDAVPrefix returns the part of a request path matched by the handler, i.e.
+// the path without the last segments which make up the subpath.
+func webDAVPrefix(uriPath, subpath string) string {
+	segments := strings.Split(strings.Trim(uriPath, "/"), "/")
+
+	if subpath != "" {
+		n := strings.Count(subpath, "/") + 1
+		segments = segments[:max(len(segments)-n, 0)]
+	}
+
+	prefix := strings.Join(segments, "/")
+	if prefix == "" {
+		return ""
+	}
+
+	return "/" + prefix
+}
+
+// webDAVLockSystem keeps track of the locks created in a memory lock system
+// so that we know when it can be dropped.
+type webDAVLockSystem struct {
+	webdav.LockSystem
+
+	refs int // protected by WebDAVAction.lockSystemsMutex
+
+	expirations      map[string]time.Time // zero for infinite timeouts
+	expirationsMutex sync.Mutex
+}
+
+func newWebDAVLockSystem() *webDAVLockSystem {
+	ls := webDAVLockSystem{
+		LockSystem: webdav.NewMemLS(),
+
+		expirations: make(map[string]time.Time),
+	}
+
+	return &ls
+}
+
+func (ls *webDAVLockSystem) hasLocks(now time.Time) bool {
+	ls.expirationsMutex.Lock()
+	defer ls.expirationsMutex.Unlock()
+
+	for token, expiration := range ls.expirations {
+		if !expiration.IsZero() && !now.Before(expiration) {
+			delete(ls.expirations, token)
+		}
+	}
+
+	return len(ls.expirations) > 0
+}
+
+func (ls *webDAVLockSystem) setExpiration(token string, now time.Time, duration time.Duration) {
+	ls.expirationsMutex.Lock()
+	defer ls.expirationsMutex.Unlock()
+
+	var expiration time.Time
+	if duration >= 0 {
+		expiration = now.Add(duration)
+	}
+
+	ls.expirations[token] = expiration
+}
+
+func (ls *webDAVLockSystem) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	token, err := ls.LockSystem.Create(now, details)
+	if err != nil {
+		return "", err
+	}
+
+	ls.setExpiration(token, now, details.Duration)
+
+	return token, nil
+}
+
+func (ls *webDAVLockSystem) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+	details, err := ls.LockSystem.Refresh(now, token, duration)
+	if err != nil {
+		return details, err
+	}
+
+	ls.setExpiration(token, now, duration)
+
+	return details, nil
+}
+
+func (ls *webDAVLockSystem) Unlock(now time.Time, token string) error {
+	if err := ls.LockSystem.Unlock(now, token); err != nil {
+		return err
+	}
+
+	ls.expirationsMutex.Lock()
+	delete(ls.expirations, token)
+	ls.expirationsMutex.Unlock()
+
+	return nil
+}
+
+// webDAVFileSystem is a webdav.Dir which refuses to access files through
+// symbolic links resolving outside of the root directory. Symbolic links
+// within the root directory are followed as usual.
+type webDAVFileSystem struct {
+	dir      webdav.Dir
+	rootPath string
+}
+
+func newWebDAVFileSystem(rootPath string) *webDAVFileSystem {
+	return &webDAVFileSystem{
+		dir:      webdav.Dir(rootPath),
+		rootPath: rootPath,
+	}
+}
+
+func (wfs *webDAVFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if err := wfs.checkPath("mkdir", name); err != nil {
+		return err
+	}
+
+	return wfs.dir.Mkdir(ctx, name, perm)
+}
+
+func (wfs *webDAVFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if err := wfs.checkPath("open", name); err != nil {
+		return nil, err
+	}
+
+	return wfs.dir.OpenFile(ctx, name, flag, perm)
+}
+
+func (wfs *webDAVFileSystem) RemoveAll(ctx context.Context, name string) error {
+	if err := wfs.checkPath("remove", name); err != nil {
+		return err
+	}
+
+	return wfs.dir.RemoveAll(ctx, name)
+}
+
+func (wfs *webDAVFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	if err := wfs.checkPath("rename", oldName); err != nil {
+		return err
+	}
+
+	if err := wfs.checkPath("rename", newName); err != nil {
+		return err
+	}
+
+	return wfs.dir.Rename(ctx, oldName, newName)
+}
+
+func (wfs *webDAVFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	if err := wfs.checkPath("stat", name); err != nil {
+		return nil, err
+	}
+
+	return wfs.dir.Stat(ctx, name)
+}
+
+func (wfs *webDAVFileSystem) checkPath(op, name string) error {
+	rootPath, err := filepath.EvalSymlinks(wfs.rootPath)
+	if err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(rootPath,
+		filepath.FromSlash(path.Clean("/"+name)))
+
+	resolvedPath, err := resolveExistingPath(filePath)
+	if err != nil {
+		return err
+	}
+
+	if resolvedPath != rootPath &&
+		!strings.HasPrefix(resolvedPath, rootPath+string(filepath.Separator)) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+
+	return nil
+}
+
+// resolveExistingPath evaluates symbolic links in the longest existing part of
+// a path, so that the location of files which are about to be created can be
+// checked. Dangling symbolic links are rejected since creating a file through
+// them would create their target.
+func resolveExistingPath(filePath string) (string, error) {
+	var missingPath string
+
+	for {
+		resolvedPath, err := filepath.EvalSymlinks(filePath)
+		if err == nil {
+			return filepath.Join(resolvedPath, missingPath), nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		if _, err := os.Lstat(filePath); err == nil {
+			return "", &fs.PathError{Op: "resolve", Path: filePath,
+				Err: fs.ErrPermission}
+		}
+
+		parentPath := filepath.Dir(filePath)
+		if parentPath == filePath {
+			return "", err
+		}
+
+		missingPath = filepath.Join(filepath.Base(filePath), missingPath)
+		filePath = parentPath
+	}
+}
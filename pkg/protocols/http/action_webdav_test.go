@@ -0,0 +1,137 @@
+package http
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestWebDAVPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		path    string
+		subpath string
+		prefix  string
+	}{
+		{"/", "", ""},
+		{"/a.txt", "a.txt", ""},
+		{"/dav", "", "/dav"},
+		{"/dav/", "", "/dav"},
+		{"/dav/a.txt", "a.txt", "/dav"},
+		{"/dav/docs/", "docs", "/dav"},
+		{"/a/b/c/d.txt", "c/d.txt", "/a/b"},
+	}
+
+	for _, test := range tests {
+		prefix := webDAVPrefix(test.path, test.subpath)
+		assert.Equal(test.prefix, prefix, test.path+" "+test.subpath)
+	}
+}
+
+func TestWebDAVLockSystem(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+
+	ls := newWebDAVLockSystem()
+	assert.False(ls.hasLocks(now))
+
+	token1, err := ls.Create(now, webdav.LockDetails{
+		Root:     "/a",
+		Duration: time.Minute,
+	})
+	assert.NoError(err)
+
+	token2, err := ls.Create(now, webdav.LockDetails{
+		Root:     "/b",
+		Duration: -1,
+	})
+	assert.NoError(err)
+	assert.True(ls.hasLocks(now))
+
+	assert.NoError(ls.Unlock(now, token2))
+	assert.True(ls.hasLocks(now))
+
+	_, err = ls.Refresh(now, token1, 2*time.Minute)
+	assert.NoError(err)
+	assert.True(ls.hasLocks(now.Add(90 * time.Second)))
+	assert.False(ls.hasLocks(now.Add(2 * time.Minute)))
+}
+
+func TestWebDAVFileSystem(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+
+	rootPath := t.TempDir()
+	outsidePath := t.TempDir()
+
+	writeFile := func(filePath, content string) {
+		err := os.WriteFile(filePath, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	symlink := func(target, linkPath string) {
+		if err := os.Symlink(target, linkPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(filepath.Join(rootPath, "a.txt"), "a")
+	writeFile(filepath.Join(outsidePath, "secret.txt"), "secret")
+
+	symlink("a.txt", filepath.Join(rootPath, "inside"))
+	symlink(outsidePath, filepath.Join(rootPath, "outside"))
+	symlink(filepath.Join(outsidePath, "secret.txt"),
+		filepath.Join(rootPath, "secret"))
+	symlink(filepath.Join(outsidePath, "new.txt"),
+		filepath.Join(rootPath, "dangling"))
+
+	wfs := newWebDAVFileSystem(rootPath)
+
+	// Allowed
+	for _, name := range []string{"/", "/a.txt", "/inside", "/../a.txt"} {
+		_, err := wfs.Stat(ctx, name)
+		assert.NoError(err, name)
+	}
+
+	f, err := wfs.OpenFile(ctx, "/b.txt", os.O_WRONLY|os.O_CREATE, 0600)
+	if assert.NoError(err) {
+		f.Close()
+	}
+
+	assert.NoError(wfs.Mkdir(ctx, "/dir", 0700))
+	assert.NoError(wfs.Rename(ctx, "/b.txt", "/dir/b.txt"))
+
+	// Rejected
+	for _, name := range []string{"/outside", "/outside/secret.txt",
+		"/secret", "/dangling"} {
+		_, err := wfs.Stat(ctx, name)
+		assert.ErrorIs(err, fs.ErrPermission, name)
+
+		_, err = wfs.OpenFile(ctx, name, os.O_RDONLY, 0)
+		assert.ErrorIs(err, fs.ErrPermission, name)
+	}
+
+	_, err = wfs.OpenFile(ctx, "/dangling", os.O_WRONLY|os.O_CREATE, 0600)
+	assert.ErrorIs(err, fs.ErrPermission)
+	assert.NoFileExists(filepath.Join(outsidePath, "new.txt"))
+
+	assert.ErrorIs(wfs.Mkdir(ctx, "/outside/dir", 0700), fs.ErrPermission)
+	assert.NoDirExists(filepath.Join(outsidePath, "dir"))
+
+	assert.ErrorIs(wfs.Rename(ctx, "/a.txt", "/outside/a.txt"),
+		fs.ErrPermission)
+	assert.ErrorIs(wfs.RemoveAll(ctx, "/outside/secret.txt"),
+		fs.ErrPermission)
+	assert.FileExists(filepath.Join(outsidePath, "secret.txt"))
+}
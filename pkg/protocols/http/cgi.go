@@ -0,0 +1,113 @@
+package http
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func compileCGIScriptRegexp(s string) (*regexp.Regexp, error) {
+	if s[0] != '^' && s[0] != '/' {
+		return nil, fmt.Errorf("script regexp must match an absolute path")
+	}
+
+	if s[0] != '^' {
+		s = "^" + s
+	}
+
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse script regexp: %w", err)
+	}
+
+	return re, nil
+}
+
+// cgiMetaVariables returns the meta-variables used by CGI-like protocols
+// (CGI, FastCGI, SCGI, uWSGI).
+func cgiMetaVariables(ctx *RequestContext, scriptRE *regexp.Regexp, defaultScript, basePath string, reqBodySize int64) map[string]string {
+	req := ctx.Request
+	header := req.Header
+
+	var pathInfo string
+	var scriptName string // must not start with '/'
+
+	subpath := ctx.Subpath // relative
+	if subpath == "" {
+		subpath = defaultScript
+	}
+
+	if scriptRE == nil {
+		scriptName = subpath
+		pathInfo = "/"
+	} else {
+		if match := scriptRE.FindString("/" + subpath); match == "" {
+			scriptName = subpath
+			pathInfo = "/"
+		} else {
+			scriptName = strings.TrimPrefix(match, "/")
+			pathInfo = path.Join("/", strings.TrimPrefix("/"+subpath, match))
+		}
+	}
+
+	serverPort := ctx.Listener.Port
+
+	buildId := ctx.Protocol.Server.Cfg.BoulevardBuildId
+
+	if basePath == "" {
+		basePath = "/"
+	}
+
+	vars := map[string]string{
+		// RFC 3875 4.1. Request Meta-Variables
+		"CONTENT_LENGTH":    strconv.FormatInt(reqBodySize, 10),
+		"CONTENT_TYPE":      header.Get("Content-Type"),
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"PATH_INFO":         pathInfo,
+		"PATH_TRANSLATED":   path.Join(basePath, pathInfo),
+		"QUERY_STRING":      req.URL.RawQuery,
+		"REMOTE_ADDR":       ctx.ClientAddress.String(),
+		"REMOTE_HOST":       ctx.ClientAddress.String(),  // [1]
+		"REQUEST_METHOD":    strings.ToUpper(req.Method), // [2]
+		"SCRIPT_NAME":       scriptName,
+		"SERVER_NAME":       ctx.Host,
+		"SERVER_PORT":       strconv.Itoa(serverPort),
+		"SERVER_PROTOCOL":   req.Proto,
+		"SERVER_SOFTWARE":   "boulevard/" + buildId,
+
+		// Required for php-fpm
+		"SCRIPT_FILENAME": path.Join(basePath, scriptName),
+	}
+
+	// [1] 4.1.9. REMOTE_HOST: "If the hostname is not available for performance
+	// reasons or otherwise, the server MAY substitute the REMOTE_ADDR value".
+	// Because no, we are not going to do a reverse DNS lookup for each request.
+	//
+	// [2] 4.1.12. REQUEST_METHOD: "The method is case sensitive".
+
+	if scheme, _, ok := strings.Cut(header.Get("Authorization"), " "); ok {
+		vars["AUTH_TYPE"] = scheme
+	}
+
+	if username, _, ok := req.BasicAuth(); ok {
+		vars["REMOTE_USER"] = username
+	}
+
+	// RFC 3875 4.1.18. Protocol-Specific Meta-Variables
+	for name, values := range header {
+		// The Proxy header field would become HTTP_PROXY, which most HTTP
+		// clients use to select an outbound proxy (httpoxy, CVE-2016-5385).
+		if strings.EqualFold(name, "Proxy") {
+			continue
+		}
+
+		name = "HTTP_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		value := strings.Join(values, ", ")
+
+		vars[name] = value
+	}
+
+	return vars
+}
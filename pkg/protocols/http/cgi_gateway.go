@@ -0,0 +1,287 @@
+package http
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/boulevard/pkg/boulevard"
+	"go.n16f.net/boulevard/pkg/cgigateway"
+	"go.n16f.net/boulevard/pkg/netutils"
+	"go.n16f.net/log"
+)
+
+// CGIGatewayCfg contains the settings of actions forwarding requests to
+// servers using a protocol based on CGI meta-variables, i.e. SCGI and uwsgi.
+type CGIGatewayCfg struct {
+	Address        string
+	Parameters     map[string]string
+	Path           string
+	DefaultScript  string
+	ScriptRegexp   string
+	MaxConnections *int
+
+	TemporaryDirectory string
+
+	RequestBodyMemoryBufferSize *int64
+	MaxRequestBodySize          *int64
+
+	ResponseBodyMemoryBufferSize *int64
+	MaxResponseBodySize          *int64
+
+	ConnectionTimeout *time.Duration
+	RequestTimeout    *time.Duration
+}
+
+func (cfg *CGIGatewayCfg) ReadBCLElement(block *bcl.Element) error {
+	block.EntryValues("address",
+		bcl.WithValueValidation(&cfg.Address, netutils.ValidateBCLAddress))
+
+	cfg.Parameters = make(map[string]string)
+	for _, entry := range block.FindEntries("parameter") {
+		var name, value string
+		if entry.Values(&name, &value) {
+			cfg.Parameters[name] = value
+		}
+	}
+
+	block.MaybeEntryValues("path", &cfg.Path)
+	block.MaybeEntryValues("default_script", &cfg.DefaultScript)
+	block.MaybeEntryValues("script_regexp", &cfg.ScriptRegexp)
+
+	block.MaybeEntryValues("max_connections",
+		bcl.WithValueValidation(&cfg.MaxConnections,
+			bcl.ValidatePositiveInteger))
+
+	block.MaybeEntryValues("temporary_directory", &cfg.TemporaryDirectory)
+
+	block.MaybeEntryValues("request_body_memory_buffer_size",
+		bcl.WithValueValidation(&cfg.RequestBodyMemoryBufferSize,
+			bcl.ValidatePositiveInteger))
+	block.MaybeEntryValues("max_request_body_size",
+		bcl.WithValueValidation(&cfg.MaxRequestBodySize,
+			bcl.ValidatePositiveInteger))
+
+	block.MaybeEntryValues("response_body_memory_buffer_size",
+		bcl.WithValueValidation(&cfg.ResponseBodyMemoryBufferSize,
+			bcl.ValidatePositiveInteger))
+	block.MaybeEntryValues("max_response_body_size",
+		bcl.WithValueValidation(&cfg.MaxResponseBodySize,
+			bcl.ValidatePositiveInteger))
+
+	block.MaybeEntryValues("connection_timeout", &cfg.ConnectionTimeout)
+	block.MaybeEntryValues("request_timeout", &cfg.RequestTimeout)
+
+	return nil
+}
+
+type cgiGateway struct {
+	Cfg *CGIGatewayCfg
+	Log *log.Logger
+
+	protocolName string
+	protocol     cgigateway.Protocol
+	client       *cgigateway.Client
+
+	scriptRE *regexp.Regexp
+
+	tmpDirPath string
+
+	reqBodyMemBufSize int64
+	maxReqBodySize    int64
+
+	resBodyMemBufSize int64
+	maxResBodySize    int64
+
+	requestTimeout time.Duration
+}
+
+func newCGIGateway(h *Handler, cfg *CGIGatewayCfg, protocolName string, protocol cgigateway.Protocol) (*cgiGateway, error) {
+	g := cgiGateway{
+		Cfg: cfg,
+		Log: h.Protocol.Log,
+
+		protocolName: protocolName,
+		protocol:     protocol,
+	}
+
+	if s := cfg.ScriptRegexp; s != "" {
+		re, err := compileCGIScriptRegexp(s)
+		if err != nil {
+			return nil, err
+		}
+
+		g.scriptRE = re
+	}
+
+	g.tmpDirPath = cfg.TemporaryDirectory
+	if g.tmpDirPath == "" {
+		dirPath, err := os.MkdirTemp("",
+			"boulevard-"+strings.ToLower(protocolName)+"-*")
+		if err != nil {
+			return nil, fmt.Errorf("cannot create temporary directory: %w", err)
+		}
+
+		g.tmpDirPath = dirPath
+	}
+
+	g.reqBodyMemBufSize = DefaultRequestBodyMemoryBufferSize
+	if size := cfg.RequestBodyMemoryBufferSize; size != nil {
+		g.reqBodyMemBufSize = *size
+	}
+
+	g.maxReqBodySize = DefaultMaxRequestBodySize
+	if size := cfg.MaxRequestBodySize; size != nil {
+		g.maxReqBodySize = *size
+	}
+
+	g.resBodyMemBufSize = DefaultResponseBodyMemoryBufferSize
+	if size := cfg.ResponseBodyMemoryBufferSize; size != nil {
+		g.resBodyMemBufSize = *size
+	}
+
+	g.maxResBodySize = DefaultMaxResponseBodySize
+	if size := cfg.MaxResponseBodySize; size != nil {
+		g.maxResBodySize = *size
+	}
+
+	g.requestTimeout = DefaultRequestTimeout
+	if timeout := cfg.RequestTimeout; timeout != nil {
+		g.requestTimeout = *timeout
+	}
+
+	return &g, nil
+}
+
+func (g *cgiGateway) start() error {
+	clientCfg := cgigateway.ClientCfg{
+		Log:      g.Log,
+		Protocol: g.protocol,
+
+		Address:        g.Cfg.Address,
+		MaxConnections: g.Cfg.MaxConnections,
+	}
+
+	if timeout := g.Cfg.ConnectionTimeout; timeout != nil {
+		clientCfg.ConnectionTimeout = *timeout
+	}
+
+	client, err := cgigateway.NewClient(&clientCfg)
+	if err != nil {
+		return fmt.Errorf("cannot create %s client: %w", g.protocolName, err)
+	}
+
+	g.client = client
+
+	return nil
+}
+
+func (g *cgiGateway) stop() {
+	if g.client != nil {
+		g.client.Close()
+	}
+
+	if err := os.RemoveAll(g.tmpDirPath); err != nil {
+		g.Log.Error("cannot delete directory %q: %v", g.tmpDirPath, err)
+	}
+}
+
+func (g *cgiGateway) handleRequest(ctx *RequestContext) {
+	// As for FastCGI, we have to buffer the request body to compute the
+	// mandatory CONTENT_LENGTH parameter, and the response body to send a
+	// Content-Length header field.
+	reqBodyBuf := g.newSpillBuffer(g.reqBodyMemBufSize, g.maxReqBodySize)
+	defer func() {
+		if err := reqBodyBuf.Close(); err != nil {
+			ctx.Log.Error("cannot close spill buffer: %v", err)
+		}
+	}()
+
+	reqBodySize, err := io.Copy(reqBodyBuf, ctx.Request.Body)
+	if err != nil {
+		if errors.Is(err, boulevard.ErrSpillBufferFull) {
+			ctx.ReplyError(413)
+			return
+		}
+
+		ctx.Log.Error("cannot copy request body: %v", err)
+		ctx.ReplyError(500)
+		return
+	}
+
+	params := cgiMetaVariables(ctx, g.scriptRE, g.Cfg.DefaultScript,
+		g.Cfg.Path, reqBodySize)
+
+	for name, value := range g.Cfg.Parameters {
+		params[name] = value
+	}
+
+	stdin, err := reqBodyBuf.Reader()
+	if err != nil {
+		ctx.Log.Error("cannot read spill buffer: %v", err)
+		ctx.ReplyError(500)
+		return
+	}
+	defer stdin.Close()
+
+	resBodyBuf := g.newSpillBuffer(g.resBodyMemBufSize, g.maxResBodySize)
+	defer func() {
+		if err := resBodyBuf.Close(); err != nil {
+			ctx.Log.Error("cannot close spill buffer: %v", err)
+		}
+	}()
+
+	timeoutCtx, cancelTimeoutCtx := context.WithTimeout(ctx.Ctx,
+		g.requestTimeout)
+	defer cancelTimeoutCtx()
+
+	resHeader, err := g.client.SendRequest(timeoutCtx, params, stdin,
+		resBodyBuf)
+	if err != nil {
+		if !netutils.IsConnectionClosedError(err) {
+			ctx.Log.Error("cannot send %s request: %v", g.protocolName, err)
+		}
+
+		status := 500
+		if errors.Is(err, cgigateway.ErrConnectionLimitReached) {
+			status = 503
+		} else if errors.Is(err, cgigateway.ErrRequestTimeout) {
+			status = 504
+		}
+
+		ctx.ReplyError(status)
+		return
+	}
+
+	resBodyReader, err := resBodyBuf.Reader()
+	if err != nil {
+		ctx.Log.Error("cannot read spill buffer: %v", err)
+		ctx.ReplyError(500)
+		return
+	}
+	defer resBodyReader.Close()
+
+	header := ctx.ResponseWriter.Header()
+	resHeader.CopyToHTTPHeader(header)
+
+	header.Set("Content-Length", strconv.FormatInt(resBodyBuf.Size(), 10))
+
+	statusCode, _ := resHeader.Status()
+	ctx.Reply(statusCode, resBodyReader)
+}
+
+func (g *cgiGateway) newSpillBuffer(memBufSize, maxSize int64) *boulevard.SpillBuffer {
+	fileName := hex.EncodeToString(boulevard.RandomBytes(16))
+	filePath := path.Join(g.tmpDirPath, fileName)
+
+	return boulevard.NewSpillBuffer(filePath, memBufSize, maxSize)
+}
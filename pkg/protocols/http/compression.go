@@ -0,0 +1,352 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"go.n16f.net/bcl"
+	"go.n16f.net/boulevard/pkg/httputils"
+	"go.n16f.net/program"
+)
+
+const (
+	DefaultCompressionMinSize = 1024
+)
+
+var DefaultCompressionMediaTypes = []string{
+	"text/html",
+	"text/plain",
+	"text/css",
+	"text/csv",
+	"text/javascript",
+	"text/xml",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+type CompressionCfg struct {
+	Enabled    bool
+	Codings    []string
+	MediaTypes []*MediaRange
+	MinSize    int64
+}
+
+func (cfg *CompressionCfg) ReadBCLElement(elt *bcl.Element) error {
+	if elt.IsBlock() {
+		cfg.Enabled = true
+
+		for _, entry := range elt.FindEntries("algorithm") {
+			var coding string
+			if entry.CheckValueOneOf(0, ContentCodingGzip, ContentCodingZstd,
+				ContentCodingBrotli) && entry.Values(&coding) {
+				cfg.Codings = append(cfg.Codings, coding)
+			}
+		}
+
+		for _, entry := range elt.FindEntries("media_type") {
+			var s string
+			if entry.Values(&s) {
+				var r MediaRange
+				if err := r.Parse(s); err != nil {
+					entry.AddSimpleValidationError("invalid media type: %v",
+						err)
+					continue
+				}
+
+				cfg.MediaTypes = append(cfg.MediaTypes, &r)
+			}
+		}
+
+		cfg.MinSize = DefaultCompressionMinSize
+		elt.MaybeEntryValues("min_size",
+			bcl.WithValueValidation(&cfg.MinSize, bcl.ValidatePositiveInteger))
+	} else {
+		elt.Values(&cfg.Enabled)
+		cfg.MinSize = DefaultCompressionMinSize
+	}
+
+	if len(cfg.Codings) == 0 {
+		cfg.Codings = ContentCodingValues
+	}
+
+	if len(cfg.MediaTypes) == 0 {
+		for _, s := range DefaultCompressionMediaTypes {
+			var r MediaRange
+			r.Parse(s)
+			cfg.MediaTypes = append(cfg.MediaTypes, &r)
+		}
+	}
+
+	return nil
+}
+
+type compressionEncoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type CompressionWriter struct {
+	Cfg *CompressionCfg
+
+	ctx    *RequestContext
+	w      http.ResponseWriter
+	coding string
+
+	headerWritten bool
+	encoder       compressionEncoder
+
+	// If we do not know the size of the response body, we buffer the first
+	// bytes until we reach the minimum size.
+	pendingStatus int
+	pendingData   []byte
+}
+
+func NewCompressionWriter(cfg *CompressionCfg, ctx *RequestContext, w http.ResponseWriter) *CompressionWriter {
+	cw := CompressionWriter{
+		Cfg: cfg,
+
+		ctx: ctx,
+		w:   w,
+	}
+
+	if ctx.Request.Method != "HEAD" {
+		ranges := ctx.AcceptedContentCodings()
+		cw.coding = NegotiateContentCoding(ranges, cfg.Codings)
+	}
+
+	return &cw
+}
+
+func (cw *CompressionWriter) Header() http.Header {
+	return cw.w.Header()
+}
+
+func (cw *CompressionWriter) WriteHeader(status int) {
+	if cw.headerWritten || httputils.IsInformationalStatus(status) {
+		cw.w.WriteHeader(status)
+		return
+	}
+
+	cw.headerWritten = true
+
+	header := cw.w.Header()
+
+	if cw.isResponseCompressible(status, header) {
+		// The response would be compressed for a client accepting one of the
+		// supported codings, so caches must know that it varies.
+		httputils.AddVaryFieldName(header, "Accept-Encoding")
+
+		if cw.coding != "" {
+			size, known := cw.responseSize(header)
+
+			switch {
+			case !known:
+				cw.pendingStatus = status
+				return
+
+			case size >= cw.Cfg.MinSize:
+				cw.startEncoder(header)
+			}
+		}
+	}
+
+	cw.w.WriteHeader(status)
+}
+
+func (cw *CompressionWriter) Write(data []byte) (int, error) {
+	if !cw.headerWritten {
+		cw.WriteHeader(200)
+	}
+
+	if cw.pendingStatus != 0 {
+		cw.pendingData = append(cw.pendingData, data...)
+
+		if int64(len(cw.pendingData)) < cw.Cfg.MinSize {
+			return len(data), nil
+		}
+
+		if err := cw.writePendingResponse(true); err != nil {
+			return 0, err
+		}
+
+		return len(data), nil
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(data)
+	}
+
+	return cw.w.Write(data)
+}
+
+func (cw *CompressionWriter) Flush() {
+	// If the response is flushed before we know if it is large enough, we
+	// assume it is going to be streamed and compress it.
+	if cw.pendingStatus != 0 {
+		if err := cw.writePendingResponse(true); err != nil {
+			cw.ctx.Log.Error("cannot write response body: %v", err)
+		}
+	}
+
+	if cw.encoder != nil {
+		if err := cw.encoder.Flush(); err != nil {
+			cw.ctx.Log.Error("cannot flush %s encoder: %v", cw.coding, err)
+		}
+	}
+
+	if f, ok := cw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *CompressionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil,
+			fmt.Errorf("response writer does not support connection hijacking")
+	}
+
+	return hijacker.Hijack()
+}
+
+func (cw *CompressionWriter) Close() error {
+	if cw.pendingStatus != 0 {
+		// The response body is smaller than the minimum size
+		if err := cw.writePendingResponse(false); err != nil {
+			return err
+		}
+	}
+
+	if cw.encoder == nil {
+		return nil
+	}
+
+	if err := cw.encoder.Close(); err != nil {
+		return fmt.Errorf("cannot close %s encoder: %w", cw.coding, err)
+	}
+
+	return nil
+}
+
+func (cw *CompressionWriter) isResponseCompressible(status int, header http.Header) bool {
+	switch {
+	case status < 200:
+		return false
+	case status == 204 || status == 206 || status == 304:
+		return false
+	}
+
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	if header.Get("Content-Range") != "" {
+		return false
+	}
+
+	// RFC 9111 5.2.2.6. no-transform
+	cacheControl := httputils.SplitTokenList(header.Get("Cache-Control"), true)
+	if slices.Contains(cacheControl, "no-transform") {
+		return false
+	}
+
+	var mediaType MediaType
+	if err := mediaType.Parse(header.Get("Content-Type")); err != nil {
+		return false
+	}
+
+	mediaType.Type = strings.ToLower(mediaType.Type)
+	mediaType.Subtype = strings.ToLower(mediaType.Subtype)
+
+	return slices.ContainsFunc(cw.Cfg.MediaTypes, func(r *MediaRange) bool {
+		return r.Matches(&mediaType)
+	})
+}
+
+func (cw *CompressionWriter) responseSize(header http.Header) (int64, bool) {
+	value := header.Get("Content-Length")
+	if value == "" {
+		return 0, false
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return size, true
+}
+
+func (cw *CompressionWriter) writePendingResponse(compress bool) error {
+	status := cw.pendingStatus
+	data := cw.pendingData
+
+	cw.pendingStatus = 0
+	cw.pendingData = nil
+
+	if compress {
+		cw.startEncoder(cw.w.Header())
+	}
+
+	cw.w.WriteHeader(status)
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	if cw.encoder != nil {
+		_, err := cw.encoder.Write(data)
+		return err
+	}
+
+	_, err := cw.w.Write(data)
+	return err
+}
+
+func (cw *CompressionWriter) startEncoder(header http.Header) {
+	var encoder compressionEncoder
+
+	switch cw.coding {
+	case ContentCodingGzip:
+		encoder = gzip.NewWriter(cw.w)
+
+	case ContentCodingZstd:
+		zstdEncoder, err := zstd.NewWriter(cw.w,
+			zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			cw.ctx.Log.Error("cannot create zstd encoder: %v", err)
+			return
+		}
+
+		encoder = zstdEncoder
+
+	case ContentCodingBrotli:
+		encoder = brotli.NewWriter(cw.w)
+
+	default:
+		program.Panic("unhandled content coding %q", cw.coding)
+	}
+
+	cw.encoder = encoder
+
+	header.Set("Content-Encoding", cw.coding)
+	header.Del("Content-Length")
+
+	// RFC 9110 8.8.3.3. The compressed representation is not byte-identical to
+	// the original one, a strong validator cannot be used anymore.
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+}
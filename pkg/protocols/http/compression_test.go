@@ -0,0 +1,74 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testResponseWriter struct {
+	header   http.Header
+	statuses []int
+	body     bytes.Buffer
+}
+
+func newTestResponseWriter() *testResponseWriter {
+	return &testResponseWriter{header: make(http.Header)}
+}
+
+func (w *testResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *testResponseWriter) WriteHeader(status int) {
+	w.statuses = append(w.statuses, status)
+}
+
+func (w *testResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func TestCompressionWriterInformationalStatus(t *testing.T) {
+	require := require.New(t)
+
+	const text = "Lorem ipsum dolor sit amet, consectetur adipiscing elit."
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	cfg := CompressionCfg{
+		Enabled: true,
+		Codings: []string{ContentCodingGzip},
+		MinSize: 1,
+	}
+
+	var mediaRange MediaRange
+	require.NoError(mediaRange.Parse("text/plain"))
+	cfg.MediaTypes = []*MediaRange{&mediaRange}
+
+	w := newTestResponseWriter()
+	cw := NewCompressionWriter(&cfg, &RequestContext{Request: req}, w)
+
+	cw.Header().Set("Link", "</style.css>; rel=preload")
+	cw.WriteHeader(103)
+
+	cw.Header().Set("Content-Type", "text/plain")
+	cw.WriteHeader(200)
+	_, err := cw.Write([]byte(text))
+	require.NoError(err)
+	require.NoError(cw.Close())
+
+	require.Equal([]int{103, 200}, w.statuses)
+	require.Equal("gzip", w.header.Get("Content-Encoding"))
+
+	r, err := gzip.NewReader(&w.body)
+	require.NoError(err)
+	data, err := io.ReadAll(r)
+	require.NoError(err)
+	require.Equal(text, string(data))
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	ContentCodingGzip   = "gzip"
+	ContentCodingZstd   = "zstd"
+	ContentCodingBrotli = "br"
+)
+
+var ContentCodingValues = []string{
+	ContentCodingZstd,
+	ContentCodingBrotli,
+	ContentCodingGzip,
+}
+
+type ContentCodingRange struct {
+	Coding  string // normalized to lower case; "*" for any coding
+	Quality float64
+}
+
+func (r *ContentCodingRange) String() string {
+	if r.Quality == 1.0 {
+		return r.Coding
+	}
+
+	q := strconv.FormatFloat(float64(r.Quality), 'g', -1, 64)
+	return r.Coding + ";q=" + q
+}
+
+func (r *ContentCodingRange) Parse(s string) error {
+	// RFC 9110 12.5.3. Accept-Encoding
+
+	coding, params, hasParams := strings.Cut(s, ";")
+
+	coding = strings.Trim(coding, " \t")
+	if coding == "" {
+		return fmt.Errorf("invalid empty content coding")
+	}
+
+	r.Coding = strings.ToLower(coding)
+	r.Quality = 1.0
+
+	if hasParams {
+		var p MediaTypeParameter
+		if err := p.Parse(params); err != nil {
+			return fmt.Errorf("invalid parameter: %w", err)
+		}
+
+		if strings.ToLower(p.Name) != "q" {
+			return fmt.Errorf("invalid parameter %q", p.Name)
+		}
+
+		f, err := strconv.ParseFloat(p.Value, 64)
+		if err != nil || f < 0.0 || f > 1.0 {
+			return fmt.Errorf("invalid \"q\" parameter")
+		}
+
+		r.Quality = f
+	}
+
+	return nil
+}
+
+func NegotiateContentCoding(ranges []*ContentCodingRange, supportedCodings []string) string {
+	// Return the supported coding with the highest quality value, using the
+	// order of supported codings to break ties. An empty string means that no
+	// supported coding is acceptable and that the identity coding should be
+	// used.
+
+	var bestCoding string
+	var bestQuality float64
+
+	for _, coding := range supportedCodings {
+		quality := -1.0
+		wildcardQuality := -1.0
+
+		for _, r := range ranges {
+			switch r.Coding {
+			case coding:
+				quality = r.Quality
+			case "*":
+				wildcardQuality = r.Quality
+			}
+		}
+
+		if quality < 0.0 {
+			quality = wildcardQuality
+		}
+
+		if quality > bestQuality {
+			bestCoding = coding
+			bestQuality = quality
+		}
+	}
+
+	return bestCoding
+}
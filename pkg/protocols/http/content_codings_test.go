@@ -0,0 +1,95 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentCodingRangeParsing(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		s  string
+		r  *ContentCodingRange
+		ns string
+	}{
+		{"gzip",
+			&ContentCodingRange{Coding: "gzip", Quality: 1.0},
+			"gzip"},
+		{" GZip ",
+			&ContentCodingRange{Coding: "gzip", Quality: 1.0},
+			"gzip"},
+		{"br;q=0.5",
+			&ContentCodingRange{Coding: "br", Quality: 0.5},
+			"br;q=0.5"},
+		{"*; q=0",
+			&ContentCodingRange{Coding: "*", Quality: 0.0},
+			"*;q=0"},
+
+		{"", nil, ""},
+		{";q=1", nil, ""},
+		{"gzip;", nil, ""},
+		{"gzip;q", nil, ""},
+		{"gzip;q=foo", nil, ""},
+		{"gzip;q=2", nil, ""},
+		{"gzip;a=b", nil, ""},
+	}
+
+	for _, test := range tests {
+		label := fmt.Sprintf("%q", test.s)
+
+		var r ContentCodingRange
+		err := r.Parse(test.s)
+
+		if test.r == nil {
+			assert.Error(err, label)
+		} else {
+			if assert.NoError(err, label) {
+				assert.Equal(test.r, &r, label)
+				assert.Equal(test.ns, r.String(), label)
+			}
+		}
+	}
+}
+
+func TestContentCodingNegotiation(t *testing.T) {
+	assert := assert.New(t)
+
+	supportedCodings := []string{"zstd", "br", "gzip"}
+
+	tests := []struct {
+		s      string
+		coding string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"compress", ""},
+		{"gzip", "gzip"},
+		{"gzip, br", "br"},
+		{"gzip, br, zstd", "zstd"},
+		{"gzip;q=0.5, br;q=0.8", "br"},
+		{"gzip, br;q=0.8", "gzip"},
+		{"*", "zstd"},
+		{"*, zstd;q=0", "br"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"*;q=0", ""},
+	}
+
+	for _, test := range tests {
+		label := fmt.Sprintf("%q", test.s)
+
+		var ranges []*ContentCodingRange
+		for _, part := range strings.Split(test.s, ",") {
+			var r ContentCodingRange
+			if err := r.Parse(part); err == nil {
+				ranges = append(ranges, &r)
+			}
+		}
+
+		coding := NegotiateContentCoding(ranges, supportedCodings)
+		assert.Equal(test.coding, coding, label)
+	}
+}
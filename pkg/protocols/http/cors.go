@@ -0,0 +1,341 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/boulevard/pkg/httputils"
+	"go.n16f.net/boulevard/pkg/netutils"
+)
+
+var DefaultCORSMethods = []string{"GET", "HEAD", "POST"}
+
+type CORSCfg struct {
+	Enabled bool
+
+	// If no origin is configured, any origin is allowed.
+	Origins        []string // exact origins, normalized to lower case
+	OriginPatterns []*netutils.DomainNamePattern
+	OriginRegexps  []*regexp.Regexp
+
+	Methods        []string
+	Headers        []string // normalized to lower case; may contain "*"
+	ExposedHeaders []string
+	Credentials    bool
+	MaxAge         *time.Duration
+}
+
+func (cfg *CORSCfg) ReadBCLElement(elt *bcl.Element) error {
+	if !elt.IsBlock() {
+		elt.Values(&cfg.Enabled)
+		cfg.Methods = DefaultCORSMethods
+		return nil
+	}
+
+	cfg.Enabled = true
+
+	for _, entry := range elt.FindEntries("origin") {
+		for i := range entry.NbValues() {
+			var s bcl.String
+			if !entry.Value(i, &s) {
+				continue
+			}
+
+			switch {
+			case s.Sigil == "re":
+				var re *regexp.Regexp
+				if entry.Value(i, &re) {
+					cfg.OriginRegexps = append(cfg.OriginRegexps, re)
+				}
+
+			case strings.Contains(s.String, "://"):
+				origin, err := normalizeCORSOrigin(s.String)
+				if err != nil {
+					entry.AddSimpleValidationError("invalid origin: %v", err)
+					continue
+				}
+
+				cfg.Origins = append(cfg.Origins, origin)
+
+			default:
+				var pattern *netutils.DomainNamePattern
+				if entry.Value(i, &pattern) {
+					cfg.OriginPatterns = append(cfg.OriginPatterns, pattern)
+				}
+			}
+		}
+	}
+
+	for _, entry := range elt.FindEntries("method") {
+		for i := range entry.NbValues() {
+			var method string
+			if entry.Value(i, bcl.WithValueValidation(&method,
+				httputils.ValidateBCLMethod)) {
+				cfg.Methods = append(cfg.Methods, method)
+			}
+		}
+	}
+
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = DefaultCORSMethods
+	}
+
+	for _, entry := range elt.FindEntries("header") {
+		for i := range entry.NbValues() {
+			var name string
+			if entry.Value(i, &name) {
+				cfg.Headers = append(cfg.Headers, strings.ToLower(name))
+			}
+		}
+	}
+
+	for _, entry := range elt.FindEntries("exposed_header") {
+		for i := range entry.NbValues() {
+			var name string
+			if entry.Value(i, &name) {
+				cfg.ExposedHeaders = append(cfg.ExposedHeaders, name)
+			}
+		}
+	}
+
+	elt.MaybeEntryValues("credentials", &cfg.Credentials)
+	elt.MaybeEntryValues("max_age", &cfg.MaxAge)
+
+	// Reflecting any origin for requests with credentials would let any
+	// website read responses on behalf of the user.
+	if cfg.Credentials && cfg.AllowAnyOrigin() {
+		elt.AddSimpleValidationError("credentials cannot be allowed " +
+			"without explicit origins")
+	}
+
+	return nil
+}
+
+func normalizeCORSOrigin(s string) (string, error) {
+	// RFC 6454 6.1. Unicode Serialization of an Origin. We do not support
+	// "null" origins on purpose: they can be sent by sandboxed documents of any
+	// website.
+
+	uri, err := url.Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	if uri.Scheme == "" || uri.Host == "" {
+		return "", fmt.Errorf("missing scheme or host")
+	}
+
+	if (uri.Path != "" && uri.Path != "/") || uri.RawQuery != "" ||
+		uri.Fragment != "" || uri.User != nil {
+		return "", fmt.Errorf("origin must only contain a scheme, a host " +
+			"and an optional port")
+	}
+
+	return strings.ToLower(uri.Scheme + "://" + uri.Host), nil
+}
+
+func (cfg *CORSCfg) AllowAnyOrigin() bool {
+	return len(cfg.Origins) == 0 && len(cfg.OriginPatterns) == 0 &&
+		len(cfg.OriginRegexps) == 0
+}
+
+func (cfg *CORSCfg) IsOriginAllowed(origin string) bool {
+	if cfg.AllowAnyOrigin() {
+		return true
+	}
+
+	normalizedOrigin, err := normalizeCORSOrigin(origin)
+	if err != nil {
+		return false
+	}
+
+	if slices.Contains(cfg.Origins, normalizedOrigin) {
+		return true
+	}
+
+	if len(cfg.OriginPatterns) > 0 {
+		uri, _ := url.Parse(normalizedOrigin)
+		hostname := uri.Hostname()
+
+		for _, pattern := range cfg.OriginPatterns {
+			if pattern.Match(hostname) {
+				return true
+			}
+		}
+	}
+
+	for _, re := range cfg.OriginRegexps {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isCORSPreflightRequest(req *http.Request) bool {
+	// Fetch Standard 3.2.2. HTTP requests: "A CORS-preflight request is a CORS
+	// request that checks to see if the CORS protocol is understood. It uses
+	// `OPTIONS` as method and includes the following header:
+	// `Access-Control-Request-Method`".
+	return req.Method == "OPTIONS" && req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+func (ctx *RequestContext) replyCORSPreflight(cfg *CORSCfg) {
+	reqHeader := ctx.Request.Header
+	header := ctx.ResponseWriter.Header()
+
+	httputils.AddVaryFieldName(header, "Origin")
+	httputils.AddVaryFieldName(header, "Access-Control-Request-Method")
+	httputils.AddVaryFieldName(header, "Access-Control-Request-Headers")
+
+	origin := reqHeader.Get("Origin")
+	if !cfg.IsOriginAllowed(origin) {
+		ctx.ReplyError2(403, "origin not allowed")
+		return
+	}
+
+	method := reqHeader.Get("Access-Control-Request-Method")
+	if !slices.Contains(cfg.Methods, method) {
+		ctx.ReplyError2(403, "method not allowed")
+		return
+	}
+
+	var reqHeaderNames []string
+	for _, value := range reqHeader.Values("Access-Control-Request-Headers") {
+		names := httputils.SplitTokenList(value, true)
+		reqHeaderNames = append(reqHeaderNames, names...)
+	}
+
+	var allowedHeaders []string
+	if slices.Contains(cfg.Headers, "*") {
+		// The "*" wildcard is not interpreted as such for requests with
+		// credentials, so we echo requested header fields instead.
+		allowedHeaders = reqHeaderNames
+	} else {
+		for _, name := range reqHeaderNames {
+			if !slices.Contains(cfg.Headers, name) {
+				ctx.ReplyError2(403, "header field %q not allowed", name)
+				return
+			}
+		}
+
+		allowedHeaders = cfg.Headers
+	}
+
+	cfg.setResponseHeaderOrigin(header, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(cfg.Methods, ", "))
+
+	if len(allowedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers",
+			strings.Join(allowedHeaders, ", "))
+	}
+
+	if cfg.MaxAge != nil {
+		maxAge := int64(cfg.MaxAge.Seconds())
+		header.Set("Access-Control-Max-Age", strconv.FormatInt(maxAge, 10))
+	}
+
+	ctx.Reply(204, nil)
+}
+
+func (cfg *CORSCfg) setResponseHeader(header http.Header, origin string) {
+	if !cfg.AllowAnyOrigin() {
+		httputils.AddVaryFieldName(header, "Origin")
+	}
+
+	if origin == "" || !cfg.IsOriginAllowed(origin) {
+		return
+	}
+
+	cfg.setResponseHeaderOrigin(header, origin)
+
+	if len(cfg.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers",
+			strings.Join(cfg.ExposedHeaders, ", "))
+	}
+}
+
+func (cfg *CORSCfg) setResponseHeaderOrigin(header http.Header, origin string) {
+	if cfg.Credentials {
+		// The "*" wildcard cannot be used for requests with credentials
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Allow-Credentials", "true")
+	} else if cfg.AllowAnyOrigin() {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+}
+
+// CORSWriter sets CORS header fields when the response header is written,
+// after the action had the opportunity to set its own fields (e.g. for a
+// reverse proxy, after header fields are copied from the upstream response).
+type CORSWriter struct {
+	ctx *RequestContext
+	w   http.ResponseWriter
+
+	headerWritten bool
+}
+
+func NewCORSWriter(ctx *RequestContext, w http.ResponseWriter) *CORSWriter {
+	return &CORSWriter{
+		ctx: ctx,
+		w:   w,
+	}
+}
+
+func (cw *CORSWriter) Header() http.Header {
+	return cw.w.Header()
+}
+
+func (cw *CORSWriter) WriteHeader(status int) {
+	if httputils.IsInformationalStatus(status) {
+		cw.w.WriteHeader(status)
+		return
+	}
+
+	// The request may have been rewritten and handled by a handler for which
+	// CORS is disabled.
+	if cfg := cw.ctx.CORS; cfg != nil && !cw.headerWritten {
+		origin := cw.ctx.Request.Header.Get("Origin")
+		cfg.setResponseHeader(cw.w.Header(), origin)
+	}
+
+	cw.headerWritten = true
+	cw.w.WriteHeader(status)
+}
+
+func (cw *CORSWriter) Write(data []byte) (int, error) {
+	if !cw.headerWritten {
+		cw.WriteHeader(200)
+	}
+
+	return cw.w.Write(data)
+}
+
+func (cw *CORSWriter) Flush() {
+	if f, ok := cw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *CORSWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil,
+			fmt.Errorf("response writer does not support connection hijacking")
+	}
+
+	return hijacker.Hijack()
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORSWriterInformationalStatus(t *testing.T) {
+	require := require.New(t)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Origin", "https://example.com")
+
+	cfg := CORSCfg{
+		Enabled: true,
+		Origins: []string{"https://example.com"},
+		Methods: DefaultCORSMethods,
+	}
+
+	w := newTestResponseWriter()
+	cw := NewCORSWriter(&RequestContext{Request: req, CORS: &cfg}, w)
+
+	cw.Header().Set("Link", "</style.css>; rel=preload")
+	cw.WriteHeader(103)
+	require.Empty(w.header.Get("Access-Control-Allow-Origin"))
+
+	// The final header is built after the informational response
+	cw.Header().Del("Link")
+	cw.Header().Set("Content-Type", "text/plain")
+	cw.WriteHeader(200)
+
+	require.Equal([]int{103, 200}, w.statuses)
+	require.Equal("https://example.com",
+		w.header.Get("Access-Control-Allow-Origin"))
+	require.Equal("Origin", w.header.Get("Vary"))
+}
@@ -0,0 +1,335 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/boulevard/pkg/boulevard"
+)
+
+var errorPageMediaTypes = []*MediaType{
+	MediaTypeText,
+	MediaTypeHTML,
+	MediaTypeJSON,
+}
+
+// StatusRange is an inclusive range of HTTP status codes. In configuration
+// files, it is either a status code (e.g. 404), a class of status codes (e.g.
+// "5xx") or an explicit range (e.g. "500-504").
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+func (r *StatusRange) Parse(s string) error {
+	if len(s) == 3 && s[0] >= '1' && s[0] <= '5' && s[1:] == "xx" {
+		r.Min = int(s[0]-'0') * 100
+		r.Max = r.Min + 99
+		return nil
+	}
+
+	minString, maxString, found := strings.Cut(s, "-")
+	if !found {
+		maxString = minString
+	}
+
+	minStatus, err := strconv.Atoi(minString)
+	if err != nil || minStatus < 100 || minStatus > 599 {
+		return fmt.Errorf("invalid status %q", minString)
+	}
+
+	maxStatus, err := strconv.Atoi(maxString)
+	if err != nil || maxStatus < 100 || maxStatus > 599 {
+		return fmt.Errorf("invalid status %q", maxString)
+	}
+
+	if minStatus > maxStatus {
+		return fmt.Errorf("invalid empty range")
+	}
+
+	r.Min = minStatus
+	r.Max = maxStatus
+
+	return nil
+}
+
+// bcl.ValueReader
+func (r *StatusRange) ReadBCLValue(v *bcl.Value) error {
+	var s string
+
+	switch v.Type() {
+	case bcl.ValueTypeInteger:
+		s = strconv.FormatInt(v.Content.(int64), 10)
+	case bcl.ValueTypeString:
+		s = v.Content.(bcl.String).String
+	default:
+		return bcl.NewValueTypeError(v, bcl.ValueTypeInteger,
+			bcl.ValueTypeString)
+	}
+
+	if err := r.Parse(s); err != nil {
+		return fmt.Errorf("invalid status range: %w", err)
+	}
+
+	return nil
+}
+
+func (r *StatusRange) Contains(status int) bool {
+	return status >= r.Min && status <= r.Max
+}
+
+type ErrorPagesCfg struct {
+	Enabled bool
+	Pages   []*ErrorPageCfg
+}
+
+func (cfg *ErrorPagesCfg) ReadBCLElement(elt *bcl.Element) error {
+	if !elt.IsBlock() {
+		elt.Values(&cfg.Enabled)
+		return nil
+	}
+
+	cfg.Enabled = true
+
+	for _, entry := range elt.FindEntries("page") {
+		var page ErrorPageCfg
+		if page.ReadBCLEntry(entry) {
+			cfg.Pages = append(cfg.Pages, &page)
+		}
+	}
+
+	return nil
+}
+
+type ErrorPageCfg struct {
+	Statuses StatusRange
+
+	// One of the following
+	File     string
+	Template string
+	Reply    *boulevard.FormatString
+}
+
+func (cfg *ErrorPageCfg) ReadBCLEntry(entry *bcl.Element) bool {
+	if !entry.CheckNbValues(3) || !entry.Value(0, &cfg.Statuses) {
+		return false
+	}
+
+	if !entry.CheckValueOneOf(1, "file", "template", "reply") {
+		return false
+	}
+
+	var pageType string
+	entry.Value(1, &pageType)
+
+	switch pageType {
+	case "file":
+		return entry.Value(2, &cfg.File)
+	case "template":
+		return entry.Value(2, &cfg.Template)
+	case "reply":
+		return entry.Value(2, &cfg.Reply)
+	}
+
+	return false
+}
+
+type ErrorPageData struct {
+	Status  int               `json:"status"`
+	Reason  string            `json:"reason"`
+	Message string            `json:"message"`
+	Vars    map[string]string `json:"-"`
+}
+
+type ErrorPages struct {
+	Cfg *ErrorPagesCfg
+
+	pages []*errorPage
+	view  *View
+}
+
+type errorPage struct {
+	Cfg       *ErrorPageCfg
+	MediaType *MediaType
+
+	textTemplate *texttemplate.Template
+	htmlTemplate *htmltemplate.Template
+}
+
+func NewErrorPages(cfg *ErrorPagesCfg) (*ErrorPages, error) {
+	view, err := NewView("templates/error")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create view: %w", err)
+	}
+
+	ep := ErrorPages{
+		Cfg: cfg,
+
+		view: view,
+	}
+
+	for _, pageCfg := range cfg.Pages {
+		page, err := newErrorPage(pageCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		ep.pages = append(ep.pages, page)
+	}
+
+	// Pages for single status codes are more specific than pages for ranges
+	// and must be preferred when they have the same media type.
+	slices.SortStableFunc(ep.pages, func(p1, p2 *errorPage) int {
+		r1, r2 := p1.Cfg.Statuses, p2.Cfg.Statuses
+		return (r1.Max - r1.Min) - (r2.Max - r2.Min)
+	})
+
+	return &ep, nil
+}
+
+func newErrorPage(cfg *ErrorPageCfg) (*errorPage, error) {
+	page := errorPage{
+		Cfg:       cfg,
+		MediaType: MediaTypeText,
+	}
+
+	switch {
+	case cfg.File != "":
+		page.MediaType = errorPageFileMediaType(cfg.File)
+
+	case cfg.Template != "":
+		filePath := cfg.Template
+		page.MediaType = errorPageFileMediaType(
+			strings.TrimSuffix(filePath, ".gotpl"))
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %q: %w", filePath, err)
+		}
+
+		if page.MediaType.Subtype == "html" {
+			tpl := htmltemplate.New(filePath).Option("missingkey=error")
+			page.htmlTemplate, err = tpl.Parse(string(data))
+		} else {
+			tpl := texttemplate.New(filePath).Option("missingkey=error")
+			page.textTemplate, err = tpl.Parse(string(data))
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse template %q: %w", filePath,
+				err)
+		}
+	}
+
+	return &page, nil
+}
+
+func errorPageFileMediaType(filePath string) *MediaType {
+	ext := path.Ext(filePath)
+
+	var mediaType MediaType
+	if err := mediaType.Parse(mime.TypeByExtension(ext)); err != nil {
+		return MediaTypeText
+	}
+
+	return &mediaType
+}
+
+func (ep *ErrorPages) Reply(ctx *RequestContext, status int, msg string) {
+	ctx.Vars["http.error.message"] = msg
+
+	data := ErrorPageData{
+		Status:  status,
+		Reason:  http.StatusText(status),
+		Message: msg,
+		Vars:    ctx.Vars,
+	}
+
+	var pages []*errorPage
+	var mediaTypes []*MediaType
+
+	for _, page := range ep.pages {
+		if page.Cfg.Statuses.Contains(status) {
+			pages = append(pages, page)
+			mediaTypes = append(mediaTypes, page.MediaType)
+		}
+	}
+
+	var mediaType *MediaType
+	var body []byte
+	var err error
+
+	if len(pages) == 0 {
+		mediaType = ctx.NegotiateMediaType(errorPageMediaTypes)
+		body, err = ep.renderDefaultPage(ctx, mediaType, &data)
+	} else {
+		mediaType = ctx.NegotiateMediaType(mediaTypes)
+		page := pages[slices.Index(mediaTypes, mediaType)]
+		body, err = page.render(ctx, &data)
+	}
+
+	if err != nil {
+		ctx.Log.Error("cannot render error page: %v", err)
+		ctx.replyTextError(status, msg)
+		return
+	}
+
+	header := ctx.ResponseWriter.Header()
+	header.Set("Content-Type", mediaType.String())
+
+	ctx.Reply(status, bytes.NewReader(body))
+}
+
+func (ep *ErrorPages) renderDefaultPage(ctx *RequestContext, mediaType *MediaType, data *ErrorPageData) ([]byte, error) {
+	switch mediaType {
+	case MediaTypeJSON:
+		return ep.view.renderJSON("error", data, ctx)
+	case MediaTypeHTML:
+		return ep.view.renderHTML("error", data, ctx)
+	default:
+		return ep.view.renderText("error", data, ctx)
+	}
+}
+
+func (page *errorPage) render(ctx *RequestContext, data *ErrorPageData) ([]byte, error) {
+	cfg := page.Cfg
+
+	switch {
+	case cfg.File != "":
+		body, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %q: %w", cfg.File, err)
+		}
+
+		return body, nil
+
+	case page.htmlTemplate != nil:
+		var buf bytes.Buffer
+		if err := page.htmlTemplate.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+
+	case page.textTemplate != nil:
+		var buf bytes.Buffer
+		if err := page.textTemplate.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+
+	default:
+		return []byte(cfg.Reply.Expand(ctx.Vars)), nil
+	}
+}
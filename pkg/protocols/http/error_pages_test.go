@@ -0,0 +1,50 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusRangeParse(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		s string
+		r StatusRange
+	}{
+		{"404", StatusRange{404, 404}},
+		{"4xx", StatusRange{400, 499}},
+		{"1xx", StatusRange{100, 199}},
+		{"500-504", StatusRange{500, 504}},
+		{"500-500", StatusRange{500, 500}},
+	}
+
+	for _, test := range tests {
+		label := test.s
+
+		var r StatusRange
+		err := r.Parse(test.s)
+		if assert.NoError(err, label) {
+			assert.Equal(test.r, r, label)
+		}
+	}
+
+	invalidTests := []string{
+		"",
+		"6xx",
+		"0xx",
+		"4x",
+		"99",
+		"600",
+		"504-500",
+		"500-",
+		"-500",
+		"foo",
+	}
+
+	for _, s := range invalidTests {
+		var r StatusRange
+		assert.Error(r.Parse(s), s)
+	}
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	FileETagMethodMTimeSize   = "mtime_size"
+	FileETagMethodContentHash = "content_hash"
+
+	DefaultFileETagCacheSize = 10_000
+)
+
+var FileETagMethodValues = []string{
+	FileETagMethodMTimeSize,
+	FileETagMethodContentHash,
+}
+
+// FileETag returns a strong entity tag based on the modification time and the
+// size of a file.
+func FileETag(info fs.FileInfo) string {
+	mtime := strconv.FormatInt(info.ModTime().UnixNano(), 16)
+	size := strconv.FormatInt(info.Size(), 16)
+
+	return `"` + mtime + "-" + size + `"`
+}
+
+// FileETagCache stores entity tags computed from the content of files. Entries
+// are invalidated when the size or modification time of the file changes.
+type FileETagCache struct {
+	maxEntries int
+
+	entries map[string]*list.Element // key -> *fileETagCacheEntry
+	lru     *list.List
+	mutex   sync.Mutex
+}
+
+type fileETagCacheEntry struct {
+	key     string
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+func NewFileETagCache(maxEntries int) *FileETagCache {
+	return &FileETagCache{
+		maxEntries: maxEntries,
+
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// ETag returns the entity tag of a file identified by a key, usually its
+// path, reading its content from r if there is no valid cache entry.
+func (c *FileETagCache) ETag(key string, info fs.FileInfo, r io.Reader) (string, error) {
+	if etag := c.lookup(key, info); etag != "" {
+		return etag, nil
+	}
+
+	// We do not want to hold the lock while hashing the file. Concurrent
+	// requests for the same file may compute the hash several times; this is
+	// harmless.
+	etag, err := contentETag(r)
+	if err != nil {
+		return "", err
+	}
+
+	c.store(key, info, etag)
+
+	return etag, nil
+}
+
+func (c *FileETagCache) lookup(key string, info fs.FileInfo) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elt, found := c.entries[key]
+	if !found {
+		return ""
+	}
+
+	entry := elt.Value.(*fileETagCacheEntry)
+	if entry.size != info.Size() || !entry.modTime.Equal(info.ModTime()) {
+		c.lru.Remove(elt)
+		delete(c.entries, key)
+		return ""
+	}
+
+	c.lru.MoveToFront(elt)
+
+	return entry.etag
+}
+
+func (c *FileETagCache) store(key string, info fs.FileInfo, etag string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := fileETagCacheEntry{
+		key:     key,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		etag:    etag,
+	}
+
+	if elt, found := c.entries[key]; found {
+		elt.Value = &entry
+		c.lru.MoveToFront(elt)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&entry)
+
+	for c.lru.Len() > c.maxEntries {
+		elt := c.lru.Back()
+		c.lru.Remove(elt)
+		delete(c.entries, elt.Value.(*fileETagCacheEntry).key)
+	}
+}
+
+func contentETag(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+
+	// 128 bits are more than enough to identify a version of a file
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`, nil
+}
@@ -2,8 +2,12 @@ package http
 
 import (
 	"fmt"
+	"maps"
+	"net"
 	"regexp"
 	"slices"
+	"strconv"
+	"strings"
 
 	"go.n16f.net/bcl"
 	"go.n16f.net/boulevard/pkg/httputils"
@@ -15,13 +19,21 @@ type HandlerCfg struct {
 	AccessLogger       *AccessLoggerCfg
 	Auth               *AuthCfg
 	RequestRateLimiter *netutils.RateLimiterCfg
+	Compression        *CompressionCfg
+	CORS               *CORSCfg
+	ErrorPages         *ErrorPagesCfg
 
 	Reply        *ReplyActionCfg
 	Redirect     *RedirectActionCfg
 	Serve        *ServeActionCfg
+	WebDAV       *WebDAVActionCfg
 	ReverseProxy *ReverseProxyActionCfg
 	Status       *StatusActionCfg
 	FastCGI      *FastCGIActionCfg
+	CGI          *CGIActionCfg
+	SCGI         *SCGIActionCfg
+	UWSGI        *UWSGIActionCfg
+	Rewrite      *RewriteActionCfg
 
 	Handlers    []*HandlerCfg
 	NextHandler bool
@@ -35,15 +47,24 @@ func (cfg *HandlerCfg) ReadBCLElement(block *bcl.Element) error {
 	block.MaybeBlock("access_logs", &cfg.AccessLogger)
 	block.MaybeBlock("authentication", &cfg.Auth)
 	block.MaybeElement("request_rate_limits", &cfg.RequestRateLimiter)
+	block.MaybeElement("compression", &cfg.Compression)
+	block.MaybeElement("cors", &cfg.CORS)
+	block.MaybeElement("error_pages", &cfg.ErrorPages)
 
-	block.CheckElementsMaybeOneOf("reply", "redirect", "serve", "reverse_proxy",
-		"status", "fastcgi")
+	block.CheckElementsMaybeOneOf("reply", "redirect", "serve", "webdav",
+		"reverse_proxy", "status", "fastcgi", "cgi", "scgi", "uwsgi",
+		"rewrite")
 	block.MaybeElement("reply", &cfg.Reply)
 	block.MaybeElement("redirect", &cfg.Redirect)
 	block.MaybeElement("serve", &cfg.Serve)
+	block.MaybeElement("webdav", &cfg.WebDAV)
 	block.MaybeElement("reverse_proxy", &cfg.ReverseProxy)
 	block.MaybeElement("status", &cfg.Status)
 	block.MaybeElement("fastcgi", &cfg.FastCGI)
+	block.MaybeElement("cgi", &cfg.CGI)
+	block.MaybeElement("scgi", &cfg.SCGI)
+	block.MaybeElement("uwsgi", &cfg.UWSGI)
+	block.MaybeElement("rewrite", &cfg.Rewrite)
 
 	block.Blocks("handler", &cfg.Handlers)
 	if block.FindEntry("next_handler") != nil {
@@ -59,27 +80,67 @@ type MatchCfg struct {
 	Methods       []string
 	Hosts         []*netutils.DomainNamePattern
 	HostRegexps   []*regexp.Regexp
+	Addresses     []*netutils.IPNetAddr
 	HeaderValues  map[string][]string
 	HeaderRegexps map[string][]*regexp.Regexp
+	QueryValues   map[string][]string
+	QueryRegexps  map[string][]*regexp.Regexp
+	CookieValues  map[string][]string
+	CookieRegexps map[string][]*regexp.Regexp
 	Paths         []*PathPattern
 	PathRegexps   []*regexp.Regexp
+
+	Negations []*MatchCfg // "match not" entries, one per entry
 }
 
+var matchTypes = []any{"tls", "http_version", "method", "host", "address",
+	"header", "query", "cookie", "path"}
+
 func (cfg *MatchCfg) ReadBCLEntry(entry *bcl.Element) {
-	entry.CheckValueOneOf(0, "tls", "http_version", "method", "host", "header",
-		"path")
+	entry.CheckValueOneOf(0, append(matchTypes, "not")...)
 
 	var matchType string
 	if !entry.Value(0, &matchType) {
 		return
 	}
 
+	if matchType == "not" {
+		// A negated match type without any constraint would match all
+		// requests, and the handler would therefore never be selected.
+		if !entry.CheckMinNbValues(3) {
+			return
+		}
+
+		if !entry.CheckValueOneOf(1, matchTypes...) {
+			return
+		}
+
+		var negatedCfg MatchCfg
+		negatedCfg.readBCLEntry(entry, 1)
+		cfg.Negations = append(cfg.Negations, &negatedCfg)
+		return
+	}
+
+	cfg.readBCLEntry(entry, 0)
+}
+
+func (cfg *MatchCfg) readBCLEntry(entry *bcl.Element, first int) {
+	// The first value is the match type; it is followed by the constraint
+	// parameters.
+
+	var matchType string
+	if !entry.Value(first, &matchType) {
+		return
+	}
+
 	switch matchType {
 	case "tls":
-		entry.Values(&matchType, &cfg.TLS)
+		if entry.CheckNbValues(first + 2) {
+			entry.Value(first+1, &cfg.TLS)
+		}
 
 	case "http_version":
-		for i := 1; i < entry.NbValues(); i++ {
+		for i := first + 1; i < entry.NbValues(); i++ {
 			if entry.CheckValueOneOf(i, HTTPVersionStringsAny...) {
 				var version HTTPVersion
 				entry.Value(i, &version)
@@ -88,14 +149,14 @@ func (cfg *MatchCfg) ReadBCLEntry(entry *bcl.Element) {
 		}
 
 	case "method":
-		cfg.Methods = make([]string, entry.NbValues()-1)
-		for i := 1; i < entry.NbValues(); i++ {
-			entry.Value(i, bcl.WithValueValidation(&cfg.Methods[i-1],
+		cfg.Methods = make([]string, entry.NbValues()-first-1)
+		for i := first + 1; i < entry.NbValues(); i++ {
+			entry.Value(i, bcl.WithValueValidation(&cfg.Methods[i-first-1],
 				httputils.ValidateBCLMethod))
 		}
 
 	case "host":
-		for i := 1; i < entry.NbValues(); i++ {
+		for i := first + 1; i < entry.NbValues(); i++ {
 			var s bcl.String
 
 			if entry.Value(i, &s) {
@@ -113,6 +174,26 @@ func (cfg *MatchCfg) ReadBCLEntry(entry *bcl.Element) {
 			}
 		}
 
+	case "address":
+		for i := first + 1; i < entry.NbValues(); i++ {
+			var s string
+
+			if entry.Value(i, &s) {
+				if strings.Contains(s, "/") {
+					var addr netutils.IPNetAddr
+					if entry.Value(i, &addr) {
+						cfg.Addresses = append(cfg.Addresses, &addr)
+					}
+				} else {
+					var addr netutils.IPAddr
+					if entry.Value(i, &addr) {
+						netAddr := netutils.IPNetAddr(addr)
+						cfg.Addresses = append(cfg.Addresses, &netAddr)
+					}
+				}
+			}
+		}
+
 	case "header":
 		if cfg.HeaderValues == nil {
 			cfg.HeaderValues = make(map[string][]string)
@@ -123,12 +204,12 @@ func (cfg *MatchCfg) ReadBCLEntry(entry *bcl.Element) {
 
 		var name string
 
-		if entry.Value(1, &name) {
-			if entry.NbValues() == 1 {
+		if entry.Value(first+1, &name) {
+			if entry.NbValues() == first+2 {
 				cfg.HeaderValues[name] = nil
 			}
 
-			for i := 2; i < entry.NbValues(); i++ {
+			for i := first + 2; i < entry.NbValues(); i++ {
 				var s bcl.String
 
 				if entry.Value(i, &s) {
@@ -149,8 +230,80 @@ func (cfg *MatchCfg) ReadBCLEntry(entry *bcl.Element) {
 			}
 		}
 
+	case "query":
+		if cfg.QueryValues == nil {
+			cfg.QueryValues = make(map[string][]string)
+		}
+		if cfg.QueryRegexps == nil {
+			cfg.QueryRegexps = make(map[string][]*regexp.Regexp)
+		}
+
+		var name string
+
+		if entry.Value(first+1, &name) {
+			if entry.NbValues() == first+2 {
+				cfg.QueryValues[name] = nil
+			}
+
+			for i := first + 2; i < entry.NbValues(); i++ {
+				var s bcl.String
+
+				if entry.Value(i, &s) {
+					switch s.Sigil {
+					case "re":
+						var re *regexp.Regexp
+						entry.Value(i, &re)
+						cfg.QueryRegexps[name] =
+							append(cfg.QueryRegexps[name], re)
+
+					default:
+						var value string
+						entry.Value(i, &value)
+						cfg.QueryValues[name] =
+							append(cfg.QueryValues[name], value)
+					}
+				}
+			}
+		}
+
+	case "cookie":
+		if cfg.CookieValues == nil {
+			cfg.CookieValues = make(map[string][]string)
+		}
+		if cfg.CookieRegexps == nil {
+			cfg.CookieRegexps = make(map[string][]*regexp.Regexp)
+		}
+
+		var name string
+
+		if entry.Value(first+1, &name) {
+			if entry.NbValues() == first+2 {
+				cfg.CookieValues[name] = nil
+			}
+
+			for i := first + 2; i < entry.NbValues(); i++ {
+				var s bcl.String
+
+				if entry.Value(i, &s) {
+					switch s.Sigil {
+					case "re":
+						var re *regexp.Regexp
+						entry.Value(i, &re)
+						cfg.CookieRegexps[name] =
+							append(cfg.CookieRegexps[name], re)
+
+					default:
+						var value string
+						entry.Value(i, &value)
+						cfg.CookieValues[name] =
+							append(cfg.CookieValues[name], value)
+					}
+				}
+			}
+		}
+
 	case "path":
-		for i := 1; i < entry.NbValues(); i++ {
+		for i := first + 1; i < entry.NbValues(); i++ {
 			var s bcl.String
 
 			if entry.Value(i, &s) {
@@ -182,6 +335,7 @@ type Handler struct {
 	AccessLogger       *AccessLogger
 	Auth               Auth
 	RequestRateLimiter *netutils.RateLimiter
+	ErrorPages         *ErrorPages
 	Action             Action
 
 	Handlers []*Handler
@@ -219,6 +373,19 @@ func StartHandler(p *Protocol, cfg *HandlerCfg) (*Handler, error) {
 		h.RequestRateLimiter = netutils.NewRateLimiter(rlCfg)
 	}
 
+	if epCfg := cfg.ErrorPages; epCfg != nil && epCfg.Enabled {
+		errorPages, err := NewErrorPages(epCfg)
+		if err != nil {
+			if h.AccessLogger != nil {
+				h.AccessLogger.Close()
+			}
+
+			return nil, fmt.Errorf("cannot create error pages: %w", err)
+		}
+
+		h.ErrorPages = errorPages
+	}
+
 	var action Action
 	var err error
 
@@ -229,12 +396,22 @@ func StartHandler(p *Protocol, cfg *HandlerCfg) (*Handler, error) {
 		action, err = NewRedirectAction(&h, cfg.Redirect)
 	case cfg.Serve != nil:
 		action, err = NewServeAction(&h, cfg.Serve)
+	case cfg.WebDAV != nil:
+		action, err = NewWebDAVAction(&h, cfg.WebDAV)
 	case cfg.ReverseProxy != nil:
 		action, err = NewReverseProxyAction(&h, cfg.ReverseProxy)
 	case cfg.Status != nil:
 		action, err = NewStatusAction(&h, cfg.Status)
 	case cfg.FastCGI != nil:
 		action, err = NewFastCGIAction(&h, cfg.FastCGI)
+	case cfg.CGI != nil:
+		action, err = NewCGIAction(&h, cfg.CGI)
+	case cfg.SCGI != nil:
+		action, err = NewSCGIAction(&h, cfg.SCGI)
+	case cfg.UWSGI != nil:
+		action, err = NewUWSGIAction(&h, cfg.UWSGI)
+	case cfg.Rewrite != nil:
+		action, err = NewRewriteAction(&h, cfg.Rewrite)
 	default:
 		reply := ReplyActionCfg{Status: 200}
 		action, err = NewReplyAction(&h, &reply)
@@ -287,24 +464,88 @@ func (h *Handler) matchRequest(ctx *RequestContext) bool {
 	// is important because we try to match handlers recursively and fall back
 	// to the last parent handler which matched.
 
-	matchSpec := h.Cfg.Match
+	matchVars := make(map[string]string)
+
+	match, subpath := h.Cfg.Match.matchRequest(ctx, matchVars)
+	if !match {
+		return false
+	}
+
+	// We now have a full match, we can update the request context
+	//
+	// If next_handler is set, we update inheritable settings (e.g.
+	// authentication) but we do not return true because we need to find another
+	// matching handler. We also do not update context variables associated with
+	// a match for the same reason.
+
+	if h.AccessLogger != nil {
+		ctx.AccessLogger = h.AccessLogger
+	}
+
+	if h.Auth != nil {
+		ctx.Auth = h.Auth
+	}
+
+	if h.RequestRateLimiter != nil {
+		if h.RequestRateLimiter.Cfg.IsEmpty() {
+			ctx.RequestRateLimiter = nil
+		} else {
+			ctx.RequestRateLimiter = h.RequestRateLimiter
+		}
+	}
+
+	if compressionCfg := h.Cfg.Compression; compressionCfg != nil {
+		if compressionCfg.Enabled {
+			ctx.Compression = compressionCfg
+		} else {
+			ctx.Compression = nil
+		}
+	}
+
+	if corsCfg := h.Cfg.CORS; corsCfg != nil {
+		if corsCfg.Enabled {
+			ctx.CORS = corsCfg
+		} else {
+			ctx.CORS = nil
+		}
+	}
+
+	if epCfg := h.Cfg.ErrorPages; epCfg != nil {
+		ctx.ErrorPages = h.ErrorPages // nil if disabled
+	}
+
+	if h.Cfg.NextHandler {
+		return false
+	}
+
+	ctx.Subpath = subpath
+	ctx.Vars["http.match.subpath"] = subpath
+	maps.Copy(ctx.Vars, matchVars)
+
+	return true
+}
+
+func (cfg *MatchCfg) matchRequest(ctx *RequestContext, vars map[string]string) (bool, string) {
+	// Returns whether all constraints match, and if they do, the subpath
+	// associated with the path constraint if there is one. Regexp capture
+	// groups are stored in vars if it is not nil.
 
 	// TLS
-	if matchSpec.TLS != nil {
-		if *matchSpec.TLS == true && ctx.Request.TLS == nil {
-			return false
+	if cfg.TLS != nil {
+		if *cfg.TLS == true && ctx.Request.TLS == nil {
+			return false, ""
 		}
 
-		if *matchSpec.TLS == false && ctx.Request.TLS != nil {
-			return false
+		if *cfg.TLS == false && ctx.Request.TLS != nil {
+			return false, ""
 		}
 	}
 
 	// HTTP version
-	if len(matchSpec.HTTPVersions) > 0 {
+	if len(cfg.HTTPVersions) > 0 {
 		var versionMatch bool
 
-		for _, version := range matchSpec.HTTPVersions {
+		for _, version := range cfg.HTTPVersions {
 			if version.Match(ctx.Request.ProtoMajor, ctx.Request.ProtoMinor) {
 				versionMatch = true
 				break
@@ -312,22 +553,22 @@ func (h *Handler) matchRequest(ctx *RequestContext) bool {
 		}
 
 		if !versionMatch {
-			return false
+			return false, ""
 		}
 	}
 
 	// Method
-	if len(matchSpec.Methods) > 0 {
-		if !slices.Contains(matchSpec.Methods, ctx.Request.Method) {
-			return false
+	if len(cfg.Methods) > 0 {
+		if !slices.Contains(cfg.Methods, ctx.Request.Method) {
+			return false, ""
 		}
 	}
 
 	// Host
-	if len(matchSpec.Hosts) > 0 || len(matchSpec.HostRegexps) > 0 {
+	if len(cfg.Hosts) > 0 || len(cfg.HostRegexps) > 0 {
 		var hostMatch bool
 
-		if patterns := matchSpec.Hosts; len(patterns) > 0 {
+		if patterns := cfg.Hosts; len(patterns) > 0 {
 			for _, pattern := range patterns {
 				if pattern.Match(ctx.Host) {
 					hostMatch = true
@@ -337,10 +578,12 @@ func (h *Handler) matchRequest(ctx *RequestContext) bool {
 		}
 
 		if !hostMatch {
-			if res := matchSpec.HostRegexps; len(res) > 0 {
+			if res := cfg.HostRegexps; len(res) > 0 {
 				for _, re := range res {
-					if re.MatchString(ctx.Host) {
+					groups := re.FindStringSubmatch(ctx.Host)
+					if groups != nil {
 						hostMatch = true
+						setRegexpMatchVars(vars, "http.match.host", re, groups)
 						break
 					}
 				}
@@ -348,17 +591,34 @@ func (h *Handler) matchRequest(ctx *RequestContext) bool {
 		}
 
 		if !hostMatch {
-			return false
+			return false, ""
+		}
+	}
+
+	// Address
+	if len(cfg.Addresses) > 0 {
+		var addressMatch bool
+
+		for _, addr := range cfg.Addresses {
+			ipNet := net.IPNet(*addr)
+			if ipNet.Contains(ctx.ClientAddress) {
+				addressMatch = true
+				break
+			}
+		}
+
+		if !addressMatch {
+			return false, ""
 		}
 	}
 
 	// Header
-	if len(matchSpec.HeaderValues) > 0 || len(matchSpec.HeaderRegexps) > 0 {
+	if len(cfg.HeaderValues) > 0 || len(cfg.HeaderRegexps) > 0 {
 		header := ctx.Request.Header
 		var headerMatch bool
 
 	outer1:
-		for name, expectedValues := range matchSpec.HeaderValues {
+		for name, expectedValues := range cfg.HeaderValues {
 			if len(expectedValues) == 0 {
 				headerMatch = len(header.Values(name)) > 0
 			} else {
@@ -373,7 +633,7 @@ func (h *Handler) matchRequest(ctx *RequestContext) bool {
 
 		if !headerMatch {
 		outer2:
-			for name, res := range matchSpec.HeaderRegexps {
+			for name, res := range cfg.HeaderRegexps {
 				for _, value := range header.Values(name) {
 					for _, re := range res {
 						if re.MatchString(value) {
@@ -386,17 +646,92 @@ func (h *Handler) matchRequest(ctx *RequestContext) bool {
 		}
 
 		if !headerMatch {
-			return false
+			return false, ""
+		}
+	}
+
+	// Query
+	if len(cfg.QueryValues) > 0 || len(cfg.QueryRegexps) > 0 {
+		query := ctx.Request.URL.Query()
+		var queryMatch bool
+
+	outer3:
+		for name, expectedValues := range cfg.QueryValues {
+			if len(expectedValues) == 0 {
+				if query.Has(name) {
+					queryMatch = true
+					break
+				}
+			} else {
+				for _, value := range query[name] {
+					if slices.Contains(expectedValues, value) {
+						queryMatch = true
+						break outer3
+					}
+				}
+			}
+		}
+
+		if !queryMatch {
+		outer4:
+			for name, res := range cfg.QueryRegexps {
+				for _, value := range query[name] {
+					for _, re := range res {
+						if re.MatchString(value) {
+							queryMatch = true
+							break outer4
+						}
+					}
+				}
+			}
+		}
+
+		if !queryMatch {
+			return false, ""
+		}
+	}
+
+	// Cookie
+	if len(cfg.CookieValues) > 0 || len(cfg.CookieRegexps) > 0 {
+		var cookieMatch bool
+
+	outer5:
+		for name, expectedValues := range cfg.CookieValues {
+			for _, cookie := range ctx.Request.CookiesNamed(name) {
+				if len(expectedValues) == 0 ||
+					slices.Contains(expectedValues, cookie.Value) {
+					cookieMatch = true
+					break outer5
+				}
+			}
+		}
+
+		if !cookieMatch {
+		outer6:
+			for name, res := range cfg.CookieRegexps {
+				for _, cookie := range ctx.Request.CookiesNamed(name) {
+					for _, re := range res {
+						if re.MatchString(cookie.Value) {
+							cookieMatch = true
+							break outer6
+						}
+					}
+				}
+			}
+		}
+
+		if !cookieMatch {
+			return false, ""
 		}
 	}
 
 	// Path
 	var subpath string
 
-	if matchSpec.HasPaths() {
+	if cfg.HasPaths() {
 		var pathMatch bool
 
-		if patterns := matchSpec.Paths; len(patterns) > 0 {
+		if patterns := cfg.Paths; len(patterns) > 0 {
 			for _, pattern := range patterns {
 				refPath := ctx.Request.URL.Path
 				if pattern.Relative {
@@ -413,10 +748,12 @@ func (h *Handler) matchRequest(ctx *RequestContext) bool {
 		}
 
 		if !pathMatch {
-			if res := matchSpec.PathRegexps; len(res) > 0 {
+			if res := cfg.PathRegexps; len(res) > 0 {
 				for _, re := range res {
-					if re.MatchString(ctx.Request.URL.Path) {
+					groups := re.FindStringSubmatch(ctx.Request.URL.Path)
+					if groups != nil {
 						pathMatch = true
+						setRegexpMatchVars(vars, "http.match.path", re, groups)
 						break
 					}
 				}
@@ -424,39 +761,32 @@ func (h *Handler) matchRequest(ctx *RequestContext) bool {
 		}
 
 		if !pathMatch {
-			return false
+			return false, ""
 		}
 	}
 
-	// We now have a full match, we can update the request context
-	//
-	// If next_handler is set, we update inheritable settings (e.g.
-	// authentication) but we do not return true because we need to find another
-	// matching handler. We also do not update context variables associated with
-	// a match for the same reason.
-
-	if h.AccessLogger != nil {
-		ctx.AccessLogger = h.AccessLogger
+	// Negated constraints
+	for _, negatedCfg := range cfg.Negations {
+		if match, _ := negatedCfg.matchRequest(ctx, nil); match {
+			return false, ""
+		}
 	}
 
-	if h.Auth != nil {
-		ctx.Auth = h.Auth
-	}
+	return true, subpath
+}
 
-	if h.RequestRateLimiter != nil {
-		if h.RequestRateLimiter.Cfg.IsEmpty() {
-			ctx.RequestRateLimiter = nil
-		} else {
-			ctx.RequestRateLimiter = h.RequestRateLimiter
-		}
+func setRegexpMatchVars(vars map[string]string, prefix string, re *regexp.Regexp, groups []string) {
+	if vars == nil {
+		return
 	}
 
-	if h.Cfg.NextHandler {
-		return false
-	}
+	names := re.SubexpNames()
 
-	ctx.Subpath = subpath
-	ctx.Vars["http.match.subpath"] = subpath
+	for i, group := range groups {
+		vars[prefix+"."+strconv.Itoa(i)] = group
 
-	return true
+		if name := names[i]; name != "" {
+			vars[prefix+"."+name] = group
+		}
+	}
 }
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.n16f.net/bcl"
+)
+
+func TestMatchCfgNegation(t *testing.T) {
+	assert := assert.New(t)
+
+	readMatchCfg := func(s string) (*MatchCfg, error) {
+		doc, err := bcl.Parse([]byte(s), "test")
+		if err != nil {
+			return nil, err
+		}
+
+		var cfg MatchCfg
+		for _, entry := range doc.TopLevel.FindEntries("match") {
+			cfg.ReadBCLEntry(entry)
+		}
+
+		if errs := doc.ValidationErrors(); errs != nil {
+			return nil, errs
+		}
+
+		return &cfg, nil
+	}
+
+	cfg, err := readMatchCfg(`match not header "X-Foo"
+match not path "/foo/..."
+`)
+	if assert.NoError(err) {
+		assert.Len(cfg.Negations, 2)
+	}
+
+	invalidTests := []string{
+		"match not",
+		"match not header",
+		"match not path",
+		"match not method",
+		"match not tls",
+		`match not foo "bar"`,
+	}
+
+	for _, s := range invalidTests {
+		_, err := readMatchCfg(s)
+		assert.Error(err, s)
+	}
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"fmt"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -31,6 +32,7 @@ const (
 type ProtocolCfg struct {
 	Handlers     []*HandlerCfg
 	AccessLogger *AccessLoggerCfg
+	ErrorPages   *ErrorPagesCfg
 	TLSHandling  TLSHandling
 	HSTS         bool
 
@@ -49,6 +51,7 @@ func NewProtocolCfg() boulevard.ProtocolCfg {
 func (cfg *ProtocolCfg) ReadBCLElement(block *bcl.Element) error {
 	block.Blocks("handler", &cfg.Handlers)
 	block.MaybeBlock("access_logs", &cfg.AccessLogger)
+	block.MaybeElement("error_pages", &cfg.ErrorPages)
 
 	cfg.TLSHandling = TLSHandlingAccept
 	if entry := block.FindEntry("tls"); entry != nil {
@@ -79,6 +82,7 @@ type Protocol struct {
 
 	vars               map[string]string
 	accessLogger       *AccessLogger
+	errorPages         *ErrorPages
 	handlers           []*Handler
 	servers            []*Server
 	defaultTLSListener *boulevard.Listener
@@ -112,6 +116,15 @@ func (p *Protocol) Start(server *boulevard.Server) error {
 		p.accessLogger = log
 	}
 
+	if epCfg := p.Cfg.ErrorPages; epCfg != nil && epCfg.Enabled {
+		errorPages, err := NewErrorPages(epCfg)
+		if err != nil {
+			return fmt.Errorf("cannot create error pages: %w", err)
+		}
+
+		p.errorPages = errorPages
+	}
+
 	p.handlers = make([]*Handler, len(p.Cfg.Handlers))
 	for i, cfg := range p.Cfg.Handlers {
 		handler, err := StartHandler(p, cfg)
@@ -222,6 +235,69 @@ func (p *Protocol) findHandler(ctx *RequestContext) *Handler {
 	return find(p.handlers, nil)
 }
 
+func (p *Protocol) handleRequest(ctx *RequestContext) {
+	h := p.findHandler(ctx)
+	if h == nil {
+		ctx.ReplyError2(404, "unhandled request")
+		return
+	}
+
+	// A rewritten request is dispatched again; we do not want to count it
+	// twice if it ends up in the scope of the same rate limiter.
+	rl := ctx.RequestRateLimiter
+	if rl != nil && rl != ctx.appliedRateLimiter {
+		if rl.Update(1, ctx.ClientAddress, ctx.StartTime) == false {
+			ctx.ReplyError2(429, "rate limit reached")
+			return
+		}
+
+		ctx.appliedRateLimiter = rl
+	}
+
+	// Preflight requests never contain credentials, they must be handled
+	// before authentication.
+	if cfg := ctx.CORS; cfg != nil {
+		if isCORSPreflightRequest(ctx.Request) {
+			ctx.replyCORSPreflight(cfg)
+			return
+		}
+
+		if ctx.corsWriter == nil {
+			ctx.ResponseWriter.Wrap(func(w http.ResponseWriter) http.ResponseWriter {
+				ctx.corsWriter = NewCORSWriter(ctx, w)
+				return ctx.corsWriter
+			})
+		}
+	}
+
+	if ctx.Auth != nil {
+		if err := ctx.Auth.AuthenticateRequest(ctx); err != nil {
+			ctx.Log.Error("cannot authenticate request: %v", err)
+			return
+		}
+	}
+
+	if h.Action == nil {
+		ctx.ReplyError(501)
+		return
+	}
+
+	if cfg := ctx.Compression; cfg != nil && ctx.compressionWriter == nil {
+		ctx.ResponseWriter.Wrap(func(w http.ResponseWriter) http.ResponseWriter {
+			ctx.compressionWriter = NewCompressionWriter(cfg, ctx, w)
+			return ctx.compressionWriter
+		})
+
+		defer func() {
+			if err := ctx.compressionWriter.Close(); err != nil {
+				ctx.Log.Error("cannot finalize response body: %v", err)
+			}
+		}()
+	}
+
+	h.Action.HandleRequest(ctx)
+}
+
 func (p *Protocol) registerTCPConnection(c *TCPConnection) {
 	p.tcpConnectionMutex.Lock()
 	p.tcpConnections[c] = struct{}{}
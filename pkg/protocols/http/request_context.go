@@ -9,6 +9,7 @@ import (
 	"maps"
 	"net"
 	nethttp "net/http"
+	"path"
 	"slices"
 	"strconv"
 	"strings"
@@ -25,12 +26,16 @@ type RequestContext struct {
 	Log                *log.Logger
 	Ctx                context.Context
 	Request            *nethttp.Request
+	OriginalRequest    *nethttp.Request // nil if the request was not rewritten
 	ResponseWriter     *httputils.ResponseWriter
 	Protocol           *Protocol
 	Listener           *boulevard.Listener
 	AccessLogger       *AccessLogger
 	Auth               Auth
 	RequestRateLimiter *netutils.RateLimiter
+	Compression        *CompressionCfg
+	CORS               *CORSCfg
+	ErrorPages         *ErrorPages
 
 	ClientAddress     net.IP
 	Host              string
@@ -38,12 +43,17 @@ type RequestContext struct {
 	ConnectionOptions []string // [1]
 	UpgradeProtocols  []string // [1]
 	Username          string   // basic authentication only
+	NbRewrites        int
 
 	StartTime    time.Time
 	ResponseTime time.Duration
 
 	Vars map[string]string
 
+	appliedRateLimiter *netutils.RateLimiter
+	compressionWriter  *CompressionWriter
+	corsWriter         *CORSWriter
+
 	// [1] Normalized to lower case.
 }
 
@@ -112,6 +122,87 @@ func (ctx *RequestContext) initVars() {
 	ctx.Vars["http.request.uri"] = ctx.Request.URL.String()
 	ctx.Vars["http.request.path"] = ctx.Request.URL.Path
 	ctx.Vars["http.request.query"] = ctx.Request.URL.RawQuery
+
+	for name, values := range ctx.Request.URL.Query() {
+		if len(values) > 0 {
+			ctx.Vars["http.request.query."+name] = values[0]
+		}
+	}
+
+	for _, cookie := range ctx.Request.Cookies() {
+		name := "http.request.cookie." + cookie.Name
+		if _, found := ctx.Vars[name]; !found {
+			ctx.Vars[name] = cookie.Value
+		}
+	}
+}
+
+func (ctx *RequestContext) saveOriginalRequest() {
+	if ctx.OriginalRequest != nil {
+		return
+	}
+
+	req := *ctx.Request
+	uri := *req.URL
+	req.URL = &uri
+
+	ctx.OriginalRequest = &req
+}
+
+func (ctx *RequestContext) rewrite(uriPath, rawQuery string) {
+	ctx.saveOriginalRequest()
+
+	uri := ctx.Request.URL
+
+	uri.Path = path.Join("/", uriPath)
+	if strings.HasSuffix(uriPath, "/") && uri.Path != "/" {
+		uri.Path += "/"
+	}
+	uri.RawPath = ""
+	uri.RawQuery = rawQuery
+
+	ctx.Request.RequestURI = uri.RequestURI()
+
+	// Variables derived from the request or from a handler match must not
+	// leak into the processing of the rewritten request.
+	for name := range ctx.Vars {
+		if strings.HasPrefix(name, "http.request.query.") ||
+			strings.HasPrefix(name, "http.request.cookie.") ||
+			strings.HasPrefix(name, "http.match.") {
+			delete(ctx.Vars, name)
+		}
+	}
+
+	delete(ctx.Vars, "http.request.username")
+	ctx.Username = ""
+
+	ctx.initSubpath()
+	ctx.initVars()
+
+	// The request will go through handler selection again, starting from the
+	// top-level handlers.
+	ctx.AccessLogger = ctx.Protocol.accessLogger
+	ctx.Auth = nil
+	ctx.RequestRateLimiter = nil
+	ctx.Compression = nil
+	ctx.CORS = nil
+	ctx.ErrorPages = ctx.Protocol.errorPages
+}
+
+// reprocess rewrites the request and runs it through handler selection again,
+// making sure a request cannot be rewritten indefinitely.
+func (ctx *RequestContext) reprocess(uriPath, rawQuery string) {
+	if ctx.NbRewrites >= MaxRequestRewrites {
+		ctx.Log.Error("too many request rewrites, last path was %q",
+			ctx.Request.URL.Path)
+		ctx.ReplyError(500)
+		return
+	}
+
+	ctx.NbRewrites++
+
+	ctx.rewrite(uriPath, rawQuery)
+	ctx.Protocol.handleRequest(ctx)
 }
 
 func (ctx *RequestContext) Recover() {
@@ -234,10 +325,20 @@ func (ctx *RequestContext) ReplyError(status int) {
 }
 
 func (ctx *RequestContext) ReplyError2(status int, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+
+	if ctx.ErrorPages != nil {
+		ctx.ErrorPages.Reply(ctx, status, msg)
+		return
+	}
+
+	ctx.replyTextError(status, msg)
+}
+
+func (ctx *RequestContext) replyTextError(status int, msg string) {
 	header := ctx.ResponseWriter.Header()
 	header.Set("Content-Type", MediaTypeText.String())
 
-	msg := fmt.Sprintf(format, args...)
 	body := io.MultiReader(strings.NewReader(msg), strings.NewReader("\n"))
 	ctx.Reply(status, body)
 }
@@ -270,6 +371,28 @@ func (ctx *RequestContext) AcceptedMediaRanges() []*MediaRange {
 	return ranges
 }
 
+func (ctx *RequestContext) AcceptedContentCodings() []*ContentCodingRange {
+	value := ctx.Request.Header.Get("Accept-Encoding")
+	parts := strings.Split(value, ",")
+
+	var ranges []*ContentCodingRange
+	for _, part := range parts {
+		part = strings.Trim(part, " \t")
+		if part == "" {
+			continue
+		}
+
+		var r ContentCodingRange
+		if err := r.Parse(part); err != nil {
+			continue
+		}
+
+		ranges = append(ranges, &r)
+	}
+
+	return ranges
+}
+
 func (ctx *RequestContext) LogVariables() {
 	keys := slices.Collect(maps.Keys(ctx.Vars))
 	slices.Sort(keys)
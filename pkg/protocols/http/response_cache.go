@@ -0,0 +1,794 @@
+package http
+
+import (
+	"container/list"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/boulevard/pkg/boulevard"
+	"go.n16f.net/boulevard/pkg/httputils"
+	"go.n16f.net/log"
+)
+
+const (
+	DefaultResponseCacheKey              = "{http.request.host}{http.request.uri}"
+	DefaultResponseCacheMaxSize          = 64 * 1024 * 1024
+	DefaultResponseCacheMaxEntrySize     = 4 * 1024 * 1024
+	DefaultResponseCacheMemoryBufferSize = 128 * 1024
+)
+
+// Each stored entry is made of two files in the cache directory: the body
+// file, whose name is random, and the entry file containing everything else.
+const responseCacheEntryFileExtension = ".entry"
+
+// RFC 9111 3. Storing Responses in Caches. We only store responses whose
+// status code is defined as heuristically cacheable (RFC 9110 15.1).
+var responseCacheStatuses = []int{
+	200, 203, 204, 300, 301, 308, 404, 405, 410, 414, 501,
+}
+
+type ResponseCacheCfg struct {
+	Enabled          bool
+	Key              *boulevard.FormatString
+	MaxSize          int64
+	MaxEntrySize     int64
+	MemoryBufferSize int64
+	Directory        string // entries are persisted if set
+}
+
+func (cfg *ResponseCacheCfg) ReadBCLElement(elt *bcl.Element) error {
+	cfg.MaxSize = DefaultResponseCacheMaxSize
+	cfg.MaxEntrySize = DefaultResponseCacheMaxEntrySize
+	cfg.MemoryBufferSize = DefaultResponseCacheMemoryBufferSize
+
+	if elt.IsBlock() {
+		cfg.Enabled = true
+
+		elt.MaybeEntryValues("key", &cfg.Key)
+
+		elt.MaybeEntryValues("max_size",
+			bcl.WithValueValidation(&cfg.MaxSize,
+				bcl.ValidatePositiveInteger))
+		elt.MaybeEntryValues("max_entry_size",
+			bcl.WithValueValidation(&cfg.MaxEntrySize,
+				bcl.ValidatePositiveInteger))
+		elt.MaybeEntryValues("memory_buffer_size",
+			bcl.WithValueValidation(&cfg.MemoryBufferSize,
+				bcl.ValidatePositiveInteger))
+
+		elt.MaybeEntryValues("directory", &cfg.Directory)
+	} else {
+		elt.Values(&cfg.Enabled)
+	}
+
+	if cfg.Key == nil {
+		var key boulevard.FormatString
+		key.Parse(DefaultResponseCacheKey)
+		cfg.Key = &key
+	}
+
+	return nil
+}
+
+type ResponseCacheEntry struct {
+	Key        string
+	VaryValues map[string]string // lower case field name -> value
+
+	Status int
+	Header http.Header
+	Body   *boulevard.SpillBuffer
+
+	RequestTime  time.Time
+	ResponseTime time.Time
+
+	revalidating atomic.Bool
+	element      *list.Element
+}
+
+type responseCacheEntryData struct {
+	Key        string            `json:"key"`
+	VaryValues map[string]string `json:"vary_values,omitempty"`
+
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+
+	RequestTime  time.Time `json:"request_time"`
+	ResponseTime time.Time `json:"response_time"`
+}
+
+type ResponseCache struct {
+	Cfg *ResponseCacheCfg
+	Log *log.Logger
+
+	dirPath    string
+	persistent bool
+
+	entries map[string][]*ResponseCacheEntry // key -> variants
+	lru     *list.List
+	size    int64
+	mutex   sync.Mutex
+}
+
+func NewResponseCache(cfg *ResponseCacheCfg, logger *log.Logger) (*ResponseCache, error) {
+	c := ResponseCache{
+		Cfg: cfg,
+		Log: logger,
+
+		entries: make(map[string][]*ResponseCacheEntry),
+		lru:     list.New(),
+	}
+
+	if cfg.Directory == "" {
+		dirPath, err := os.MkdirTemp("", "boulevard-cache-*")
+		if err != nil {
+			return nil, fmt.Errorf("cannot create temporary directory: %w", err)
+		}
+
+		c.dirPath = dirPath
+	} else {
+		if err := os.MkdirAll(cfg.Directory, 0700); err != nil {
+			return nil, fmt.Errorf("cannot create directory %q: %w",
+				cfg.Directory, err)
+		}
+
+		c.dirPath = cfg.Directory
+		c.persistent = true
+
+		if err := c.load(); err != nil {
+			return nil, fmt.Errorf("cannot load entries from %q: %w",
+				cfg.Directory, err)
+		}
+	}
+
+	return &c, nil
+}
+
+func (c *ResponseCache) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, variants := range c.entries {
+		for _, entry := range variants {
+			if c.persistent {
+				// Entry files are kept so that entries are loaded again on
+				// the next start.
+				if err := entry.Body.Release(); err != nil {
+					c.Log.Error("cannot release cache entry body: %v", err)
+				}
+			} else {
+				c.closeEntry(entry)
+			}
+		}
+	}
+
+	c.entries = make(map[string][]*ResponseCacheEntry)
+	c.lru.Init()
+	c.size = 0
+
+	if !c.persistent {
+		if err := os.RemoveAll(c.dirPath); err != nil {
+			c.Log.Error("cannot delete directory %q: %v", c.dirPath, err)
+		}
+	}
+}
+
+func (c *ResponseCache) Key(ctx *RequestContext) string {
+	return c.Cfg.Key.Expand(ctx.Vars)
+}
+
+func (c *ResponseCache) NewBodyBuffer() *boulevard.SpillBuffer {
+	fileName := hex.EncodeToString(boulevard.RandomBytes(16))
+	filePath := path.Join(c.dirPath, fileName)
+
+	return boulevard.NewSpillBuffer(filePath, c.Cfg.MemoryBufferSize,
+		c.Cfg.MaxEntrySize)
+}
+
+func (c *ResponseCache) Lookup(key string, reqHeader http.Header) (*ResponseCacheEntry, io.ReadCloser, error) {
+	// We open the body while holding the lock so that the entry cannot be
+	// evicted in the meantime; once open, the body stays readable even if the
+	// entry is deleted.
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, entry := range c.entries[key] {
+		if entry.matchVaryValues(reqHeader) {
+			body, err := entry.Body.Reader()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			c.lru.MoveToFront(entry.element)
+			return entry, body, nil
+		}
+	}
+
+	return nil, nil, nil
+}
+
+func (c *ResponseCache) Store(entry *ResponseCacheEntry) {
+	size := entry.Body.Size()
+
+	if c.persistent {
+		if err := entry.Body.Persist(); err != nil {
+			c.Log.Error("cannot persist cache entry body: %v", err)
+			c.closeEntry(entry)
+			return
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.persistent {
+		if err := c.writeEntryFile(entry); err != nil {
+			c.Log.Error("cannot write cache entry: %v", err)
+			c.closeEntry(entry)
+			return
+		}
+	}
+
+	variants := c.entries[entry.Key]
+
+	// Replace any existing variant for the same values of the fields listed
+	// in Vary.
+	variants = slices.DeleteFunc(variants, func(e *ResponseCacheEntry) bool {
+		if e.sameVariant(entry) {
+			c.removeEntry(e)
+			return true
+		}
+
+		return false
+	})
+
+	entry.element = c.lru.PushFront(entry)
+	c.entries[entry.Key] = append(variants, entry)
+	c.size += size
+
+	for c.size > c.Cfg.MaxSize && c.lru.Len() > 1 {
+		oldest := c.lru.Back().Value.(*ResponseCacheEntry)
+		c.deleteEntry(oldest)
+	}
+}
+
+func (c *ResponseCache) Refresh(entry *ResponseCacheEntry, res *http.Response, reqTime, resTime time.Time) *ResponseCacheEntry {
+	// RFC 9111 4.3.4. Freshening Stored Responses upon Validation
+	//
+	// Entries are never modified once stored since they can be used by
+	// concurrent requests: we replace the entry with a new one sharing the
+	// same body. If the entry was evicted in the meantime, its body is closed
+	// and we return nil.
+
+	header := entry.Header.Clone()
+
+	for name, values := range res.Header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Length", "Content-Encoding", "Content-Range",
+			"Transfer-Encoding":
+			continue
+		}
+
+		header[name] = slices.Clone(values)
+	}
+
+	newEntry := ResponseCacheEntry{
+		Key:        entry.Key,
+		VaryValues: entry.VaryValues,
+
+		Status: entry.Status,
+		Header: header,
+		Body:   entry.Body,
+
+		RequestTime:  reqTime,
+		ResponseTime: resTime,
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	variants := c.entries[entry.Key]
+
+	idx := slices.Index(variants, entry)
+	if idx == -1 {
+		return nil
+	}
+
+	variants[idx] = &newEntry
+
+	newEntry.element = entry.element
+	newEntry.element.Value = &newEntry
+	c.lru.MoveToFront(newEntry.element)
+
+	if c.persistent {
+		// If the entry file cannot be updated, the previous version stays
+		// valid for the next start.
+		if err := c.writeEntryFile(&newEntry); err != nil {
+			c.Log.Error("cannot write cache entry: %v", err)
+		}
+	}
+
+	return &newEntry
+}
+
+func (c *ResponseCache) Delete(entry *ResponseCacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.deleteEntry(entry)
+}
+
+func (c *ResponseCache) deleteEntry(entry *ResponseCacheEntry) {
+	variants := c.entries[entry.Key]
+
+	idx := slices.Index(variants, entry)
+	if idx == -1 {
+		return
+	}
+
+	variants = slices.Delete(variants, idx, idx+1)
+	if len(variants) == 0 {
+		delete(c.entries, entry.Key)
+	} else {
+		c.entries[entry.Key] = variants
+	}
+
+	c.removeEntry(entry)
+}
+
+func (c *ResponseCache) removeEntry(entry *ResponseCacheEntry) {
+	c.lru.Remove(entry.element)
+	c.size -= entry.Body.Size()
+	c.closeEntry(entry)
+}
+
+func (c *ResponseCache) closeEntry(entry *ResponseCacheEntry) {
+	if c.persistent {
+		filePath := entryFilePath(entry.Body.FilePath())
+		err := os.Remove(filePath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			c.Log.Error("cannot delete %q: %v", filePath, err)
+		}
+	}
+
+	// Readers which opened the spilled body file before it is deleted can
+	// still read it.
+	if err := entry.Body.Close(); err != nil {
+		c.Log.Error("cannot close cache entry body: %v", err)
+	}
+}
+
+func (c *ResponseCache) writeEntryFile(entry *ResponseCacheEntry) error {
+	data := responseCacheEntryData{
+		Key:        entry.Key,
+		VaryValues: entry.VaryValues,
+
+		Status: entry.Status,
+		Header: entry.Header,
+
+		RequestTime:  entry.RequestTime,
+		ResponseTime: entry.ResponseTime,
+	}
+
+	content, err := json.Marshal(&data)
+	if err != nil {
+		return fmt.Errorf("cannot encode entry: %w", err)
+	}
+
+	// The entry file is written atomically so that an interrupted write never
+	// leaves a truncated file behind.
+	filePath := entryFilePath(entry.Body.FilePath())
+	tmpFilePath := filePath + ".tmp"
+
+	if err := os.WriteFile(tmpFilePath, content, 0600); err != nil {
+		return fmt.Errorf("cannot write %q: %w", tmpFilePath, err)
+	}
+
+	if err := os.Rename(tmpFilePath, filePath); err != nil {
+		os.Remove(tmpFilePath)
+		return fmt.Errorf("cannot rename %q: %w", tmpFilePath, err)
+	}
+
+	return nil
+}
+
+func (c *ResponseCache) load() error {
+	dirEntries, err := os.ReadDir(c.dirPath)
+	if err != nil {
+		return err
+	}
+
+	var entries []*ResponseCacheEntry
+	bodyFiles := make(map[string]struct{})
+
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+
+		bodyFileName, found := strings.CutSuffix(name,
+			responseCacheEntryFileExtension)
+		if !found || !dirEntry.Type().IsRegular() {
+			continue
+		}
+
+		entry, err := c.loadEntry(path.Join(c.dirPath, bodyFileName))
+		if err != nil {
+			c.Log.Error("cannot load cache entry %q: %v", name, err)
+			continue
+		}
+
+		entries = append(entries, entry)
+		bodyFiles[bodyFileName] = struct{}{}
+	}
+
+	// Files left by responses which were being buffered or deleted when the
+	// server stopped are of no use.
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+
+		bodyFileName := strings.TrimSuffix(name, ".tmp")
+		bodyFileName = strings.TrimSuffix(bodyFileName,
+			responseCacheEntryFileExtension)
+
+		if _, found := bodyFiles[bodyFileName]; found ||
+			!isResponseCacheFileName(bodyFileName) {
+			continue
+		}
+
+		filePath := path.Join(c.dirPath, name)
+		if err := os.Remove(filePath); err != nil {
+			c.Log.Error("cannot delete %q: %v", filePath, err)
+		}
+	}
+
+	// We do not know when entries were last used, so the most recent
+	// responses are considered the most recently used.
+	slices.SortFunc(entries, func(e1, e2 *ResponseCacheEntry) int {
+		return e1.ResponseTime.Compare(e2.ResponseTime)
+	})
+
+	for _, entry := range entries {
+		// The server may have stopped after storing an entry but before
+		// deleting the previous version of the same variant.
+		variants := slices.DeleteFunc(c.entries[entry.Key],
+			func(e *ResponseCacheEntry) bool {
+				if e.sameVariant(entry) {
+					c.removeEntry(e)
+					return true
+				}
+
+				return false
+			})
+
+		entry.element = c.lru.PushFront(entry)
+		c.entries[entry.Key] = append(variants, entry)
+		c.size += entry.Body.Size()
+	}
+
+	for c.size > c.Cfg.MaxSize && c.lru.Len() > 0 {
+		oldest := c.lru.Back().Value.(*ResponseCacheEntry)
+		c.deleteEntry(oldest)
+	}
+
+	c.Log.Debug(1, "loaded %d cache entries from %q", c.lru.Len(), c.dirPath)
+
+	return nil
+}
+
+func (c *ResponseCache) loadEntry(bodyFilePath string) (*ResponseCacheEntry, error) {
+	filePath := entryFilePath(bodyFilePath)
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %w", filePath, err)
+	}
+
+	var data responseCacheEntryData
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("cannot decode %q: %w", filePath, err)
+	}
+
+	body, err := boulevard.LoadSpillBuffer(bodyFilePath,
+		c.Cfg.MemoryBufferSize, c.Cfg.MaxEntrySize)
+	if err != nil {
+		if errors.Is(err, boulevard.ErrSpillBufferFull) {
+			err = errors.New("body larger than the maximum entry size")
+		}
+
+		return nil, err
+	}
+
+	entry := ResponseCacheEntry{
+		Key:        data.Key,
+		VaryValues: data.VaryValues,
+
+		Status: data.Status,
+		Header: data.Header,
+		Body:   body,
+
+		RequestTime:  data.RequestTime,
+		ResponseTime: data.ResponseTime,
+	}
+
+	return &entry, nil
+}
+
+func entryFilePath(bodyFilePath string) string {
+	return bodyFilePath + responseCacheEntryFileExtension
+}
+
+func isResponseCacheFileName(name string) bool {
+	// See NewBodyBuffer
+	data, err := hex.DecodeString(name)
+	return err == nil && len(data) == 16
+}
+
+func NewResponseCacheEntry(key string, reqHeader http.Header, res *http.Response, body *boulevard.SpillBuffer, reqTime, resTime time.Time) *ResponseCacheEntry {
+	entry := ResponseCacheEntry{
+		Key: key,
+
+		Status: res.StatusCode,
+		Header: res.Header.Clone(),
+		Body:   body,
+
+		RequestTime:  reqTime,
+		ResponseTime: resTime,
+	}
+
+	for _, name := range responseVaryFieldNames(res.Header) {
+		if entry.VaryValues == nil {
+			entry.VaryValues = make(map[string]string)
+		}
+
+		entry.VaryValues[name] = strings.Join(reqHeader.Values(name), ", ")
+	}
+
+	return &entry
+}
+
+func (e *ResponseCacheEntry) matchVaryValues(reqHeader http.Header) bool {
+	// RFC 9111 4.1. Calculating Cache Keys with the Vary Header Field. We do
+	// not normalize values, which only means that we may store more variants
+	// than necessary.
+
+	for name, value := range e.VaryValues {
+		if strings.Join(reqHeader.Values(name), ", ") != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *ResponseCacheEntry) sameVariant(e2 *ResponseCacheEntry) bool {
+	if len(e.VaryValues) != len(e2.VaryValues) {
+		return false
+	}
+
+	for name, value := range e.VaryValues {
+		if value2, found := e2.VaryValues[name]; !found || value2 != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *ResponseCacheEntry) CacheControl() httputils.CacheControl {
+	return httputils.ParseCacheControl(e.Header)
+}
+
+func (e *ResponseCacheEntry) Age(now time.Time) time.Duration {
+	// RFC 9111 4.2.3. Calculating Age
+
+	var ageValue time.Duration
+	if s := e.Header.Get("Age"); s != "" {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil && i > 0 {
+			ageValue = time.Duration(i) * time.Second
+		}
+	}
+
+	responseDelay := e.ResponseTime.Sub(e.RequestTime)
+	correctedInitialAge := ageValue + responseDelay
+
+	return correctedInitialAge + now.Sub(e.ResponseTime)
+}
+
+func (e *ResponseCacheEntry) FreshnessLifetime() time.Duration {
+	return responseFreshnessLifetime(e.Header, e.ResponseTime)
+}
+
+func (e *ResponseCacheEntry) IsFresh(now time.Time) bool {
+	if e.CacheControl().Has("no-cache") {
+		return false
+	}
+
+	return e.Age(now) < e.FreshnessLifetime()
+}
+
+func (e *ResponseCacheEntry) CanServeStale(now time.Time) bool {
+	// RFC 5861 3. The stale-while-revalidate Cache-Control Extension
+
+	cc := e.CacheControl()
+
+	if cc.Has("no-cache") || cc.Has("must-revalidate") ||
+		cc.Has("proxy-revalidate") {
+		return false
+	}
+
+	window, ok := cc.Duration("stale-while-revalidate")
+	if !ok {
+		return false
+	}
+
+	return e.Age(now) < e.FreshnessLifetime()+window
+}
+
+func (e *ResponseCacheEntry) HasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+func (e *ResponseCacheEntry) SetConditionalRequestHeader(header http.Header) {
+	// RFC 9111 4.3.1. Sending a Validation Request
+	header.Del("If-None-Match")
+	header.Del("If-Modified-Since")
+	header.Del("If-Match")
+	header.Del("If-Unmodified-Since")
+	header.Del("If-Range")
+
+	if etag := e.Header.Get("ETag"); etag != "" {
+		header.Set("If-None-Match", etag)
+	} else if lastModified := e.Header.Get("Last-Modified"); lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+	}
+}
+
+func (e *ResponseCacheEntry) IsNotModified(reqHeader http.Header) bool {
+	// RFC 9110 13.1.2. If-None-Match and 13.1.3. If-Modified-Since; the
+	// latter is ignored if the former is present.
+
+	if values := reqHeader.Values("If-None-Match"); len(values) > 0 {
+		etag := e.Header.Get("ETag")
+		if etag == "" {
+			return false
+		}
+
+		for _, value := range values {
+			for _, tag := range httputils.SplitTokenList(value, false) {
+				if tag == "*" || weakETagMatch(tag, etag) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	if value := reqHeader.Get("If-Modified-Since"); value != "" {
+		since, err := http.ParseTime(value)
+		if err != nil {
+			return false
+		}
+
+		lastModified, err := http.ParseTime(e.Header.Get("Last-Modified"))
+		if err != nil {
+			return false
+		}
+
+		return !lastModified.After(since)
+	}
+
+	return false
+}
+
+func weakETagMatch(tag1, tag2 string) bool {
+	// RFC 9110 8.8.3.2. Comparison
+	return strings.TrimPrefix(tag1, "W/") == strings.TrimPrefix(tag2, "W/")
+}
+
+func responseVaryFieldNames(header http.Header) []string {
+	var names []string
+
+	for _, value := range header.Values("Vary") {
+		names = append(names, httputils.SplitTokenList(value, true)...)
+	}
+
+	return names
+}
+
+func responseFreshnessLifetime(header http.Header, resTime time.Time) time.Duration {
+	// RFC 9111 4.2.1. Calculating Freshness Lifetime
+
+	cc := httputils.ParseCacheControl(header)
+
+	if d, ok := cc.Duration("s-maxage"); ok {
+		return d
+	}
+
+	if d, ok := cc.Duration("max-age"); ok {
+		return d
+	}
+
+	if value := header.Get("Expires"); value != "" {
+		expires, err := http.ParseTime(value)
+		if err != nil {
+			// RFC 9111 5.3. "A cache recipient MUST interpret invalid date
+			// formats, especially the value "0", as representing a time in the
+			// past"
+			return 0
+		}
+
+		date := resTime
+		if value := header.Get("Date"); value != "" {
+			if t, err := http.ParseTime(value); err == nil {
+				date = t
+			}
+		}
+
+		return max(expires.Sub(date), 0)
+	}
+
+	return 0
+}
+
+func isResponseStorable(req *http.Request, res *http.Response) bool {
+	// RFC 9111 3. Storing Responses in Caches, for a shared cache
+
+	if req.Method != "GET" {
+		return false
+	}
+
+	if !slices.Contains(responseCacheStatuses, res.StatusCode) {
+		return false
+	}
+
+	reqCC := httputils.ParseCacheControl(req.Header)
+	if reqCC.Has("no-store") {
+		return false
+	}
+
+	cc := httputils.ParseCacheControl(res.Header)
+	if cc.Has("no-store") || cc.Has("private") {
+		return false
+	}
+
+	// RFC 9111 3.5. Storing Responses to Authenticated Requests
+	if req.Header.Get("Authorization") != "" {
+		if !cc.Has("public") && !cc.Has("s-maxage") &&
+			!cc.Has("must-revalidate") {
+			return false
+		}
+	}
+
+	if slices.Contains(responseVaryFieldNames(res.Header), "*") {
+		return false
+	}
+
+	// Responses setting cookies are specific to a client. RFC 9111 does not
+	// forbid caching them but it is a terrible idea in a shared cache.
+	if len(res.Header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+
+	// We do not do heuristic freshness: responses must either have an
+	// explicit expiration time or be revalidated each time they are used.
+	hasExplicitExpiration := cc.Has("s-maxage") || cc.Has("max-age") ||
+		res.Header.Get("Expires") != ""
+	hasValidators := res.Header.Get("ETag") != "" ||
+		res.Header.Get("Last-Modified") != ""
+
+	return hasExplicitExpiration || (cc.Has("no-cache") && hasValidators)
+}
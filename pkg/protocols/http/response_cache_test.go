@@ -0,0 +1,115 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/log"
+)
+
+func TestResponseCachePersistence(t *testing.T) {
+	require := require.New(t)
+
+	dirPath := t.TempDir()
+
+	cfg := ResponseCacheCfg{
+		Enabled:          true,
+		MaxSize:          1024,
+		MaxEntrySize:     64,
+		MemoryBufferSize: 8,
+		Directory:        dirPath,
+	}
+
+	logger := log.DefaultLogger("response_cache")
+
+	store := func(c *ResponseCache, key, acceptLanguage, content string) {
+		reqHeader := make(http.Header)
+		reqHeader.Set("Accept-Language", acceptLanguage)
+
+		res := http.Response{
+			StatusCode: 200,
+			Header:     make(http.Header),
+		}
+		res.Header.Set("Cache-Control", "max-age=60")
+		res.Header.Set("Vary", "Accept-Language")
+
+		body := c.NewBodyBuffer()
+		_, err := body.Write([]byte(content))
+		require.NoError(err)
+
+		now := time.Now()
+		c.Store(NewResponseCacheEntry(key, reqHeader, &res, body, now, now))
+	}
+
+	lookup := func(c *ResponseCache, key, acceptLanguage string) string {
+		reqHeader := make(http.Header)
+		reqHeader.Set("Accept-Language", acceptLanguage)
+
+		entry, body, err := c.Lookup(key, reqHeader)
+		require.NoError(err)
+		if entry == nil {
+			return ""
+		}
+		defer body.Close()
+
+		require.Equal(200, entry.Status)
+		require.Equal("max-age=60", entry.Header.Get("Cache-Control"))
+
+		data, err := io.ReadAll(body)
+		require.NoError(err)
+		return string(data)
+	}
+
+	c, err := NewResponseCache(&cfg, logger)
+	require.NoError(err)
+
+	store(c, "/a", "en", "short")
+	store(c, "/a", "fr", "more than 8 bytes")
+	store(c, "/b", "en", "b1")
+	store(c, "/b", "en", "b2")
+
+	// A file left by a response being buffered when the server stopped
+	orphanPath := path.Join(dirPath, strings.Repeat("ab", 16))
+	require.NoError(os.WriteFile(orphanPath, []byte("orphan"), 0600))
+
+	c.Close()
+
+	c, err = NewResponseCache(&cfg, logger)
+	require.NoError(err)
+
+	require.Equal("short", lookup(c, "/a", "en"))
+	require.Equal("more than 8 bytes", lookup(c, "/a", "fr"))
+	require.Equal("b2", lookup(c, "/b", "en"))
+	require.Equal("", lookup(c, "/b", "fr"))
+
+	require.NoFileExists(orphanPath)
+
+	// Three entries, each made of a body file and an entry file
+	dirEntries, err := os.ReadDir(dirPath)
+	require.NoError(err)
+	require.Len(dirEntries, 6)
+
+	entry, body, err := c.Lookup("/a", http.Header{"Accept-Language": {"en"}})
+	require.NoError(err)
+	body.Close()
+	c.Delete(entry)
+
+	c.Close()
+
+	c, err = NewResponseCache(&cfg, logger)
+	require.NoError(err)
+	defer c.Close()
+
+	require.Equal("", lookup(c, "/a", "en"))
+	require.Equal("more than 8 bytes", lookup(c, "/a", "fr"))
+
+	dirEntries, err = os.ReadDir(dirPath)
+	require.NoError(err)
+	require.Len(dirEntries, 4)
+}
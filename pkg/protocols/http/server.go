@@ -82,6 +82,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	ctx.Protocol = s.Protocol
 	ctx.Listener = s.Listener
 	ctx.AccessLogger = s.Protocol.accessLogger
+	ctx.ErrorPages = s.Protocol.errorPages
 
 	defer ctx.Recover()
 	defer ctx.OnRequestHandled()
@@ -106,32 +107,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	s.handleHSTS(ctx)
 
-	h := s.Protocol.findHandler(ctx)
-	if h == nil {
-		ctx.ReplyError2(404, "unhandled request")
-		return
-	}
-
-	if rl := ctx.RequestRateLimiter; rl != nil {
-		if rl.Update(1, ctx.ClientAddress, ctx.StartTime) == false {
-			ctx.ReplyError2(429, "rate limit reached")
-			return
-		}
-	}
-
-	if ctx.Auth != nil {
-		if err := ctx.Auth.AuthenticateRequest(ctx); err != nil {
-			ctx.Log.Error("cannot authenticate request: %v", err)
-			return
-		}
-	}
-
-	if h.Action == nil {
-		ctx.ReplyError(501)
-		return
-	}
-
-	h.Action.HandleRequest(ctx)
+	s.Protocol.handleRequest(ctx)
 
 	if s.Protocol.Cfg.DebugLogVariables {
 		ctx.LogVariables()
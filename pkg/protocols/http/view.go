@@ -50,25 +50,22 @@ func (v *View) Load() error {
 }
 
 func (v *View) Render(tplName string, data any, ctx *RequestContext) ([]byte, error) {
-	mediaTypes := []*MediaType{MediaTypeJSON}
-
-	if v.textTemplates != nil {
-		mediaTypes = append(mediaTypes, MediaTypeText)
-	}
-
-	if v.htmlTemplates != nil {
-		mediaTypes = append(mediaTypes, MediaTypeHTML)
-	}
+	mediaType := ctx.NegotiateMediaType(statusMediaTypes)
+	return v.RenderMediaType(mediaType, tplName, data, ctx)
+}
 
+func (v *View) RenderMediaType(mediaType *MediaType, tplName string, data any, ctx *RequestContext) ([]byte, error) {
 	var fn func(string, any, *RequestContext) ([]byte, error)
 
-	switch ctx.NegotiateMediaType(statusMediaTypes) {
+	switch mediaType {
 	case MediaTypeJSON:
 		fn = v.renderJSON
 	case MediaTypeText:
 		fn = v.renderText
 	case MediaTypeHTML:
 		fn = v.renderHTML
+	default:
+		return nil, fmt.Errorf("unsupported media type %q", mediaType)
 	}
 
 	return fn(tplName, data, ctx)
@@ -0,0 +1,65 @@
+package scgi
+
+import (
+	"bufio"
+	"bytes"
+	"maps"
+	"slices"
+	"strconv"
+
+	"go.n16f.net/boulevard/pkg/fastcgi"
+)
+
+// Reference: https://python.ca/scgi/protocol.txt.
+
+const MaxHeaderSize = 64 * 1024
+
+// Protocol implements cgigateway.Protocol for SCGI.
+type Protocol struct{}
+
+func (Protocol) EncodeRequest(params map[string]string) ([]byte, error) {
+	return EncodeHeader(params), nil
+}
+
+func (Protocol) ReadResponseHeader(r *bufio.Reader) (*fastcgi.Header, error) {
+	return fastcgi.ReadHeader(r, MaxHeaderSize)
+}
+
+// EncodeHeader encodes request parameters as a netstring. CONTENT_LENGTH must
+// be the first parameter; the SCGI parameter is added automatically.
+func EncodeHeader(params map[string]string) []byte {
+	var buf bytes.Buffer
+
+	writeParam := func(name, value string) {
+		buf.WriteString(name)
+		buf.WriteByte(0)
+		buf.WriteString(value)
+		buf.WriteByte(0)
+	}
+
+	contentLength := params["CONTENT_LENGTH"]
+	if contentLength == "" {
+		contentLength = "0"
+	}
+
+	writeParam("CONTENT_LENGTH", contentLength)
+	writeParam("SCGI", "1")
+
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		if name == "CONTENT_LENGTH" || name == "SCGI" {
+			continue
+		}
+
+		writeParam(name, params[name])
+	}
+
+	data := buf.Bytes()
+
+	header := make([]byte, 0, len(data)+16)
+	header = strconv.AppendInt(header, int64(len(data)), 10)
+	header = append(header, ':')
+	header = append(header, data...)
+	header = append(header, ',')
+
+	return header
+}
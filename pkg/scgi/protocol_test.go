@@ -0,0 +1,181 @@
+package scgi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/cgigateway"
+	"go.n16f.net/log"
+)
+
+func TestHeader(t *testing.T) {
+	require := require.New(t)
+
+	params := map[string]string{
+		"CONTENT_LENGTH": "3",
+		"REQUEST_METHOD": "POST",
+		"EMPTY":          "",
+	}
+
+	data := EncodeHeader(params)
+	require.Equal("51:CONTENT_LENGTH\x003\x00SCGI\x001\x00EMPTY\x00\x00"+
+		"REQUEST_METHOD\x00POST\x00,", string(data))
+
+	params2, err := readHeader(bufio.NewReader(bytes.NewReader(data)))
+	require.NoError(err)
+	require.Equal(map[string]string{
+		"CONTENT_LENGTH": "3",
+		"SCGI":           "1",
+		"REQUEST_METHOD": "POST",
+		"EMPTY":          "",
+	}, params2)
+
+	_, err = readHeader(bufio.NewReader(strings.NewReader("4:a\x00b\x00;")))
+	require.Error(err)
+}
+
+func TestClient(t *testing.T) {
+	require := require.New(t)
+
+	address := startTestServer(t)
+
+	c := newTestClient(t, address)
+	defer c.Close()
+
+	params := map[string]string{
+		"CONTENT_LENGTH": "5",
+		"REQUEST_METHOD": "PUT",
+	}
+
+	var stdout bytes.Buffer
+
+	header, err := c.SendRequest(context.Background(), params,
+		strings.NewReader("hello"), &stdout)
+	require.NoError(err)
+
+	status, _ := header.Status()
+	require.Equal(201, status)
+	require.Equal("text/plain", header.Field("Content-Type"))
+	require.Equal("PUT hello", stdout.String())
+
+	// Timeout
+	params["REQUEST_METHOD"] = "SLEEP"
+	params["CONTENT_LENGTH"] = "0"
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+
+	_, err = c.SendRequest(ctx, params, nil, &stdout)
+	require.ErrorIs(err, cgigateway.ErrRequestTimeout)
+}
+
+func newTestClient(t *testing.T, address string) *cgigateway.Client {
+	cfg := cgigateway.ClientCfg{
+		Log:      log.DefaultLogger("scgi"),
+		Protocol: Protocol{},
+		Address:  address,
+	}
+
+	c, err := cgigateway.NewClient(&cfg)
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+
+	return c
+}
+
+func startTestServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveTestConnection(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func serveTestConnection(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+
+	params, err := readHeader(r)
+	if err != nil {
+		return
+	}
+
+	method := params["REQUEST_METHOD"]
+	if method == "SLEEP" {
+		time.Sleep(time.Second)
+		return
+	}
+
+	length, _ := strconv.Atoi(params["CONTENT_LENGTH"])
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return
+	}
+
+	fmt.Fprintf(conn, "Status: 201 Created\r\n")
+	fmt.Fprintf(conn, "Content-Type: text/plain\r\n")
+	fmt.Fprintf(conn, "\r\n")
+	fmt.Fprintf(conn, "%s %s", method, body)
+}
+
+// readHeader reads the header of a request as sent by a client. It is the
+// counterpart of EncodeHeader.
+func readHeader(r *bufio.Reader) (map[string]string, error) {
+	lengthString, err := r.ReadString(':')
+	if err != nil {
+		return nil, fmt.Errorf("cannot read netstring length: %w", err)
+	}
+
+	length, err := strconv.ParseInt(lengthString[:len(lengthString)-1], 10, 64)
+	if err != nil || length < 0 || length > MaxHeaderSize {
+		return nil, fmt.Errorf("invalid netstring length %q",
+			lengthString[:len(lengthString)-1])
+	}
+
+	data := make([]byte, length+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("cannot read netstring: %w", err)
+	}
+
+	if data[length] != ',' {
+		return nil, fmt.Errorf("missing netstring terminator")
+	}
+
+	parts := bytes.Split(data[:length], []byte{0})
+	if len(parts)%2 != 1 || len(parts[len(parts)-1]) != 0 {
+		return nil, fmt.Errorf("truncated parameter list")
+	}
+
+	params := make(map[string]string)
+	for i := 0; i < len(parts)-1; i += 2 {
+		params[string(parts[i])] = string(parts[i+1])
+	}
+
+	return params, nil
+}
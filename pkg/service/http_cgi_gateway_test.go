@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPSCGI(t *testing.T) {
+	startTestCGIGatewayServer(t, "localhost:9012", readTestSCGIRequest,
+		"Status: ")
+
+	testHTTPCGIGateway(t, "/scgi")
+}
+
+func TestHTTPUWSGI(t *testing.T) {
+	startTestCGIGatewayServer(t, "localhost:9013", readTestUWSGIRequest,
+		"HTTP/1.1 ")
+
+	testHTTPCGIGateway(t, "/uwsgi")
+}
+
+func testHTTPCGIGateway(t *testing.T, basePath string) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	res = c.SendRequest("POST", basePath+"/app.py/a/b?x=1", nil, "hello",
+		&resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("text/plain", res.Header.Get("Content-Type"))
+	require.Equal("POST app.py /a/b x=1 test hello", resBody)
+
+	res = c.SendRequest("GET", basePath+"/not-found.py", nil, nil, nil)
+	require.Equal(404, res.StatusCode)
+
+	res = c.SendRequest("GET", basePath+"/slow.py", nil, nil, nil)
+	require.Equal(504, res.StatusCode)
+}
+
+func startTestCGIGatewayServer(t *testing.T, address string, readRequest func(*bufio.Reader) (map[string]string, error), statusPrefix string) {
+	listener, err := net.Listen("tcp", address)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	serveConn := func(conn net.Conn) {
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+
+		vars, err := readRequest(r)
+		if err != nil {
+			return
+		}
+
+		length, _ := strconv.Atoi(vars["CONTENT_LENGTH"])
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch vars["SCRIPT_NAME"] {
+		case "app.py":
+			fmt.Fprintf(conn, "%s200 OK\r\n", statusPrefix)
+			fmt.Fprintf(conn, "Content-Type: text/plain\r\n\r\n")
+			fmt.Fprintf(conn, "%s %s %s %s %s %s", vars["REQUEST_METHOD"],
+				vars["SCRIPT_NAME"], vars["PATH_INFO"], vars["QUERY_STRING"],
+				vars["APP_ENV"], body)
+
+		case "slow.py":
+			time.Sleep(2 * time.Second)
+
+		default:
+			fmt.Fprintf(conn, "%s404 Not Found\r\n\r\n", statusPrefix)
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveConn(conn)
+		}
+	}()
+}
+
+func readTestSCGIRequest(r *bufio.Reader) (map[string]string, error) {
+	lengthString, err := r.ReadString(':')
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(lengthString[:len(lengthString)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	// Netstring content followed by ','
+	data := make([]byte, length+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	parts := bytes.Split(data[:length], []byte{0})
+
+	params := make(map[string]string)
+	for i := 0; i+1 < len(parts); i += 2 {
+		params[string(parts[i])] = string(parts[i+1])
+	}
+
+	return params, nil
+}
+
+func readTestUWSGIRequest(r *bufio.Reader) (map[string]string, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, binary.LittleEndian.Uint16(header[1:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	readString := func() string {
+		n := int(binary.LittleEndian.Uint16(data))
+		s := string(data[2 : 2+n])
+		data = data[2+n:]
+		return s
+	}
+
+	vars := make(map[string]string)
+	for len(data) > 0 {
+		name := readString()
+		vars[name] = readString()
+	}
+
+	return vars, nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPCGI(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	// Environment and standard input
+	res = c.SendRequest("POST", "/cgi/hello.cgi/a/b?x=1", nil, "foo",
+		&resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("text/plain", res.Header.Get("Content-Type"))
+	require.Equal("hello", res.Header.Get("X-Script"))
+	require.Equal("method: POST\n"+
+		"query: x=1\n"+
+		"path_info: /a/b\n"+
+		"greeting: hello world\n"+
+		"body: foo\n", resBody)
+
+	// Default script
+	res = c.SendRequest("GET", "/cgi/", nil, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Contains(resBody, "method: GET\n")
+
+	// Status field
+	res = c.SendRequest("GET", "/cgi/status.cgi", nil, nil, &resBody)
+	require.Equal(404, res.StatusCode)
+	require.Equal("nothing here\n", resBody)
+
+	// The Proxy header field is not exposed as HTTP_PROXY (httpoxy)
+	res = c.SendRequest("GET", "/cgi/proxy.cgi",
+		httputils.Header("Proxy", "http://127.0.0.1:1"), nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("proxy: \n", resBody)
+
+	// Client redirection
+	res = c.SendRequest("GET", "/cgi/redirect.cgi", nil, nil, nil)
+	require.Equal(302, res.StatusCode)
+	require.Equal("http://localhost:8080/cgi/hello.cgi",
+		res.Header.Get("Location"))
+
+	// Local redirection
+	res = c.SendRequest("POST", "/cgi/local-redirect.cgi", nil, "foo",
+		&resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("hello", res.Header.Get("X-Script"))
+	require.Equal("method: GET\n"+
+		"query: x=2\n"+
+		"path_info: /a\n"+
+		"greeting: hello world\n"+
+		"body: \n", resBody)
+
+	res = c.SendRequest("GET", "/cgi/local-redirect.cgi?loop", nil, nil, nil)
+	require.Equal(500, res.StatusCode)
+
+	// Standard error output
+	res = c.SendRequest("GET", "/cgi/stderr.cgi", nil, nil, nil)
+	require.Equal(500, res.StatusCode)
+
+	// Invalid response header
+	res = c.SendRequest("GET", "/cgi/invalid.cgi", nil, nil, nil)
+	require.Equal(502, res.StatusCode)
+
+	// Missing and non-executable scripts
+	res = c.SendRequest("GET", "/cgi/unknown.cgi", nil, nil, nil)
+	require.Equal(404, res.StatusCode)
+
+	res = c.SendRequest("GET", "/cgi/not-executable.cgi", nil, nil, nil)
+	require.Equal(403, res.StatusCode)
+
+	res = c.SendRequest("GET", "/cgi/../cgi/hello.cgi", nil, nil, nil)
+	require.Equal(200, res.StatusCode)
+
+	// Timeout
+	res = c.SendRequest("GET", "/cgi/slow.cgi", nil, nil, nil)
+	require.Equal(504, res.StatusCode)
+}
+
+func TestHTTPCGIMaxProcesses(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	var slowStatus int
+
+	go func() {
+		defer wg.Done()
+
+		res := c.SendRequest("GET", "/cgi/limited/slow.cgi", nil, nil, nil)
+		slowStatus = res.StatusCode
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+
+	res := c.SendRequest("GET", "/cgi/limited/hello.cgi", nil, nil, nil)
+	require.Equal(503, res.StatusCode)
+
+	wg.Wait()
+	require.Equal(200, slowStatus)
+
+	res = c.SendRequest("GET", "/cgi/limited/hello.cgi", nil, nil, nil)
+	require.Equal(200, res.StatusCode)
+}
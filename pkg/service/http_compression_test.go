@@ -0,0 +1,95 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPCompression(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody []byte
+
+	const text = "Lorem ipsum dolor sit amet, consectetur adipiscing elit."
+
+	sendRequest := func(uriPath, acceptEncoding string) *http.Response {
+		var header http.Header
+		if acceptEncoding != "" {
+			header = httputils.Header("Accept-Encoding", acceptEncoding)
+		}
+
+		return c.SendRequest("GET", uriPath, header, nil, &resBody)
+	}
+
+	decode := func(coding string, data []byte) string {
+		var r io.Reader
+		var err error
+
+		switch coding {
+		case "gzip":
+			r, err = gzip.NewReader(bytes.NewReader(data))
+		case "zstd":
+			r, err = zstd.NewReader(bytes.NewReader(data))
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(data))
+		}
+		require.NoError(err)
+
+		decodedData, err := io.ReadAll(r)
+		require.NoError(err)
+
+		return string(decodedData)
+	}
+
+	// Supported codings
+	for _, coding := range []string{"gzip", "zstd", "br"} {
+		res = sendRequest("/compression/large", coding)
+		require.Equal(200, res.StatusCode)
+		require.Equal(coding, res.Header.Get("Content-Encoding"))
+		require.Equal("Accept-Encoding", res.Header.Get("Vary"))
+		require.Equal(text, decode(coding, resBody))
+	}
+
+	// Quality values
+	res = sendRequest("/compression/large", "gzip;q=0.5, br;q=0.8, zstd;q=0")
+	require.Equal(200, res.StatusCode)
+	require.Equal("br", res.Header.Get("Content-Encoding"))
+	require.Equal(text, decode("br", resBody))
+
+	// Unsupported coding
+	res = sendRequest("/compression/large", "compress")
+	require.Equal(200, res.StatusCode)
+	require.Equal("", res.Header.Get("Content-Encoding"))
+	require.Equal("Accept-Encoding", res.Header.Get("Vary"))
+	require.Equal(text, string(resBody))
+
+	// Response too small
+	res = sendRequest("/compression/small", "gzip")
+	require.Equal(200, res.StatusCode)
+	require.Equal("", res.Header.Get("Content-Encoding"))
+	require.Equal("foo", string(resBody))
+
+	// Media type not eligible
+	res = sendRequest("/compression/binary", "gzip")
+	require.Equal(200, res.StatusCode)
+	require.Equal("", res.Header.Get("Content-Encoding"))
+	require.Equal("", res.Header.Get("Vary"))
+	require.Equal(text, string(resBody))
+
+	// Restricted set of algorithms
+	res = sendRequest("/compression/gzip-only", "zstd, br, gzip;q=0.1")
+	require.Equal(200, res.StatusCode)
+	require.Equal("gzip", res.Header.Get("Content-Encoding"))
+	require.Equal(text, decode("gzip", resBody))
+}
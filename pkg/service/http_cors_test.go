@@ -0,0 +1,98 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPCORS(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	// Simple requests
+	for _, origin := range []string{"https://example.com",
+		"https://foo.example.org", "http://localhost:3000"} {
+		header := httputils.Header("Origin", origin)
+		res = c.SendRequest("GET", "/cors/", header, nil, &resBody)
+		require.Equal(200, res.StatusCode)
+		require.Equal("cors", resBody)
+		require.Equal(origin, res.Header.Get("Access-Control-Allow-Origin"))
+		require.Equal("true",
+			res.Header.Get("Access-Control-Allow-Credentials"))
+		require.Equal("X-Bar", res.Header.Get("Access-Control-Expose-Headers"))
+		require.Equal("Origin", res.Header.Get("Vary"))
+	}
+
+	header := httputils.Header("Origin", "https://example.net")
+	res = c.SendRequest("GET", "/cors/", header, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Empty(res.Header.Get("Access-Control-Allow-Origin"))
+	require.Equal("Origin", res.Header.Get("Vary"))
+
+	// Preflight requests
+	header = httputils.Header(
+		"Origin", "https://example.com",
+		"Access-Control-Request-Method", "PUT",
+		"Access-Control-Request-Headers", "x-foo, content-type",
+	)
+	res = c.SendRequest("OPTIONS", "/cors/", header, nil, nil)
+	require.Equal(204, res.StatusCode)
+	require.Equal("https://example.com",
+		res.Header.Get("Access-Control-Allow-Origin"))
+	require.Equal("GET, PUT", res.Header.Get("Access-Control-Allow-Methods"))
+	require.Equal("content-type, x-foo",
+		res.Header.Get("Access-Control-Allow-Headers"))
+	require.Equal("600", res.Header.Get("Access-Control-Max-Age"))
+
+	header = httputils.Header(
+		"Origin", "https://example.com",
+		"Access-Control-Request-Method", "DELETE",
+	)
+	res = c.SendRequest("OPTIONS", "/cors/", header, nil, nil)
+	require.Equal(403, res.StatusCode)
+
+	header = httputils.Header(
+		"Origin", "https://example.com",
+		"Access-Control-Request-Method", "GET",
+		"Access-Control-Request-Headers", "x-unknown",
+	)
+	res = c.SendRequest("OPTIONS", "/cors/", header, nil, nil)
+	require.Equal(403, res.StatusCode)
+
+	header = httputils.Header(
+		"Origin", "https://example.net",
+		"Access-Control-Request-Method", "GET",
+	)
+	res = c.SendRequest("OPTIONS", "/cors/", header, nil, nil)
+	require.Equal(403, res.StatusCode)
+
+	// Any origin
+	header = httputils.Header("Origin", "https://example.net")
+	res = c.SendRequest("GET", "/cors/any", header, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("*", res.Header.Get("Access-Control-Allow-Origin"))
+	require.Empty(res.Header.Get("Access-Control-Allow-Credentials"))
+
+	header = httputils.Header(
+		"Origin", "https://example.net",
+		"Access-Control-Request-Method", "POST",
+		"Access-Control-Request-Headers", "x-foo",
+	)
+	res = c.SendRequest("OPTIONS", "/cors/any", header, nil, nil)
+	require.Equal(204, res.StatusCode)
+	require.Equal("x-foo", res.Header.Get("Access-Control-Allow-Headers"))
+
+	// Disabled
+	header = httputils.Header("Origin", "https://example.com")
+	res = c.SendRequest("GET", "/cors/disabled", header, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("disabled", resBody)
+	require.Empty(res.Header.Get("Access-Control-Allow-Origin"))
+}
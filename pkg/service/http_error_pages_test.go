@@ -0,0 +1,78 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPErrorPages(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	sendRequest := func(uriPath, accept string) *http.Response {
+		var header http.Header
+		if accept != "" {
+			header = httputils.Header("Accept", accept)
+		}
+
+		return c.SendRequest("GET", uriPath, header, nil, &resBody)
+	}
+
+	// File
+	res = sendRequest("/error-pages/serve/unknown", "")
+	require.Equal(404, res.StatusCode)
+	require.Equal("text/html;charset=utf-8", res.Header.Get("Content-Type"))
+	require.Equal("<p>Page not found</p>\n", resBody)
+
+	// Templates
+	res = sendRequest("/error-pages/upstream", "application/json")
+	require.Equal(500, res.StatusCode)
+	require.Equal("application/json", res.Header.Get("Content-Type"))
+	require.Equal(`{"error": "Internal Server Error", "status": 500}`+"\n",
+		resBody)
+
+	res = sendRequest("/error-pages/upstream", "text/html")
+	require.Equal(500, res.StatusCode)
+	require.Equal("text/html;charset=utf-8", res.Header.Get("Content-Type"))
+	require.Equal("<p>500 Internal Server Error: "+
+		"500 Internal Server Error</p>\n", resBody)
+
+	// Reply
+	res = sendRequest("/error-pages/auth", "")
+	require.Equal(401, res.StatusCode)
+	require.Equal("text/plain;charset=utf-8", res.Header.Get("Content-Type"))
+	require.Equal("authentication required: "+
+		"missing or empty Authorization header field\n", resBody)
+	require.NotEmpty(res.Header.Get("WWW-Authenticate"))
+
+	// No page for this status
+	res = sendRequest("/error-pages/serve/d/", "")
+	require.Equal(403, res.StatusCode)
+	require.Equal("text/plain;charset=utf-8", res.Header.Get("Content-Type"))
+	require.Equal("directory access denied\n", resBody)
+
+	// Default pages
+	res = sendRequest("/error-pages/default/unknown", "application/json")
+	require.Equal(404, res.StatusCode)
+	require.Equal("application/json", res.Header.Get("Content-Type"))
+	require.JSONEq(`{"status": 404, "reason": "Not Found", `+
+		`"message": "file not found"}`, resBody)
+
+	res = sendRequest("/error-pages/default/unknown", "text/html")
+	require.Equal(404, res.StatusCode)
+	require.Equal("text/html;charset=utf-8", res.Header.Get("Content-Type"))
+	require.Contains(resBody, "<h1>404 Not Found</h1>")
+
+	// Disabled
+	res = sendRequest("/error-pages/disabled/unknown", "text/html")
+	require.Equal(404, res.StatusCode)
+	require.Equal("text/plain;charset=utf-8", res.Header.Get("Content-Type"))
+	require.Equal("file not found\n", resBody)
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPFastCGI(t *testing.T) {
+	require := require.New(t)
+
+	listener, err := net.Listen("tcp", "localhost:9023")
+	require.NoError(err)
+	defer listener.Close()
+
+	go fcgi.Serve(listener, http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			// The request path is built by concatenating SCRIPT_NAME and
+			// PATH_INFO.
+			env := fcgi.ProcessEnv(req)
+			fmt.Fprintf(w, "%s %s", req.URL.Path, env["PATH_TRANSLATED"])
+
+			// HTTP_* parameters are converted to header fields
+			if value := req.Header.Get("Proxy"); value != "" {
+				fmt.Fprintf(w, " proxy=%s", value)
+			}
+		}))
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	res = c.SendRequest("GET", "/fastcgi/index.php/a/b", nil, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("index.php/a/b /app/a/b", resBody)
+
+	res = c.SendRequest("GET", "/fastcgi/index.php", nil, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("index.php/ /app", resBody)
+
+	// The Proxy header field must not be passed as HTTP_PROXY
+	res = c.SendRequest("GET", "/fastcgi/index.php",
+		httputils.Header("Proxy", "http://localhost:8888"), nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("index.php/ /app", resBody)
+}
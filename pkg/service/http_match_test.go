@@ -0,0 +1,196 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPMatchHeader(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	sendRequest := func(header http.Header) *http.Response {
+		return c.SendRequest("GET", "/match/header", header, nil, &resBody)
+	}
+
+	res = sendRequest(httputils.Header("X-Foo", "1"))
+	require.Equal(200, res.StatusCode)
+	require.Equal("foo 1", resBody)
+
+	// Header field without value constraint
+	res = sendRequest(httputils.Header("X-Foo", "2"))
+	require.Equal(200, res.StatusCode)
+	require.Equal("foo", resBody)
+
+	res = sendRequest(nil)
+	require.Equal(200, res.StatusCode)
+	require.Equal("default", resBody)
+}
+
+func TestHTTPMatchQuery(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	sendRequest := func(uriPath string) *http.Response {
+		return c.SendRequest("GET", uriPath, nil, nil, &resBody)
+	}
+
+	// Fixed values
+	res = sendRequest("/match/query?version=1")
+	require.Equal(200, res.StatusCode)
+	require.Equal("version 1", resBody)
+
+	res = sendRequest("/match/query?foo=bar&version=2")
+	require.Equal(200, res.StatusCode)
+	require.Equal("version 2", resBody)
+
+	// Regular expression
+	res = sendRequest("/match/query?version=3.14")
+	require.Equal(200, res.StatusCode)
+	require.Equal("version 3", resBody)
+
+	// Parameter without value constraint
+	res = sendRequest("/match/query?debug")
+	require.Equal(200, res.StatusCode)
+	require.Equal("debug", resBody)
+
+	res = sendRequest("/match/query?debug=false")
+	require.Equal(200, res.StatusCode)
+	require.Equal("debug", resBody)
+
+	// No match
+	res = sendRequest("/match/query?version=4")
+	require.Equal(200, res.StatusCode)
+	require.Equal("default", resBody)
+
+	res = sendRequest("/match/query")
+	require.Equal(200, res.StatusCode)
+	require.Equal("default", resBody)
+}
+
+func TestHTTPMatchAddress(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	res = c.SendRequest("GET", "/match/address", nil, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("loopback", resBody)
+}
+
+func TestHTTPMatchNot(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	sendRequest := func(method, uriPath string, header http.Header) *http.Response {
+		return c.SendRequest(method, uriPath, header, nil, &resBody)
+	}
+
+	// Method
+	res = sendRequest("GET", "/match/not/method", nil)
+	require.Equal(200, res.StatusCode)
+	require.Equal("default", resBody)
+
+	res = sendRequest("POST", "/match/not/method", nil)
+	require.Equal(200, res.StatusCode)
+	require.Equal("not get", resBody)
+
+	// Host
+	res = sendRequest("GET", "/match/not/host", nil)
+	require.Equal(200, res.StatusCode)
+	require.Equal("default", resBody)
+
+	res = sendRequest("GET", "/match/not/host",
+		httputils.Header("Host", "example.com"))
+	require.Equal(200, res.StatusCode)
+	require.Equal("not localhost", resBody)
+
+	// Multiple negated constraints must all be satisfied
+	res = sendRequest("GET", "/match/not/header", nil)
+	require.Equal(200, res.StatusCode)
+	require.Equal("no header", resBody)
+
+	res = sendRequest("GET", "/match/not/header",
+		httputils.Header("X-Foo", "1"))
+	require.Equal(200, res.StatusCode)
+	require.Equal("default", resBody)
+
+	res = sendRequest("GET", "/match/not/header",
+		httputils.Header("X-Bar", "1"))
+	require.Equal(200, res.StatusCode)
+	require.Equal("default", resBody)
+}
+
+func TestHTTPMatchRegexpCaptures(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	res = c.SendRequest("GET", "/match/capture/foo/42", nil, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("foo 42", resBody)
+
+	res = c.SendRequest("GET", "/match/capture-host",
+		httputils.Header("Host", "bar.example.com"), nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("bar bar", resBody)
+}
+
+func TestHTTPMatchCookie(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	sendRequest := func(cookie string) *http.Response {
+		var header http.Header
+		if cookie != "" {
+			header = httputils.Header("Cookie", cookie)
+		}
+
+		return c.SendRequest("GET", "/match/cookie", header, nil, &resBody)
+	}
+
+	res = sendRequest("group=a")
+	require.Equal(200, res.StatusCode)
+	require.Equal("group a", resBody)
+
+	res = sendRequest("foo=bar; group=beta-2")
+	require.Equal(200, res.StatusCode)
+	require.Equal("beta beta-2", resBody)
+
+	res = sendRequest("session=123")
+	require.Equal(200, res.StatusCode)
+	require.Equal("session", resBody)
+
+	res = sendRequest("group=b")
+	require.Equal(200, res.StatusCode)
+	require.Equal("default", resBody)
+
+	res = sendRequest("")
+	require.Equal(200, res.StatusCode)
+	require.Equal("default", resBody)
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPRedirectAction(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	res = c.SendRequest("GET", "/redirect", nil, nil, &resBody)
+	require.Equal(302, res.StatusCode)
+	require.Equal("/hello", res.Header.Get("Location"))
+	require.Equal("text/html;charset=utf-8", res.Header.Get("Content-Type"))
+	require.Contains(resBody, "/hello")
+}
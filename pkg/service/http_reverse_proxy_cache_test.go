@@ -0,0 +1,141 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+type testCacheUpstream struct {
+	nbRequests map[string]int
+	mutex      sync.Mutex
+}
+
+func (u *testCacheUpstream) NbRequests(uriPath string) int {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	return u.nbRequests[uriPath]
+}
+
+func (u *testCacheUpstream) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	u.mutex.Lock()
+	u.nbRequests[req.URL.Path]++
+	u.mutex.Unlock()
+
+	header := w.Header()
+
+	switch req.URL.Path {
+	case "/cache/fresh":
+		header.Set("Cache-Control", "max-age=60")
+		w.Write([]byte("fresh"))
+
+	case "/cache/no-store":
+		header.Set("Cache-Control", "no-store")
+		w.Write([]byte("no-store"))
+
+	case "/cache/validation":
+		header.Set("Cache-Control", "no-cache")
+		header.Set("ETag", `"v1"`)
+
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(304)
+			return
+		}
+
+		w.Write([]byte("validation"))
+
+	case "/cache/large":
+		header.Set("Cache-Control", "max-age=60")
+		w.Write([]byte(strings.Repeat("x", 100)))
+
+	case "/cache/vary":
+		header.Set("Cache-Control", "max-age=60")
+		header.Set("Vary", "X-Variant")
+		w.Write([]byte(req.Header.Get("X-Variant")))
+
+	default:
+		w.WriteHeader(404)
+	}
+}
+
+func TestHTTPReverseProxyCache(t *testing.T) {
+	require := require.New(t)
+
+	upstream := testCacheUpstream{nbRequests: make(map[string]int)}
+
+	upstreamServer := NewTestHTTPServer(t, "localhost:9004", &upstream)
+	defer upstreamServer.Stop()
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	// Fresh response
+	for range 3 {
+		res = c.SendRequest("GET", "/cache/fresh", nil, nil, &resBody)
+		require.Equal(200, res.StatusCode)
+		require.Equal("fresh", resBody)
+	}
+
+	require.Equal(1, upstream.NbRequests("/cache/fresh"))
+	require.NotEmpty(res.Header.Get("Age"))
+
+	// Conditional request served from the cache
+	header := httputils.Header("If-None-Match", `W/"foo", "fresh"`)
+	res = c.SendRequest("GET", "/cache/fresh", header, nil, nil)
+	require.Equal(200, res.StatusCode)
+
+	// Explicit revalidation
+	header = httputils.Header("Cache-Control", "no-cache")
+	res = c.SendRequest("GET", "/cache/fresh", header, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("fresh", resBody)
+	require.Equal(2, upstream.NbRequests("/cache/fresh"))
+
+	// Non-storable response
+	for range 2 {
+		res = c.SendRequest("GET", "/cache/no-store", nil, nil, &resBody)
+		require.Equal(200, res.StatusCode)
+		require.Equal("no-store", resBody)
+	}
+
+	require.Equal(2, upstream.NbRequests("/cache/no-store"))
+
+	// Validation
+	for range 2 {
+		res = c.SendRequest("GET", "/cache/validation", nil, nil, &resBody)
+		require.Equal(200, res.StatusCode)
+		require.Equal("validation", resBody)
+	}
+
+	require.Equal(2, upstream.NbRequests("/cache/validation"))
+
+	header = httputils.Header("If-None-Match", `"v1"`)
+	res = c.SendRequest("GET", "/cache/validation", header, nil, nil)
+	require.Equal(304, res.StatusCode)
+
+	// Response larger than the maximum entry size
+	for range 2 {
+		res = c.SendRequest("GET", "/cache/large", nil, nil, &resBody)
+		require.Equal(200, res.StatusCode)
+		require.Equal(100, len(resBody))
+	}
+
+	require.Equal(2, upstream.NbRequests("/cache/large"))
+
+	// Variants
+	for _, variant := range []string{"a", "b", "a", "b"} {
+		header = httputils.Header("X-Variant", variant)
+		res = c.SendRequest("GET", "/cache/vary", header, nil, &resBody)
+		require.Equal(200, res.StatusCode)
+		require.Equal(variant, resBody)
+	}
+
+	require.Equal(2, upstream.NbRequests("/cache/vary"))
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testMirroredRequest struct {
+	Method string
+	Path   string
+	Body   string
+}
+
+type testMirrorUpstream struct {
+	requests chan testMirroredRequest
+	delay    time.Duration
+}
+
+func (u *testMirrorUpstream) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	if u.requests != nil {
+		u.requests <- testMirroredRequest{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Body:   string(body),
+		}
+	}
+
+	time.Sleep(u.delay)
+
+	w.Write([]byte(req.Method + " " + string(body)))
+}
+
+func TestHTTPReverseProxyMirror(t *testing.T) {
+	require := require.New(t)
+
+	primary := testMirrorUpstream{}
+	primaryServer := NewTestHTTPServer(t, "localhost:9014", &primary)
+	defer primaryServer.Stop()
+
+	mirror := testMirrorUpstream{
+		requests: make(chan testMirroredRequest, 10),
+		delay:    500 * time.Millisecond,
+	}
+	mirrorServer := NewTestHTTPServer(t, "localhost:9015", &mirror)
+	defer mirrorServer.Stop()
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	nextMirroredRequest := func() *testMirroredRequest {
+		select {
+		case req := <-mirror.requests:
+			return &req
+		case <-time.After(time.Second):
+			return nil
+		}
+	}
+
+	// The slow mirror must not delay the response
+	start := time.Now()
+	res = c.SendRequest("POST", "/mirror/a", nil, "hello", &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("POST hello", resBody)
+	require.Less(time.Since(start), 250*time.Millisecond)
+
+	mirroredReq := nextMirroredRequest()
+	require.NotNil(mirroredReq)
+	require.Equal(testMirroredRequest{"POST", "/mirror/a", "hello"},
+		*mirroredReq)
+
+	// Requests without body
+	res = c.SendRequest("GET", "/mirror/b", nil, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+
+	mirroredReq = nextMirroredRequest()
+	require.NotNil(mirroredReq)
+	require.Equal(testMirroredRequest{"GET", "/mirror/b", ""}, *mirroredReq)
+
+	// Request bodies too large to be buffered are only sent to the primary
+	// upstream.
+	largeBody := strings.Repeat("x", 32)
+	res = c.SendRequest("PUT", "/mirror/c", nil, largeBody, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("PUT "+largeBody, resBody)
+	require.Nil(nextMirroredRequest())
+
+	// Sampling
+	res = c.SendRequest("GET", "/mirror/never/d", nil, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Nil(nextMirroredRequest())
+
+	// The mirror being down does not affect the primary upstream
+	mirrorServer.Stop()
+
+	res = c.SendRequest("POST", "/mirror/e", nil, "hello", &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("POST hello", resBody)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/boulevard"
+)
+
+func TestHTTPReverseProxyOutlierDetection(t *testing.T) {
+	require := require.New(t)
+
+	// The first server of the load balancer (localhost:9019) is down
+	upstream := http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte("hello"))
+		})
+	upstreamServer := NewTestHTTPServer(t, "localhost:9020", upstream)
+	defer upstreamServer.Stop()
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	// The failing server is ejected after two consecutive errors
+	nbErrors := 0
+	for range 4 {
+		res = c.SendRequest("GET", "/outlier/a", nil, nil, &resBody)
+		if res.StatusCode != 200 {
+			nbErrors++
+		}
+	}
+	require.Equal(2, nbErrors)
+
+	for range 4 {
+		res = c.SendRequest("GET", "/outlier/b", nil, nil, &resBody)
+		require.Equal(200, res.StatusCode)
+		require.Equal("hello", resBody)
+	}
+
+	// Status
+	header := make(http.Header)
+	header.Set("Accept", "application/json")
+
+	res = c.SendRequest("GET", "/outlier/status", header, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+
+	var status struct {
+		LoadBalancers []*boulevard.LoadBalancerStatus `json:"load_balancers"`
+	}
+	require.NoError(json.Unmarshal([]byte(resBody), &status))
+
+	var lbStatus *boulevard.LoadBalancerStatus
+	for _, s := range status.LoadBalancers {
+		if s.Name == "outlier-pool" {
+			lbStatus = s
+		}
+	}
+	require.NotNil(lbStatus)
+	require.Len(lbStatus.Servers, 2)
+
+	require.Equal("127.0.0.1:9019", lbStatus.Servers[0].Address)
+	require.True(lbStatus.Servers[0].Ejected)
+	require.NotNil(lbStatus.Servers[0].EjectedUntil)
+	require.Equal(1, lbStatus.Servers[0].NbEjections)
+
+	require.Equal("127.0.0.1:9020", lbStatus.Servers[1].Address)
+	require.False(lbStatus.Servers[1].Ejected)
+
+	for _, mediaType := range []string{"text/plain", "text/html"} {
+		header.Set("Accept", mediaType)
+
+		res = c.SendRequest("GET", "/outlier/status", header, nil, &resBody)
+		require.Equal(200, res.StatusCode)
+		require.Contains(resBody, "outlier-pool")
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testPathUpstream struct{}
+
+func (u *testPathUpstream) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	header := w.Header()
+
+	if strings.HasSuffix(req.URL.Path, "/redirect") {
+		loginPath := strings.TrimSuffix(req.URL.Path, "redirect") + "login"
+
+		location := loginPath + "?next=1"
+		if req.URL.Query().Has("absolute") {
+			location = "http://127.0.0.1:9022" + location
+		}
+
+		header.Set("Location", location)
+		header.Add("Set-Cookie", "a=1; Path="+loginPath+"; HttpOnly")
+		header.Add("Set-Cookie", "b=2; Path=/; Domain=127.0.0.1")
+		header.Add("Set-Cookie", "c=3; Path=/other; Domain=example.com")
+
+		w.WriteHeader(302)
+		return
+	}
+
+	w.Write([]byte(req.URL.RequestURI()))
+}
+
+func TestHTTPReverseProxyPathRewriting(t *testing.T) {
+	require := require.New(t)
+
+	upstreamServer := NewTestHTTPServer(t, "localhost:9022",
+		&testPathUpstream{})
+	defer upstreamServer.Stop()
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	// Request paths
+	res = c.SendRequest("GET", "/path-rewriting/strip/a/b?c=d", nil, nil,
+		&resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("/a/b?c=d", resBody)
+
+	res = c.SendRequest("GET", "/path-rewriting/strip/", nil, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("/", resBody)
+
+	res = c.SendRequest("GET", "/path-rewriting/strip/a%2Fb", nil, nil,
+		&resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("/a%2Fb", resBody)
+
+	res = c.SendRequest("GET", "/path-rewriting/base/a", nil, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("/app/a", resBody)
+
+	res = c.SendRequest("GET", "/path-rewriting/format/users/1?c=d", nil,
+		nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("/v2/users/1?c=d", resBody)
+
+	// Response header fields
+	res = c.SendRequest("GET", "/path-rewriting/strip/x/redirect", nil, nil,
+		&resBody)
+	require.Equal(302, res.StatusCode)
+	require.Equal("/path-rewriting/strip/x/login?next=1",
+		res.Header.Get("Location"))
+	require.Equal([]string{
+		"a=1; Path=/path-rewriting/strip/x/login; HttpOnly",
+		"b=2; Path=/path-rewriting/strip; Domain=127.0.0.1",
+		"c=3; Path=/path-rewriting/strip/other; Domain=example.com",
+	}, res.Header.Values("Set-Cookie"))
+
+	res = c.SendRequest("GET", "/path-rewriting/base/redirect?absolute=1",
+		nil, nil, &resBody)
+	require.Equal(302, res.StatusCode)
+	require.Equal("/path-rewriting/base/login?next=1",
+		res.Header.Get("Location"))
+	require.Equal([]string{
+		"a=1; Path=/path-rewriting/base/login; HttpOnly",
+		"b=2; Path=/; Domain=localhost",
+		"c=3; Path=/other; Domain=example.com",
+	}, res.Header.Values("Set-Cookie"))
+
+	res = c.SendRequest("GET", "/path-rewriting/format/x/redirect", nil, nil,
+		&resBody)
+	require.Equal(302, res.StatusCode)
+	require.Equal("/path-rewriting/format/x/login?next=1",
+		res.Header.Get("Location"))
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testRetryUpstream struct {
+	nbRequests map[string]int
+	mutex      sync.Mutex
+}
+
+func (u *testRetryUpstream) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	u.mutex.Lock()
+	u.nbRequests[req.URL.Path]++
+	nbRequests := u.nbRequests[req.URL.Path]
+	u.mutex.Unlock()
+
+	if strings.HasSuffix(req.URL.Path, "/slow") {
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	failures, _ := strconv.Atoi(req.URL.Query().Get("failures"))
+	if nbRequests <= failures {
+		w.WriteHeader(503)
+		return
+	}
+
+	w.Write([]byte(req.Method + " " + string(body)))
+}
+
+func (u *testRetryUpstream) NbRequests(path string) int {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	return u.nbRequests[path]
+}
+
+func TestHTTPReverseProxyRetry(t *testing.T) {
+	require := require.New(t)
+
+	// The first server of the load balancer (localhost:9017) is down
+	upstream := testRetryUpstream{
+		nbRequests: make(map[string]int),
+	}
+	upstreamServer := NewTestHTTPServer(t, "localhost:9018", &upstream)
+	defer upstreamServer.Stop()
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	// Connection errors
+	for range 4 {
+		res = c.SendRequest("POST", "/retry/a", nil, "hello", &resBody)
+		require.Equal(200, res.StatusCode)
+		require.Equal("POST hello", resBody)
+	}
+
+	res = c.SendRequest("POST", "/retry/down/a", nil, "hello", &resBody)
+	require.Equal(500, res.StatusCode)
+
+	// Statuses
+	res = c.SendRequest("GET", "/retry/status/a?failures=2", nil, nil,
+		&resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal(3, upstream.NbRequests("/retry/status/a"))
+
+	res = c.SendRequest("GET", "/retry/status/b?failures=3", nil, nil,
+		&resBody)
+	require.Equal(503, res.StatusCode)
+	require.Equal(3, upstream.NbRequests("/retry/status/b"))
+
+	// Request bodies are sent again
+	res = c.SendRequest("PUT", "/retry/status/c?failures=1", nil, "hello",
+		&resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("PUT hello", resBody)
+	require.Equal(2, upstream.NbRequests("/retry/status/c"))
+
+	// Non-idempotent requests are not retried once sent
+	res = c.SendRequest("POST", "/retry/status/d?failures=1", nil, "hello",
+		&resBody)
+	require.Equal(503, res.StatusCode)
+	require.Equal(1, upstream.NbRequests("/retry/status/d"))
+
+	// Requests whose body is too large to be buffered are not retried
+	largeBody := strings.Repeat("x", 32)
+	res = c.SendRequest("PUT", "/retry/status/e?failures=1", nil, largeBody,
+		&resBody)
+	require.Equal(503, res.StatusCode)
+	require.Equal(1, upstream.NbRequests("/retry/status/e"))
+
+	res = c.SendRequest("PUT", "/retry/status/e", nil, largeBody, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("PUT "+largeBody, resBody)
+
+	// Timeouts
+	res = c.SendRequest("GET", "/retry/timeout/slow", nil, nil, &resBody)
+	require.Equal(504, res.StatusCode)
+	require.Equal(2, upstream.NbRequests("/retry/timeout/slow"))
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testSlowUpstream struct {
+	delay time.Duration
+}
+
+func (u *testSlowUpstream) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/slow-header"):
+		time.Sleep(u.delay)
+		w.Write([]byte("hello"))
+
+	case strings.HasSuffix(req.URL.Path, "/slow-body"):
+		w.Header().Set("Content-Length", "10")
+		w.WriteHeader(200)
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+
+		time.Sleep(u.delay)
+		w.Write([]byte("world"))
+
+	default:
+		w.Write([]byte("hello"))
+	}
+}
+
+func TestHTTPReverseProxyTimeouts(t *testing.T) {
+	require := require.New(t)
+
+	upstream := testSlowUpstream{delay: 500 * time.Millisecond}
+	upstreamServer := NewTestHTTPServer(t, "localhost:9021", &upstream)
+	defer upstreamServer.Stop()
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	res = c.SendRequest("GET", "/timeouts/a", nil, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("hello", resBody)
+
+	// Response header timeout
+	res = c.SendRequest("GET", "/timeouts/slow-header", nil, nil, &resBody)
+	require.Equal(504, res.StatusCode)
+
+	// Response body timeout: the response is truncated
+	httpRes, err := http.Get("http://localhost:8080/timeouts/slow-body")
+	require.NoError(err)
+	require.Equal(200, httpRes.StatusCode)
+	_, err = io.ReadAll(httpRes.Body)
+	httpRes.Body.Close()
+	require.Error(err)
+
+	// The connection is not reused after the timeout
+	for range 3 {
+		res = c.SendRequest("GET", "/timeouts/b", nil, nil, &resBody)
+		require.Equal(200, res.StatusCode)
+		require.Equal("hello", resBody)
+	}
+
+	// Settings inherited from the load balancer
+	res = c.SendRequest("GET", "/timeouts/load-balancer/slow-header", nil,
+		nil, &resBody)
+	require.Equal(504, res.StatusCode)
+
+	// Settings overridden by the action
+	res = c.SendRequest("GET", "/timeouts/long-poll/slow-header", nil, nil,
+		&resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("hello", resBody)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPReverseProxyUpstreamTLS(t *testing.T) {
+	require := require.New(t)
+
+	startTestTLSUpstream(t, "127.0.0.1:9016")
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	// Custom CA, client certificate and server name
+	res = c.SendRequest("GET", "/upstream-tls/a", nil, nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("client /upstream-tls/a", resBody)
+
+	res = c.SendRequest("GET", "/upstream-tls/load-balancer/b", nil, nil,
+		&resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("client /upstream-tls/load-balancer/b", resBody)
+
+	res = c.SendRequest("GET", "/upstream-tls/insecure/c", nil, nil,
+		&resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("client /upstream-tls/insecure/c", resBody)
+
+	// Unknown CA
+	res = c.SendRequest("GET", "/upstream-tls/untrusted/d", nil, nil, nil)
+	require.Equal(500, res.StatusCode)
+
+	// Missing client certificate
+	res = c.SendRequest("GET", "/upstream-tls/no-client-certificate/e", nil,
+		nil, nil)
+	require.Equal(500, res.StatusCode)
+}
+
+func startTestTLSUpstream(t *testing.T, address string) {
+	cert, err := tls.LoadX509KeyPair("test/upstream-tls/server.pem",
+		"test/upstream-tls/server.key")
+	require.NoError(t, err)
+
+	caData, err := os.ReadFile("test/upstream-tls/ca.pem")
+	require.NoError(t, err)
+
+	clientCAs := x509.NewCertPool()
+	require.True(t, clientCAs.AppendCertsFromPEM(caData))
+
+	tlsCfg := tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+
+	listener, err := net.Listen("tcp", address)
+	require.NoError(t, err)
+
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		name := req.TLS.PeerCertificates[0].Subject.CommonName
+		w.Write([]byte(name + " " + req.URL.Path))
+	}
+
+	server := http.Server{
+		Handler: http.HandlerFunc(handler),
+	}
+
+	go server.Serve(tls.NewListener(listener, &tlsCfg))
+	t.Cleanup(func() { server.Close() })
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPRewriteAction(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	sendRequest := func(uriPath string) *http.Response {
+		return c.SendRequest("GET", uriPath, nil, nil, &resBody)
+	}
+
+	// Path rewriting
+	res = sendRequest("/rewrite/hello")
+	require.Equal(200, res.StatusCode)
+	require.Equal("world", resBody)
+
+	// Query rewriting
+	res = sendRequest("/rewrite/query/2")
+	require.Equal(200, res.StatusCode)
+	require.Equal("version 2", resBody)
+
+	// Rewriting to another action
+	res = sendRequest("/rewrite/serve/c/ca.txt")
+	require.Equal(200, res.StatusCode)
+	require.Equal("ca", resBody)
+
+	// Variables derived from the original request
+	res = c.SendRequest("GET", "/rewrite/capture/foo",
+		httputils.Header("Cookie", "a=1"), nil, &resBody)
+	require.Equal(200, res.StatusCode)
+	require.Equal("name: -, cookie: 1", resBody)
+
+	// Rewrite loop
+	res = sendRequest("/rewrite/loop")
+	require.Equal(500, res.StatusCode)
+}
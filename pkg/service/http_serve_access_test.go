@@ -0,0 +1,101 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPServeAccess(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	tests := []struct {
+		uriPath string
+		status  int
+		body    string
+	}{
+		// Default policies
+		{"/serve-access/default/link-inside", 200, "ca"},
+		{"/serve-access/default/link-dir/ca.txt", 200, "ca"},
+		{"/serve-access/default/.env", 200, "SECRET=1"},
+		{"/serve-access/default/a.bak", 200, "backup"},
+		{"/serve-access/default/link-dangling", 404, ""},
+
+		// No symbolic links, hidden files and deny list
+		{"/serve-access/none/a", 200, "a"},
+		{"/serve-access/none/link-inside", 403, ""},
+		{"/serve-access/none/link-outside", 403, ""},
+		{"/serve-access/none/link-dir/ca.txt", 403, ""},
+		{"/serve-access/none/.env", 403, ""},
+		{"/serve-access/none/.private/key", 403, ""},
+		{"/serve-access/none/a.bak", 403, ""},
+		{"/serve-access/none/c/ca.txt", 403, ""},
+		{"/serve-access/none/unknown.bak", 403, ""},
+
+		// Owner match and ignored hidden files
+		{"/serve-access/owner-match/link-inside", 200, "ca"},
+		{"/serve-access/owner-match/link-dangling", 404, ""},
+		{"/serve-access/owner-match/.env", 404, ""},
+		{"/serve-access/owner-match/.private/key", 404, ""},
+
+		// Inside root
+		{"/serve-access/inside-root/link-inside", 200, "ca"},
+		{"/serve-access/inside-root/link-dir/ca.txt", 200, "ca"},
+		{"/serve-access/inside-root/link-outside", 403, ""},
+		{"/serve-access/inside-root/link-dangling", 404, ""},
+	}
+
+	for _, test := range tests {
+		res = c.SendRequest("GET", test.uriPath, nil, nil, &resBody)
+		require.Equal(test.status, res.StatusCode, test.uriPath)
+
+		if test.status == 200 {
+			require.Equal(test.body, resBody, test.uriPath)
+		}
+	}
+
+	// Owner mismatch
+	if os.Geteuid() == 0 {
+		linkPath := "test/serve/link-inside"
+
+		require.NoError(os.Lchown(linkPath, 1234, 1234))
+		t.Cleanup(func() {
+			os.Lchown(linkPath, os.Getuid(), os.Getgid())
+		})
+
+		res = c.SendRequest("GET", "/serve-access/owner-match/link-inside",
+			nil, nil, nil)
+		require.Equal(403, res.StatusCode)
+	}
+
+	// Index view
+	header := httputils.Header("Accept", "application/json")
+	res = c.SendRequest("GET", "/serve-access/inside-root/", header, nil,
+		&resBody)
+	require.Equal(200, res.StatusCode)
+
+	var indexData struct {
+		Entries []struct {
+			Filename string `json:"filename"`
+		} `json:"entries"`
+	}
+	require.NoError(json.Unmarshal([]byte(resBody), &indexData))
+
+	var filenames []string
+	for _, e := range indexData.Entries {
+		filenames = append(filenames, e.Filename)
+	}
+
+	require.Contains(filenames, "link-inside")
+	require.NotContains(filenames, "link-outside")
+	require.NotContains(filenames, "link-dangling")
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPServeArchive(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	sendRequest := func(uriPath string, fields ...string) *http.Response {
+		header := httputils.Header(fields...)
+		return c.SendRequest("GET", uriPath, header, nil, &resBody)
+	}
+
+	style := strings.Repeat("body { color: red; }\n", 20)
+
+	// Zip archive
+	res = sendRequest("/archive/zip/")
+	require.Equal(200, res.StatusCode)
+	require.Equal("archive index\n", resBody)
+
+	res = sendRequest("/archive/zip/css/style.css")
+	require.Equal(200, res.StatusCode)
+	require.Equal("text/css; charset=utf-8", res.Header.Get("Content-Type"))
+	require.Equal(style, resBody)
+
+	res = sendRequest("/archive/zip/js/app.js", "Range", "bytes=8-10")
+	require.Equal(206, res.StatusCode)
+	require.Equal("log", resBody)
+
+	lastModified := res.Header.Get("Last-Modified")
+	require.Equal("Wed, 01 Jan 2025 12:00:00 GMT", lastModified)
+
+	res = sendRequest("/archive/zip/js/app.js",
+		"If-Modified-Since", lastModified)
+	require.Equal(304, res.StatusCode)
+
+	res = sendRequest("/archive/zip/unknown")
+	require.Equal(404, res.StatusCode)
+
+	res = sendRequest("/archive/zip/../../go.mod")
+	require.Equal(404, res.StatusCode)
+
+	// Index view
+	res = sendRequest("/archive/zip/css/", "Accept", "application/json")
+	require.Equal(200, res.StatusCode)
+
+	var indexData struct {
+		Entries []struct {
+			Filename string `json:"filename"`
+			Size     int    `json:"size"`
+		} `json:"entries"`
+	}
+	require.NoError(json.Unmarshal([]byte(resBody), &indexData))
+	require.Len(indexData.Entries, 1)
+	require.Equal("style.css", indexData.Entries[0].Filename)
+	require.Equal(len(style), indexData.Entries[0].Size)
+
+	// Gzip-compressed tar archive with a base path
+	res = sendRequest("/archive/tar-gz/style.css")
+	require.Equal(200, res.StatusCode)
+	require.Equal(style, resBody)
+
+	res = sendRequest("/archive/tar-gz/index.html")
+	require.Equal(404, res.StatusCode)
+
+	// Reloading
+	archivePath := "test/archive/site.zip"
+
+	archiveData, err := os.ReadFile(archivePath)
+	require.NoError(err)
+
+	t.Cleanup(func() {
+		os.WriteFile(archivePath, archiveData, 0644)
+	})
+
+	tmpPath := archivePath + ".tmp"
+	file, err := os.Create(tmpPath)
+	require.NoError(err)
+	zw := zip.NewWriter(file)
+	w, err := zw.Create("index.html")
+	require.NoError(err)
+	_, err = w.Write([]byte("new archive index\n"))
+	require.NoError(err)
+	require.NoError(zw.Close())
+	require.NoError(file.Close())
+	require.NoError(os.Rename(tmpPath, archivePath))
+
+	time.Sleep(1100 * time.Millisecond)
+
+	res = sendRequest("/archive/zip/")
+	require.Equal(200, res.StatusCode)
+	require.Equal("new archive index\n", resBody)
+
+	res = sendRequest("/archive/zip/css/style.css")
+	require.Equal(404, res.StatusCode)
+}
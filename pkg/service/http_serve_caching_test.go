@@ -0,0 +1,95 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPServeCaching(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody []byte
+
+	sendRequest := func(uriPath string, fields ...string) *http.Response {
+		header := httputils.Header(fields...)
+		return c.SendRequest("GET", uriPath, header, nil, &resBody)
+	}
+
+	contentETag := func(filePath string) string {
+		data, err := os.ReadFile(filePath)
+		require.NoError(err)
+
+		hash := sha256.Sum256(data)
+		return `"` + hex.EncodeToString(hash[:16]) + `"`
+	}
+
+	// Cache control rules
+	res = sendRequest("/caching/assets/app.js")
+	require.Equal(200, res.StatusCode)
+	require.Equal("public, max-age=31536000, immutable",
+		res.Header.Get("Cache-Control"))
+
+	res = sendRequest("/caching/about.html")
+	require.Equal(200, res.StatusCode)
+	require.Equal("private, max-age=60", res.Header.Get("Cache-Control"))
+
+	res = sendRequest("/caching/")
+	require.Equal(200, res.StatusCode)
+	require.Equal("no-cache", res.Header.Get("Cache-Control"))
+
+	res = sendRequest("/caching/docs/")
+	require.Equal(200, res.StatusCode)
+	require.Equal("no-cache", res.Header.Get("Cache-Control"))
+
+	// Modification time and size
+	res = sendRequest("/caching/about.html")
+	require.Equal(200, res.StatusCode)
+	etag := res.Header.Get("ETag")
+	require.Regexp(`^"[0-9a-f]+-[0-9a-f]+"$`, etag)
+
+	res = sendRequest("/caching/about.html", "If-None-Match", etag)
+	require.Equal(304, res.StatusCode)
+	require.Equal(etag, res.Header.Get("ETag"))
+	require.Equal("private, max-age=60", res.Header.Get("Cache-Control"))
+
+	res = sendRequest("/caching/about.html", "If-None-Match", `"foo"`)
+	require.Equal(200, res.StatusCode)
+
+	// Content hash
+	etag = contentETag("test/precompressed/plain.txt")
+
+	for range 2 {
+		res = sendRequest("/caching/content-hash/plain.txt")
+		require.Equal(200, res.StatusCode)
+		require.Equal(etag, res.Header.Get("ETag"))
+		require.Empty(res.Header.Get("Cache-Control"))
+	}
+
+	res = sendRequest("/caching/content-hash/plain.txt", "If-None-Match", etag)
+	require.Equal(304, res.StatusCode)
+
+	// Precompressed variants
+	etag = contentETag("test/precompressed/style.css.gz")
+	etag = etag[:len(etag)-1] + `-gzip"`
+
+	res = sendRequest("/caching/content-hash/style.css",
+		"Accept-Encoding", "gzip")
+	require.Equal(200, res.StatusCode)
+	require.Equal("gzip", res.Header.Get("Content-Encoding"))
+	require.Equal(etag, res.Header.Get("ETag"))
+
+	res = sendRequest("/caching/content-hash/style.css",
+		"Accept-Encoding", "identity")
+	require.Equal(200, res.StatusCode)
+	require.Equal(contentETag("test/precompressed/style.css"),
+		res.Header.Get("ETag"))
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPServeIndexView(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	type indexData struct {
+		DirectoryPath string `json:"directory_path"`
+		Readme        string `json:"readme"`
+		Entries       []struct {
+			Filename  string `json:"filename"`
+			Directory bool   `json:"directory"`
+			Size      int64  `json:"size"`
+		} `json:"entries"`
+		NbEntries int    `json:"nb_entries"`
+		Truncated bool   `json:"truncated"`
+		Sort      string `json:"sort"`
+		Order     string `json:"order"`
+		Page      int    `json:"page"`
+		NbPages   int    `json:"nb_pages"`
+	}
+
+	sendRequest := func(uriPath, accept string) *http.Response {
+		header := httputils.Header("Accept", accept)
+		return c.SendRequest("GET", uriPath, header, nil, &resBody)
+	}
+
+	sendJSONRequest := func(uriPath string) *indexData {
+		res := sendRequest(uriPath, "application/json")
+		require.Equal(200, res.StatusCode)
+		require.Equal("application/json", res.Header.Get("Content-Type"))
+
+		var data indexData
+		require.NoError(json.Unmarshal([]byte(resBody), &data))
+		return &data
+	}
+
+	filenames := func(data *indexData) []string {
+		names := make([]string, len(data.Entries))
+		for i, e := range data.Entries {
+			names[i] = e.Filename
+		}
+		return names
+	}
+
+	// Modification times are not preserved by git, so we set them ourselves
+	now := time.Now()
+	for i, name := range []string{"gamma.txt", "beta.bin", "README.txt",
+		"sub", "alpha.txt"} {
+		mtime := now.Add(time.Duration(i-10) * time.Minute)
+		filePath := path.Join("test/index-view", name)
+		require.NoError(os.Chtimes(filePath, mtime, mtime))
+	}
+
+	// JSON output
+	data := sendJSONRequest("/index-view/")
+	require.Equal("Artifact mirror\n", data.Readme)
+	require.Equal(5, data.NbEntries)
+	require.False(data.Truncated)
+	require.Equal("name", data.Sort)
+	require.Equal("asc", data.Order)
+	require.Equal(1, data.NbPages)
+	require.Equal([]string{"README.txt", "alpha.txt", "beta.bin",
+		"gamma.txt", "sub"}, filenames(data))
+	require.True(data.Entries[4].Directory)
+	require.Equal(int64(20), data.Entries[2].Size)
+
+	// Sorting
+	data = sendJSONRequest("/index-view/?sort=size")
+	require.Equal([]string{"sub", "gamma.txt", "alpha.txt", "README.txt",
+		"beta.bin"}, filenames(data))
+
+	data = sendJSONRequest("/index-view/?sort=name&order=desc")
+	require.Equal([]string{"sub", "gamma.txt", "beta.bin", "alpha.txt",
+		"README.txt"}, filenames(data))
+
+	data = sendJSONRequest("/index-view/?sort=mtime")
+	require.Equal([]string{"gamma.txt", "beta.bin", "README.txt", "sub",
+		"alpha.txt"}, filenames(data))
+
+	// Pagination
+	data = sendJSONRequest("/index-view/?page_size=2&page=2")
+	require.Equal(2, data.Page)
+	require.Equal(3, data.NbPages)
+	require.Equal([]string{"beta.bin", "gamma.txt"}, filenames(data))
+
+	data = sendJSONRequest("/index-view/?page_size=2&page=4")
+	require.Empty(data.Entries)
+
+	// Invalid parameters
+	for _, query := range []string{"sort=foo", "order=up", "page=0",
+		"page_size=x"} {
+		res = sendRequest("/index-view/?"+query, "application/json")
+		require.Equal(400, res.StatusCode, query)
+	}
+
+	// Maximum number of files and hidden files
+	data = sendJSONRequest("/index-view/all/")
+	require.Equal(3, data.NbEntries)
+	require.True(data.Truncated)
+	require.Equal(2, data.NbPages)
+	require.Equal([]string{".hidden", "README.txt"}, filenames(data))
+
+	data = sendJSONRequest("/index-view/all/?page=2")
+	require.Equal([]string{"alpha.txt"}, filenames(data))
+
+	data = sendJSONRequest("/index-view/all/?order=desc")
+	require.True(data.Truncated)
+	require.Equal([]string{"sub", "gamma.txt"}, filenames(data))
+
+	// Text and HTML output
+	res = sendRequest("/index-view/?sort=size&order=desc", "text/plain")
+	require.Equal(200, res.StatusCode)
+	require.Equal("text/plain;charset=utf-8", res.Header.Get("Content-Type"))
+	require.Contains(resBody, "Artifact mirror\n")
+	require.Regexp(`(?s)beta\.bin.*README\.txt.*alpha\.txt`, resBody)
+	require.NotContains(resBody, ".hidden")
+
+	res = sendRequest("/index-view/", "text/html")
+	require.Equal(200, res.StatusCode)
+	require.Equal("text/html;charset=utf-8", res.Header.Get("Content-Type"))
+	require.Contains(resBody, "<pre>Artifact mirror\n</pre>")
+	require.Contains(resBody, `href="?order=desc&amp;sort=name"`)
+}
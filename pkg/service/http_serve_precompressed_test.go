@@ -0,0 +1,83 @@
+package service
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPServePrecompressed(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody []byte
+
+	sendRequest := func(uriPath, acceptEncoding string, fields ...string) *http.Response {
+		header := httputils.Header(fields...)
+		header.Set("Accept-Encoding", acceptEncoding)
+
+		return c.SendRequest("GET", uriPath, header, nil, &resBody)
+	}
+
+	fileContent := func(filePath string) []byte {
+		data, err := os.ReadFile(filePath)
+		require.NoError(err)
+		return data
+	}
+
+	// Best variant
+	res = sendRequest("/precompressed/style.css", "gzip, zstd, br")
+	require.Equal(200, res.StatusCode)
+	require.Equal("zstd", res.Header.Get("Content-Encoding"))
+	require.Equal("text/css; charset=utf-8", res.Header.Get("Content-Type"))
+	require.Equal("Accept-Encoding", res.Header.Get("Vary"))
+	require.Equal(fileContent("test/precompressed/style.css.zst"), resBody)
+
+	res = sendRequest("/precompressed/style.css", "gzip, zstd;q=0.5")
+	require.Equal(200, res.StatusCode)
+	require.Equal("gzip", res.Header.Get("Content-Encoding"))
+	require.Equal(fileContent("test/precompressed/style.css.gz"), resBody)
+
+	// Configured algorithms
+	res = sendRequest("/precompressed/gzip-only/style.css", "zstd, gzip")
+	require.Equal(200, res.StatusCode)
+	require.Equal("gzip", res.Header.Get("Content-Encoding"))
+
+	// Missing variant
+	res = sendRequest("/precompressed/script.js", "br, zstd")
+	require.Equal(200, res.StatusCode)
+	require.Empty(res.Header.Get("Content-Encoding"))
+	require.Equal("Accept-Encoding", res.Header.Get("Vary"))
+	require.Equal(fileContent("test/precompressed/script.js"), resBody)
+
+	// No variant at all
+	res = sendRequest("/precompressed/plain.txt", "gzip")
+	require.Equal(200, res.StatusCode)
+	require.Empty(res.Header.Get("Content-Encoding"))
+	require.Empty(res.Header.Get("Vary"))
+
+	// Identity
+	res = sendRequest("/precompressed/style.css", "identity")
+	require.Equal(200, res.StatusCode)
+	require.Empty(res.Header.Get("Content-Encoding"))
+	require.Equal(fileContent("test/precompressed/style.css"), resBody)
+
+	// Range request
+	res = sendRequest("/precompressed/style.css", "gzip", "Range", "bytes=0-9")
+	require.Equal(206, res.StatusCode)
+	require.Equal("gzip", res.Header.Get("Content-Encoding"))
+	require.Equal(fileContent("test/precompressed/style.css.gz")[:10], resBody)
+
+	// Conditional request
+	lastModified := res.Header.Get("Last-Modified")
+	require.NotEmpty(lastModified)
+
+	res = sendRequest("/precompressed/style.css", "gzip",
+		"If-Modified-Since", lastModified)
+	require.Equal(304, res.StatusCode)
+}
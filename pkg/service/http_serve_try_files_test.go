@@ -0,0 +1,55 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServeTryFiles(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	sendRequest := func(uriPath string) *http.Response {
+		return c.SendRequest("GET", uriPath, nil, nil, &resBody)
+	}
+
+	tests := []struct {
+		uriPath string
+		status  int
+		body    string
+	}{
+		// Exact file
+		{"/try-files/assets/app.js", 200, "app\n"},
+		{"/try-files/about.html", 200, "about\n"},
+
+		// Pretty URL
+		{"/try-files/about", 200, "about\n"},
+
+		// Directory with an index file
+		{"/try-files/docs", 200, "docs\n"},
+
+		// Single-page application fallback
+		{"/try-files/", 200, "index\n"},
+		{"/try-files/foo/bar", 200, "index\n"},
+		{"/try-files/assets/unknown.js", 200, "index\n"},
+
+		// Status fallback
+		{"/try-files/status/about.html", 200, "about\n"},
+		{"/try-files/status/about", 410, "gone"},
+
+		// Rewrite fallback
+		{"/try-files/rewrite/unknown", 200, "about\n"},
+	}
+
+	for _, test := range tests {
+		res = sendRequest(test.uriPath)
+		require.Equal(test.status, res.StatusCode, test.uriPath)
+		require.Equal(test.body, resBody, test.uriPath)
+	}
+}
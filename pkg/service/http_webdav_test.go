@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/httputils"
+)
+
+func TestHTTPWebDAV(t *testing.T) {
+	require := require.New(t)
+
+	c := testHTTPClient(t)
+
+	var res *http.Response
+	var resBody string
+
+	// Roots are per-user directories
+	rootPath := "/tmp/boulevard/webdav"
+	require.NoError(os.RemoveAll(rootPath))
+
+	for _, username := range []string{"alice", "bob", "shared"} {
+		require.NoError(os.MkdirAll(path.Join(rootPath, username), 0700))
+	}
+
+	auth := func(username, password string) string {
+		credentials := []byte(username + ":" + password)
+		return "Basic " + base64.StdEncoding.EncodeToString(credentials)
+	}
+
+	aliceAuth := auth("alice", "bar")
+	bobAuth := auth("bob", "foo")
+
+	sendRequest := func(method, uriPath, authorization string, reqBody any, fields ...string) *http.Response {
+		header := httputils.Header(fields...)
+		header.Set("Authorization", authorization)
+		return c.SendRequest(method, uriPath, header, reqBody, &resBody)
+	}
+
+	// Authentication
+	res = c.SendRequest("PROPFIND", "/webdav/", nil, nil, nil)
+	require.Equal(401, res.StatusCode)
+
+	// Writing
+	res = sendRequest("PUT", "/webdav/a.txt", aliceAuth, "hello")
+	require.Equal(201, res.StatusCode)
+
+	data, err := os.ReadFile(path.Join(rootPath, "alice/a.txt"))
+	require.NoError(err)
+	require.Equal("hello", string(data))
+
+	res = sendRequest("GET", "/webdav/a.txt", aliceAuth, nil)
+	require.Equal(200, res.StatusCode)
+	require.Equal("hello", resBody)
+
+	res = sendRequest("MKCOL", "/webdav/docs", aliceAuth, nil)
+	require.Equal(201, res.StatusCode)
+
+	res = sendRequest("COPY", "/webdav/a.txt", aliceAuth, nil,
+		"Destination", "/webdav/docs/b.txt")
+	require.Equal(201, res.StatusCode)
+
+	res = sendRequest("MOVE", "/webdav/docs/b.txt", aliceAuth, nil,
+		"Destination", "/webdav/docs/c.txt")
+	require.Equal(201, res.StatusCode)
+
+	res = sendRequest("PROPFIND", "/webdav/docs/", aliceAuth, nil,
+		"Depth", "1")
+	require.Equal(207, res.StatusCode)
+	require.Contains(resBody, "<D:href>/webdav/docs/c.txt</D:href>")
+	require.NotContains(resBody, "b.txt")
+
+	res = sendRequest("PROPPATCH", "/webdav/a.txt", aliceAuth,
+		`<?xml version="1.0" encoding="utf-8"?>`+
+			`<D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:example">`+
+			`<D:set><D:prop><Z:author>alice</Z:author></D:prop></D:set>`+
+			`</D:propertyupdate>`)
+	require.Equal(207, res.StatusCode)
+
+	res = sendRequest("DELETE", "/webdav/docs/c.txt", aliceAuth, nil)
+	require.Equal(204, res.StatusCode)
+
+	_, err = os.Stat(path.Join(rootPath, "alice/docs/c.txt"))
+	require.ErrorIs(err, os.ErrNotExist)
+
+	// Locking
+	res = sendRequest("LOCK", "/webdav/a.txt", aliceAuth,
+		`<?xml version="1.0" encoding="utf-8"?>`+
+			`<D:lockinfo xmlns:D="DAV:">`+
+			`<D:lockscope><D:exclusive/></D:lockscope>`+
+			`<D:locktype><D:write/></D:locktype>`+
+			`</D:lockinfo>`)
+	require.Equal(200, res.StatusCode)
+	lockToken := res.Header.Get("Lock-Token")
+	require.NotEmpty(lockToken)
+
+	res = sendRequest("PUT", "/webdav/a.txt", aliceAuth, "world")
+	require.Equal(423, res.StatusCode)
+
+	res = sendRequest("UNLOCK", "/webdav/a.txt", aliceAuth, nil,
+		"Lock-Token", lockToken)
+	require.Equal(204, res.StatusCode)
+
+	res = sendRequest("PUT", "/webdav/a.txt", aliceAuth, "world")
+	require.Equal(201, res.StatusCode)
+
+	// Users who are not writers
+	res = sendRequest("PUT", "/webdav/a.txt", bobAuth, "bob")
+	require.Equal(403, res.StatusCode)
+
+	res = sendRequest("PROPFIND", "/webdav/", bobAuth, nil, "Depth", "1")
+	require.Equal(207, res.StatusCode)
+	require.NotContains(resBody, "a.txt")
+
+	// Read-only
+	res = sendRequest("GET", "/webdav/read-only/a.txt", aliceAuth, nil)
+	require.Equal(200, res.StatusCode)
+	require.Equal("world", resBody)
+
+	res = sendRequest("DELETE", "/webdav/read-only/a.txt", aliceAuth, nil)
+	require.Equal(403, res.StatusCode)
+
+	// Nested handler with a relative path
+	res = sendRequest("PUT", "/webdav/shared/files/a.txt", bobAuth, "hello")
+	require.Equal(201, res.StatusCode)
+
+	res = sendRequest("COPY", "/webdav/shared/files/a.txt", bobAuth, nil,
+		"Destination", "/webdav/shared/files/b.txt")
+	require.Equal(201, res.StatusCode)
+
+	res = sendRequest("MOVE", "/webdav/shared/files/b.txt", bobAuth, nil,
+		"Destination", "/webdav/shared/files/c.txt")
+	require.Equal(201, res.StatusCode)
+
+	data, err = os.ReadFile(path.Join(rootPath, "shared/c.txt"))
+	require.NoError(err)
+	require.Equal("hello", string(data))
+
+	res = sendRequest("PROPFIND", "/webdav/shared/files/", bobAuth, nil,
+		"Depth", "1")
+	require.Equal(207, res.StatusCode)
+	require.Contains(resBody, "<D:href>/webdav/shared/files/c.txt</D:href>")
+	require.NotContains(resBody, "b.txt")
+
+	// Invalid root directories
+	res = sendRequest("PROPFIND", "/webdav/invalid-root/", aliceAuth, nil)
+	require.Equal(500, res.StatusCode)
+
+	res = sendRequest("PROPFIND", "/webdav/invalid-root/?root=tmp", aliceAuth,
+		nil)
+	require.Equal(500, res.StatusCode)
+
+	res = sendRequest("PROPFIND", "/webdav/invalid-root/?root=/tmp/..",
+		aliceAuth, nil)
+	require.Equal(500, res.StatusCode)
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+)
+
+type TestHTTPServer struct {
+	httpServer *http.Server
+
+	t *testing.T
+}
+
+func NewTestHTTPServer(t *testing.T, address string, handler http.Handler) *TestHTTPServer {
+	s := TestHTTPServer{
+		t: t,
+	}
+
+	s.httpServer = &http.Server{
+		Addr:    address,
+		Handler: handler,
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("cannot listen on %q: %v", address, err)
+	}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil {
+			if err != http.ErrServerClosed {
+				t.Errorf("cannot serve: %v", err)
+			}
+		}
+	}()
+
+	return &s
+}
+
+func (s *TestHTTPServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	s.httpServer.Shutdown(ctx)
+}
@@ -0,0 +1,106 @@
+package uwsgi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.n16f.net/boulevard/pkg/fastcgi"
+)
+
+// Reference: https://uwsgi-docs.readthedocs.io/en/latest/Protocol.html.
+
+const MaxHeaderSize = 64 * 1024
+
+// The WSGI request modifier, used for all requests carrying CGI-like
+// variables, whatever the language of the application.
+const ModifierWSGI = 0
+
+// Protocol implements cgigateway.Protocol for uwsgi.
+type Protocol struct{}
+
+func (Protocol) EncodeRequest(vars map[string]string) ([]byte, error) {
+	return EncodePacket(ModifierWSGI, vars)
+}
+
+func (Protocol) ReadResponseHeader(r *bufio.Reader) (*fastcgi.Header, error) {
+	return ReadResponseHeader(r, MaxHeaderSize)
+}
+
+// EncodePacket encodes request variables as a uwsgi packet.
+func EncodePacket(modifier1 uint8, vars map[string]string) ([]byte, error) {
+	size := 0
+	for name, value := range vars {
+		if len(name) > 0xffff || len(value) > 0xffff {
+			return nil, fmt.Errorf("variable %q too large", name)
+		}
+
+		size += 2 + len(name) + 2 + len(value)
+	}
+
+	if size > 0xffff {
+		return nil, fmt.Errorf("packet too large (%d bytes)", size)
+	}
+
+	data := make([]byte, 4, 4+size)
+	data[0] = modifier1
+	binary.LittleEndian.PutUint16(data[1:], uint16(size))
+	data[3] = 0
+
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		value := vars[name]
+
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(name)))
+		data = append(data, name...)
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(value)))
+		data = append(data, value...)
+	}
+
+	return data, nil
+}
+
+// ReadResponseHeader reads the header of a response. Applications usually
+// send an HTTP status line before header fields; it is converted to a Status
+// field.
+func ReadResponseHeader(r *bufio.Reader, maxSize int) (*fastcgi.Header, error) {
+	data, err := r.Peek(5)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	var status string
+
+	if string(data) == "HTTP/" {
+		line, err := r.ReadSlice('\n')
+		if err != nil {
+			return nil, fmt.Errorf("cannot read status line: %w", err)
+		}
+
+		_, status, _ = strings.Cut(strings.TrimRight(string(line), "\r\n"), " ")
+		if status == "" {
+			return nil, fmt.Errorf("invalid status line %q", line)
+		}
+
+		maxSize -= len(line)
+	}
+
+	header, err := fastcgi.ReadHeader(r, maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != "" && header.Field("Status") == "" {
+		field := fastcgi.Field{Name: "Status", Value: status}
+		header.Fields = append(header.Fields, field)
+	}
+
+	return header, nil
+}
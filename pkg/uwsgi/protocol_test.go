@@ -0,0 +1,215 @@
+package uwsgi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.n16f.net/boulevard/pkg/cgigateway"
+	"go.n16f.net/log"
+)
+
+func TestPacket(t *testing.T) {
+	require := require.New(t)
+
+	vars := map[string]string{
+		"REQUEST_METHOD": "GET",
+		"EMPTY":          "",
+	}
+
+	data, err := EncodePacket(ModifierWSGI, vars)
+	require.NoError(err)
+	require.Equal("\x00\x1e\x00\x00"+
+		"\x05\x00EMPTY\x00\x00"+
+		"\x0e\x00REQUEST_METHOD\x03\x00GET", string(data))
+
+	modifier1, vars2, err := readPacket(bytes.NewReader(data))
+	require.NoError(err)
+	require.Equal(uint8(ModifierWSGI), modifier1)
+	require.Equal(vars, vars2)
+
+	_, err = EncodePacket(ModifierWSGI, map[string]string{
+		"X": strings.Repeat("x", 70_000),
+	})
+	require.Error(err)
+}
+
+func TestResponseHeader(t *testing.T) {
+	require := require.New(t)
+
+	r := bufio.NewReader(strings.NewReader("HTTP/1.1 404 Not Found\r\n" +
+		"Content-Type: text/plain\r\n\r\nbody"))
+
+	header, err := ReadResponseHeader(r, 1024)
+	require.NoError(err)
+
+	status, reason := header.Status()
+	require.Equal(404, status)
+	require.Equal("Not Found", reason)
+	require.Equal("text/plain", header.Field("Content-Type"))
+
+	body, err := io.ReadAll(r)
+	require.NoError(err)
+	require.Equal("body", string(body))
+
+	// Without status line
+	r = bufio.NewReader(strings.NewReader("Content-Type: text/plain\r\n\r\n"))
+
+	header, err = ReadResponseHeader(r, 1024)
+	require.NoError(err)
+
+	status, _ = header.Status()
+	require.Equal(200, status)
+}
+
+func TestClient(t *testing.T) {
+	require := require.New(t)
+
+	address := startTestServer(t)
+
+	cfg := cgigateway.ClientCfg{
+		Log:      log.DefaultLogger("uwsgi"),
+		Protocol: Protocol{},
+		Address:  address,
+	}
+
+	c, err := cgigateway.NewClient(&cfg)
+	require.NoError(err)
+	defer c.Close()
+
+	vars := map[string]string{
+		"CONTENT_LENGTH": "5",
+		"REQUEST_METHOD": "PUT",
+	}
+
+	var stdout bytes.Buffer
+
+	header, err := c.SendRequest(context.Background(), vars,
+		strings.NewReader("hello"), &stdout)
+	require.NoError(err)
+
+	status, _ := header.Status()
+	require.Equal(201, status)
+	require.Equal("text/plain", header.Field("Content-Type"))
+	require.Equal("PUT hello", stdout.String())
+
+	// Timeout
+	vars["REQUEST_METHOD"] = "SLEEP"
+	vars["CONTENT_LENGTH"] = "0"
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+
+	_, err = c.SendRequest(ctx, vars, nil, &stdout)
+	require.ErrorIs(err, cgigateway.ErrRequestTimeout)
+}
+
+func startTestServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveTestConnection(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func serveTestConnection(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+
+	_, vars, err := readPacket(r)
+	if err != nil {
+		return
+	}
+
+	method := vars["REQUEST_METHOD"]
+	if method == "SLEEP" {
+		time.Sleep(time.Second)
+		return
+	}
+
+	length, _ := strconv.Atoi(vars["CONTENT_LENGTH"])
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return
+	}
+
+	fmt.Fprintf(conn, "HTTP/1.1 201 Created\r\n")
+	fmt.Fprintf(conn, "Content-Type: text/plain\r\n")
+	fmt.Fprintf(conn, "\r\n")
+	fmt.Fprintf(conn, "%s %s", method, body)
+}
+
+// readPacket reads a packet as sent by a client. It is the counterpart of
+// EncodePacket.
+func readPacket(r io.Reader) (uint8, map[string]string, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, fmt.Errorf("cannot read packet header: %w", err)
+	}
+
+	size := binary.LittleEndian.Uint16(header[1:])
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, fmt.Errorf("cannot read packet: %w", err)
+	}
+
+	readString := func() (string, error) {
+		if len(data) < 2 {
+			return "", fmt.Errorf("truncated packet")
+		}
+
+		n := int(binary.LittleEndian.Uint16(data))
+		if len(data) < 2+n {
+			return "", fmt.Errorf("truncated packet")
+		}
+
+		s := string(data[2 : 2+n])
+		data = data[2+n:]
+
+		return s, nil
+	}
+
+	vars := make(map[string]string)
+
+	for len(data) > 0 {
+		name, err := readString()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		value, err := readString()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		vars[name] = value
+	}
+
+	return header[0], vars, nil
+}
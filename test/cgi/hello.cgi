@@ -0,0 +1,11 @@
+#!/bin/sh
+
+printf 'Content-Type: text/plain\r\n'
+printf 'X-Script: hello\r\n'
+printf '\r\n'
+
+echo "method: $REQUEST_METHOD"
+echo "query: $QUERY_STRING"
+echo "path_info: $PATH_INFO"
+echo "greeting: $GREETING"
+echo "body: $(cat)"
@@ -0,0 +1,8 @@
+#!/bin/sh
+
+if [ "$QUERY_STRING" = "loop" ]; then
+    printf 'Location: /cgi/local-redirect.cgi?loop\r\n'
+else
+    printf 'Location: /cgi/hello.cgi/a?x=2\r\n'
+fi
+printf '\r\n'
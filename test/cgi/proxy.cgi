@@ -0,0 +1,6 @@
+#!/bin/sh
+
+printf 'Content-Type: text/plain\r\n'
+printf '\r\n'
+
+echo "proxy: $HTTP_PROXY"
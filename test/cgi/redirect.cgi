@@ -0,0 +1,4 @@
+#!/bin/sh
+
+printf 'Location: http://localhost:8080/cgi/hello.cgi\r\n'
+printf '\r\n'
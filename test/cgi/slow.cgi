@@ -0,0 +1,8 @@
+#!/bin/sh
+
+sleep 2
+
+printf 'Content-Type: text/plain\r\n'
+printf '\r\n'
+
+echo "done"
@@ -0,0 +1,7 @@
+#!/bin/sh
+
+printf 'Status: 404 Not Found\r\n'
+printf 'Content-Type: text/plain\r\n'
+printf '\r\n'
+
+echo "nothing here"
@@ -0,0 +1,6 @@
+#!/bin/sh
+
+echo "something went wrong" >&2
+
+printf 'Status: 500\r\n'
+printf '\r\n'